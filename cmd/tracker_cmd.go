@@ -2,14 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/anandf/resource-tracker/pkg/analyzer"
 	dynamicbackend "github.com/anandf/resource-tracker/pkg/analyzer/dynamic"
 	graphbackend "github.com/anandf/resource-tracker/pkg/analyzer/graph"
+	"github.com/anandf/resource-tracker/pkg/argocd"
 	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/anandf/resource-tracker/pkg/dynamic"
 	"github.com/anandf/resource-tracker/pkg/env"
+	"github.com/anandf/resource-tracker/pkg/graph"
 	"github.com/anandf/resource-tracker/pkg/kube"
 	"github.com/anandf/resource-tracker/pkg/version"
 	argocdcommon "github.com/argoproj/argo-cd/v3/common"
@@ -26,15 +33,33 @@ import (
 type queryCLIConfig struct {
 	applicationName          string
 	applicationNamespace     string
+	applicationSelector      string
+	applicationProjects      []string
+	applicationNamespaces    []string
+	applicationSet           string
+	appSetPreview            bool
+	appOfAppsDepth           int
+	checkProjects            bool
 	logLevel                 string
 	kubeConfig               string
 	repoServerAddress        string
 	repoServerPlaintext      bool
 	repoServerStrictTLS      bool
 	repoServerTimeoutSeconds int
+	manifestCacheDir         string
+	localRender              bool
+	localCheckoutDir         string
+	compact                  bool
+	compactMaxUnneededKinds  int
 	argocdNamespace          string
-	strategy                 string // 'dynamic' or 'graph'
+	strategy                 string // 'dynamic', 'graph' or 'status'
 	allApps                  bool
+	queryTimeout             time.Duration
+	traversalTimeout         time.Duration
+	clusterQPS               float32
+	clusterBurst             int
+	scanWorkers              int
+	appConcurrency           int
 }
 
 // NewAnalyzeCommand creates the 'analyze' command, which is the primary entrypoint.
@@ -46,93 +71,298 @@ func NewAnalyzeCommand() *cobra.Command {
 		Short: "Analyze resource relationships and dependencies for ArgoCD applications",
 		Long:  "Analyze resource relationships and dependencies for ArgoCD applications. Can process a single app or all apps.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			log.Infof("%s %s starting [loglevel:%s]",
-				version.BinaryName(),
-				version.Version(),
-				strings.ToUpper(cfg.logLevel),
-			)
-			level, err := log.ParseLevel(cfg.logLevel)
+			opts, err := cfg.analyzerOptions()
 			if err != nil {
-				return fmt.Errorf("failed to parse log level: %w", err)
+				return err
+			}
+			backend, err := newBackend(cfg.strategy)
+			if err != nil {
+				return err
+			}
+			if stopper, ok := backend.(analyzer.Stopper); ok {
+				defer stopper.Stop()
 			}
-			log.SetLevel(level)
-			core.LogLevel = cfg.logLevel
 
-			// Require --app when --all-apps is false, to avoid silently analyzing all apps.
-			if !cfg.allApps && cfg.applicationName == "" {
-				return fmt.Errorf("application name is required to analyze a single application")
+			if cfg.applicationSet != "" {
+				return analyzeAppSet(cmd, backend, opts, cfg.strategy)
+			}
+			if cfg.checkProjects {
+				return checkProjects(cmd, backend, opts, cfg)
 			}
 
-			// Support app specified as "namespace/name" similar to `argocd app get ns/name`.
-			// If provided, override the separate applicationNamespace flag.
-			if cfg.applicationName != "" && !cfg.allApps {
-				if parts := strings.SplitN(cfg.applicationName, "/", 2); len(parts) == 2 {
-					cfg.applicationNamespace = parts[0]
-					cfg.applicationName = parts[1]
-					log.WithFields(log.Fields{
-						"applicationName":      cfg.applicationName,
-						"applicationNamespace": cfg.applicationNamespace,
-					}).Debug("Parsed application from namespace/name syntax")
-				}
+			// Execute analysis.
+			groupedKinds, err := backend.Execute(context.Background(), opts)
+			if errors.Is(err, analyzer.ErrIncompleteResult) {
+				log.WithError(err).Warn("Analysis did not complete in time, the resource inclusions below are partial")
+			} else if err != nil {
+				return err
 			}
+			printInclusions(groupedKinds, backend, cfg)
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringVar(&cfg.logLevel, "loglevel", env.GetStringVal("RESOURCE_TRACKER_LOGLEVEL", "info"), "set the loglevel to one of trace|debug|info|warn|error")
+	cmd.PersistentFlags().StringVarP(&cfg.applicationName, "app", "", "", "Application name (required for single app analysis). Supports 'namespace/name' syntax.")
+	cmd.PersistentFlags().StringVarP(&cfg.applicationNamespace, "app-namespace", "N", "", "Application namespace, the Argo CD namespace if empty. With --all-apps, applications are listed from every namespace enabled by application.namespaces in argocd-cmd-params-cm if empty.")
+	cmd.PersistentFlags().StringVarP(&cfg.applicationSelector, "selector", "l", "", "Label selector of the applications to analyze with --all-apps, e.g. 'team=payments'")
+	cmd.PersistentFlags().StringSliceVar(&cfg.applicationProjects, "project", nil, "AppProjects of the applications to analyze with --all-apps, can be repeated or comma separated")
+	cmd.PersistentFlags().StringSliceVar(&cfg.applicationNamespaces, "app-namespaces", nil, "Namespaces to list the applications to analyze from with --all-apps, instead of --app-namespace")
+	cmd.PersistentFlags().StringVar(&cfg.applicationSet, "appset", "", "Analyze the applications of this ApplicationSet as one group. Supports 'namespace/name' syntax.")
+	cmd.PersistentFlags().BoolVar(&cfg.appSetPreview, "preview", false, "With --appset, analyze the applications rendered from the ApplicationSet template instead of the existing ones. Only list and cluster generators are supported.")
+	cmd.PersistentFlags().IntVar(&cfg.appOfAppsDepth, "app-of-apps-depth", 0, "Number of levels of Applications found in the manifests of the analyzed applications whose own manifests are analyzed too, before they exist. Set to 0 to disable.")
+	cmd.PersistentFlags().BoolVar(&cfg.checkProjects, "check-projects", false, "Check the kinds of the applications against the resource lists of their AppProjects, add the kinds needed to monitor orphaned resources, and report per project")
+	cmd.PersistentFlags().StringVar(&cfg.repoServerAddress, "repo-server", env.GetStringVal("ARGOCD_REPO_SERVER", ""), "Repo server address. If empty, the CLI will port-forward to the repo-server service.")
+	cmd.PersistentFlags().BoolVar(&cfg.repoServerPlaintext, "repo-server-plaintext", false, "Use an unencrypted HTTP connection to the ArgoCD API instead of TLS.")
+	cmd.PersistentFlags().BoolVar(&cfg.repoServerStrictTLS, "repo-server-strict-tls", false, "Enable strict TLS validation for the repo server connection.")
+	cmd.PersistentFlags().IntVar(&cfg.repoServerTimeoutSeconds, "repo-server-timeout-seconds", 60, "Timeout in seconds for repo server RPC calls.")
+	cmd.PersistentFlags().StringVar(&cfg.manifestCacheDir, "manifest-cache-dir", env.GetStringVal("RESOURCE_TRACKER_MANIFEST_CACHE_DIR", ""), "Directory where the manifests generated by the repo-server are cached by resolved revision across runs. If empty, they are cached in memory for a single run.")
+	cmd.PersistentFlags().BoolVar(&cfg.localRender, "local-render", false, "Render the manifests from local git checkouts when the repo-server cannot be reached or port-forwarded. Directory, Kustomize and Helm sources stored in git are supported.")
+	cmd.PersistentFlags().StringVar(&cfg.localCheckoutDir, "local-checkout-dir", env.GetStringVal("RESOURCE_TRACKER_LOCAL_CHECKOUT_DIR", ""), "Directory where the repositories are checked out with --local-render. If empty, a directory in the user cache directory is used.")
+	cmd.PersistentFlags().BoolVar(&cfg.compact, "compact", false, "Compact the resource inclusions, replacing the kinds of a group with '*' when every kind served in the group is needed and merging the groups which need the same kinds")
+	cmd.PersistentFlags().IntVar(&cfg.compactMaxUnneededKinds, "compact-max-unneeded-kinds", 0, "With --compact, number of kinds served in a group which are not needed but may be included by replacing the kinds of the group with '*'. Set to -1 to only merge groups.")
+	cmd.PersistentFlags().StringVarP(&cfg.argocdNamespace, "namespace", "n", "argocd", "ArgoCD namespace")
+	cmd.PersistentFlags().StringVar(&cfg.kubeConfig, "kubeconfig", "", "Path to kubeconfig file for cluster access")
+	cmd.PersistentFlags().BoolVar(&cfg.allApps, "all-apps", false, "Analyze all applications in the namespace")
+	cmd.PersistentFlags().StringVar(&cfg.strategy, "strategy", "graph", "Analysis strategy: 'dynamic' (OwnerRef walking), 'graph' (Cyphernetes) or 'status' (Cyphernetes from Application.status and tracking labels, without the repo-server)")
+	cmd.PersistentFlags().Float32Var(&cfg.clusterQPS, "cluster-qps", dynamic.DefaultScanOptions.QPS, "Maximum requests per second to each destination cluster with the 'dynamic' strategy")
+	cmd.PersistentFlags().IntVar(&cfg.clusterBurst, "cluster-burst", dynamic.DefaultScanOptions.Burst, "Maximum burst of requests to each destination cluster with the 'dynamic' strategy")
+	cmd.PersistentFlags().IntVar(&cfg.scanWorkers, "scan-workers", dynamic.DefaultScanOptions.Workers, "Number of resources listed in parallel while scanning a cluster with the 'dynamic' strategy")
+	cmd.PersistentFlags().IntVar(&cfg.appConcurrency, "app-concurrency", dynamicbackend.DefaultAppConcurrency, "Number of applications analyzed in parallel with the 'dynamic' strategy")
+	cmd.PersistentFlags().DurationVar(&cfg.queryTimeout, "query-timeout", graph.DefaultQueryTimeout, "Timeout for a single graph query. Set to 0 to disable.")
+	cmd.PersistentFlags().DurationVar(&cfg.traversalTimeout, "traversal-timeout", graph.DefaultTraversalTimeout, "Total time budget for traversing application children, a partial result is reported once it is spent. Set to 0 to disable.")
+	cmd.AddCommand(newExplainCommand(cfg))
+	return cmd
+}
 
-			// Load the Kubernetes REST config (in-cluster or from kubeconfig path).
-			restCfg, err := kube.GetKubeConfig(cfg.kubeConfig)
+// newExplainCommand creates the 'analyze explain' command, which tells why a resource kind is
+// part of the resource inclusions.
+func newExplainCommand(cfg *queryCLIConfig) *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain <group>/<kind>",
+		Short: "Explain why a resource kind is part of the resource inclusions",
+		Long: "Explain why a resource kind is part of the resource inclusions, by printing the chains of relations " +
+			"from the analyzed applications to resources of that kind. The core group is selected by omitting the group, e.g. 'ConfigMap'.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			group, kind := parseGroupKind(args[0])
+			if kind == "" {
+				return fmt.Errorf("invalid resource kind %q, expected <group>/<kind>", args[0])
+			}
+			opts, err := cfg.analyzerOptions()
 			if err != nil {
-				return fmt.Errorf("failed to load kubeconfig: %w", err)
+				return err
 			}
-			repoAddr, err := ensureRepoServerAddress(restCfg, cfg.argocdNamespace, cfg.repoServerAddress)
+			backend, err := newBackend(cfg.strategy)
 			if err != nil {
 				return err
 			}
-
-			opts := analyzer.Options{
-				KubeConfig:               restCfg,
-				KubeConfigPath:           cfg.kubeConfig,
-				ArgoCDNamespace:          cfg.argocdNamespace,
-				TargetApp:                cfg.applicationName,
-				TargetAppNamespace:       cfg.applicationNamespace,
-				RepoServerAddress:        repoAddr,
-				RepoServerPlaintext:      cfg.repoServerPlaintext,
-				RepoServerStrictTLS:      cfg.repoServerStrictTLS,
-				RepoServerTimeoutSeconds: cfg.repoServerTimeoutSeconds,
+			if stopper, ok := backend.(analyzer.Stopper); ok {
+				defer stopper.Stop()
 			}
-			// Select backend.
-			var backend analyzer.Backend
-			switch cfg.strategy {
-			case "graph":
-				backend = graphbackend.NewBackend()
-			case "dynamic":
-				backend = dynamicbackend.NewBackend()
-			default:
-				return fmt.Errorf("invalid strategy: %s (must be 'graph' or 'dynamic')", cfg.strategy)
+			explainer, ok := backend.(analyzer.Explainer)
+			if !ok {
+				return fmt.Errorf("strategy %s does not support explain", cfg.strategy)
 			}
 
-			// Execute analysis.
-			groupedKinds, err := backend.Execute(context.Background(), opts)
-			if err != nil {
+			chains, err := explainer.Explain(context.Background(), opts, group, kind)
+			if errors.Is(err, analyzer.ErrIncompleteResult) {
+				log.WithError(err).Warn("Analysis did not complete in time, the chains below are partial")
+			} else if err != nil {
 				return err
 			}
-			printInclusions(groupedKinds)
+			if len(chains) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "No application leads to resources of kind %s\n", args[0])
+				return nil
+			}
+			for i := range chains {
+				fmt.Fprintln(cmd.OutOrStdout(), chains[i].String())
+			}
 			return nil
 		},
 	}
-	cmd.Flags().StringVar(&cfg.logLevel, "loglevel", env.GetStringVal("RESOURCE_TRACKER_LOGLEVEL", "info"), "set the loglevel to one of trace|debug|info|warn|error")
-	cmd.Flags().StringVarP(&cfg.applicationName, "app", "", "", "Application name (required for single app analysis). Supports 'namespace/name' syntax.")
-	cmd.Flags().StringVarP(&cfg.applicationNamespace, "app-namespace", "N", "argocd", "Application namespace")
-	cmd.Flags().StringVar(&cfg.repoServerAddress, "repo-server", env.GetStringVal("ARGOCD_REPO_SERVER", ""), "Repo server address. If empty, the CLI will port-forward to the repo-server service.")
-	cmd.Flags().BoolVar(&cfg.repoServerPlaintext, "repo-server-plaintext", false, "Use an unencrypted HTTP connection to the ArgoCD API instead of TLS.")
-	cmd.Flags().BoolVar(&cfg.repoServerStrictTLS, "repo-server-strict-tls", false, "Enable strict TLS validation for the repo server connection.")
-	cmd.Flags().IntVar(&cfg.repoServerTimeoutSeconds, "repo-server-timeout-seconds", 60, "Timeout in seconds for repo server RPC calls.")
-	cmd.Flags().StringVarP(&cfg.argocdNamespace, "namespace", "n", "argocd", "ArgoCD namespace")
-	cmd.Flags().StringVar(&cfg.kubeConfig, "kubeconfig", "", "Path to kubeconfig file for cluster access")
-	cmd.Flags().BoolVar(&cfg.allApps, "all-apps", false, "Analyze all applications in the namespace")
-	cmd.Flags().StringVar(&cfg.strategy, "strategy", "graph", "Analysis strategy: 'dynamic' (OwnerRef walking) or 'graph' (Cyphernetes)")
-	return cmd
 }
 
-func printInclusions(groupedKinds *common.GroupedResourceKinds) {
+// analyzeAppSet analyzes the applications of an ApplicationSet and prints the kinds they require
+// together and the kinds each of them requires alone.
+func analyzeAppSet(cmd *cobra.Command, backend analyzer.Backend, opts analyzer.Options, strategy string) error {
+	appSetAnalyzer, ok := backend.(analyzer.AppSetAnalyzer)
+	if !ok {
+		return fmt.Errorf("strategy %s does not support ApplicationSet analysis", strategy)
+	}
+	report, err := appSetAnalyzer.AnalyzeAppSet(context.Background(), opts)
+	if errors.Is(err, analyzer.ErrIncompleteResult) {
+		log.WithError(err).Warn("Analysis did not complete in time, the report below is partial")
+	} else if err != nil {
+		return err
+	}
+	fmt.Fprint(cmd.OutOrStdout(), report.String())
+	return nil
+}
+
+// checkProjects analyzes the applications, checks their kinds against their AppProjects, and
+// prints the resource inclusions of all the projects followed by the report of each project.
+func checkProjects(cmd *cobra.Command, backend analyzer.Backend, opts analyzer.Options, cfg *queryCLIConfig) error {
+	checker, ok := backend.(analyzer.ProjectChecker)
+	if !ok {
+		return fmt.Errorf("strategy %s does not support checking projects", cfg.strategy)
+	}
+	reports, err := checker.CheckProjects(context.Background(), opts)
+	if errors.Is(err, analyzer.ErrIncompleteResult) {
+		log.WithError(err).Warn("Analysis did not complete in time, the reports below are partial")
+	} else if err != nil {
+		return err
+	}
+	groupedKinds := make(common.GroupedResourceKinds)
+	for i := range reports {
+		groupedKinds.Merge(reports[i].Kinds)
+	}
+	printInclusions(&groupedKinds, backend, cfg)
+	for i := range reports {
+		fmt.Fprint(cmd.OutOrStdout(), reports[i].String())
+	}
+	return nil
+}
+
+// parseGroupKind splits a <group>/<kind> argument, a kind without group selecting the core group.
+func parseGroupKind(groupKind string) (string, string) {
+	i := strings.LastIndex(groupKind, "/")
+	if i < 0 {
+		return "", groupKind
+	}
+	return groupKind[:i], groupKind[i+1:]
+}
+
+// analyzerOptions sets up logging, and returns the analyzer options built from the command line.
+func (cfg *queryCLIConfig) analyzerOptions() (analyzer.Options, error) {
+	log.Infof("%s %s starting [loglevel:%s]",
+		version.BinaryName(),
+		version.Version(),
+		strings.ToUpper(cfg.logLevel),
+	)
+	level, err := log.ParseLevel(cfg.logLevel)
+	if err != nil {
+		return analyzer.Options{}, fmt.Errorf("failed to parse log level: %w", err)
+	}
+	log.SetLevel(level)
+	core.LogLevel = cfg.logLevel
+
+	// Require --app when --all-apps is false, to avoid silently analyzing all apps.
+	if !cfg.allApps && cfg.applicationName == "" && cfg.applicationSet == "" {
+		return analyzer.Options{}, fmt.Errorf("application name is required to analyze a single application")
+	}
+	if cfg.appSetPreview && cfg.applicationSet == "" {
+		return analyzer.Options{}, fmt.Errorf("--preview requires --appset")
+	}
+
+	// Support app specified as "namespace/name" similar to `argocd app get ns/name`.
+	// If provided, override the separate applicationNamespace flag.
+	if cfg.applicationName != "" && !cfg.allApps {
+		if parts := strings.SplitN(cfg.applicationName, "/", 2); len(parts) == 2 {
+			cfg.applicationNamespace = parts[0]
+			cfg.applicationName = parts[1]
+			log.WithFields(log.Fields{
+				"applicationName":      cfg.applicationName,
+				"applicationNamespace": cfg.applicationNamespace,
+			}).Debug("Parsed application from namespace/name syntax")
+		}
+	}
+
+	selection := argocd.ApplicationSelection{
+		LabelSelector: cfg.applicationSelector,
+		Projects:      cfg.applicationProjects,
+		Namespaces:    cfg.applicationNamespaces,
+	}
+	if err := selection.Validate(); err != nil {
+		return analyzer.Options{}, err
+	}
+
+	// Load the Kubernetes REST config (in-cluster or from kubeconfig path).
+	restCfg, err := kube.GetKubeConfig(cfg.kubeConfig)
+	if err != nil {
+		return analyzer.Options{}, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	// The status strategy never contacts the repo-server, so it is not port-forwarded.
+	repoAddr := cfg.repoServerAddress
+	if cfg.strategy != "status" {
+		if repoAddr, err = ensureRepoServerAddress(restCfg, cfg.argocdNamespace, cfg.repoServerAddress); err != nil && !cfg.localRender {
+			return analyzer.Options{}, err
+		} else if err != nil {
+			log.WithError(err).Warn("Rendering the manifests from local checkouts, as the repo-server could not be port-forwarded")
+		}
+	}
+	localCheckoutDir, err := cfg.checkoutDir()
+	if err != nil {
+		return analyzer.Options{}, err
+	}
+
+	return analyzer.Options{
+		KubeConfig:               restCfg,
+		KubeConfigPath:           cfg.kubeConfig,
+		ArgoCDNamespace:          cfg.argocdNamespace,
+		TargetApp:                cfg.applicationName,
+		TargetAppNamespace:       cfg.applicationNamespace,
+		AppSelection:             selection,
+		AppSet:                   cfg.applicationSet,
+		AppSetPreview:            cfg.appSetPreview,
+		AppOfAppsDepth:           cfg.appOfAppsDepth,
+		RepoServerAddress:        repoAddr,
+		RepoServerPlaintext:      cfg.repoServerPlaintext,
+		RepoServerStrictTLS:      cfg.repoServerStrictTLS,
+		RepoServerTimeoutSeconds: cfg.repoServerTimeoutSeconds,
+		ManifestCacheDir:         cfg.manifestCacheDir,
+		LocalCheckoutDir:         localCheckoutDir,
+		QueryTimeout:             cfg.queryTimeout,
+		TraversalTimeout:         cfg.traversalTimeout,
+		ClusterQPS:               cfg.clusterQPS,
+		ClusterBurst:             cfg.clusterBurst,
+		ScanWorkers:              cfg.scanWorkers,
+		AppConcurrency:           cfg.appConcurrency,
+	}, nil
+}
+
+// checkoutDir returns the directory of the local checkouts, or an empty string if the manifests
+// are not rendered locally.
+func (cfg *queryCLIConfig) checkoutDir() (string, error) {
+	if !cfg.localRender {
+		return "", nil
+	}
+	if cfg.localCheckoutDir != "" {
+		return cfg.localCheckoutDir, nil
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the directory of the local checkouts, set --local-checkout-dir: %w", err)
+	}
+	return filepath.Join(cacheDir, "resource-tracker", "checkouts"), nil
+}
+
+// newBackend returns the analysis backend of the given strategy.
+func newBackend(strategy string) (analyzer.Backend, error) {
+	switch strategy {
+	case "graph":
+		return graphbackend.NewBackend(), nil
+	case "dynamic":
+		return dynamicbackend.NewBackend(), nil
+	case "status":
+		return graphbackend.NewStatusBackend(), nil
+	default:
+		return nil, fmt.Errorf("invalid strategy: %s (must be 'graph', 'dynamic' or 'status')", strategy)
+	}
+}
+
+// printInclusions prints the resource inclusions, compacted with the kinds served by the
+// destination clusters the backend met if compaction is enabled.
+func printInclusions(groupedKinds *common.GroupedResourceKinds, backend analyzer.Backend, cfg *queryCLIConfig) {
 	resourceInclusionString := groupedKinds.String()
+	if cfg.compact {
+		if discoverer, ok := backend.(analyzer.KindsDiscoverer); !ok {
+			log.Warnf("Strategy %s cannot discover the kinds served by the clusters, the resource inclusions are not compacted", cfg.strategy)
+		} else if served, err := discoverer.ServedKinds(); err != nil {
+			log.WithError(err).Warn("Error discovering the kinds served by the clusters, the resource inclusions are not compacted")
+		} else {
+			resourceInclusionString = groupedKinds.CompactString(served, common.CompactionPolicy{MaxUnneededKinds: cfg.compactMaxUnneededKinds})
+		}
+	}
 	if strings.HasPrefix(resourceInclusionString, "error:") {
 		log.Errorf("error generating resource.inclusions: %s", resourceInclusionString)
 		return
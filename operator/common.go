@@ -7,17 +7,25 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/anandf/resource-tracker/pkg/analyzer"
 	"github.com/anandf/resource-tracker/pkg/argocd"
 	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/anandf/resource-tracker/pkg/env"
 	"github.com/anandf/resource-tracker/pkg/graph"
 	"github.com/anandf/resource-tracker/pkg/kube"
 	argocdcommon "github.com/argoproj/argo-cd/v3/common"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/avitaltamir/cyphernetes/pkg/core"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/rest"
@@ -28,12 +36,20 @@ const (
 	DefaultCheckInterval  = 5 * time.Minute
 	ConfigMapResourceKind = "ConfigMap"
 	ArgoCDResourceKind    = "ArgoCD"
+	DiffFormatText        = "text"
+	DiffFormatJSON        = "json"
 )
 
 type Executable interface {
 	execute() error
 }
 
+// Remediator is implemented by the executors which can include the kinds reported by new
+// ExcludedResourceWarning conditions right away, without waiting for the next full run.
+type Remediator interface {
+	remediate(app *v1alpha1.Application, resources []*common.ResourceInfo) error
+}
+
 type BaseControllerConfig struct {
 	checkInterval      time.Duration
 	logLevel           string
@@ -42,21 +58,48 @@ type BaseControllerConfig struct {
 	updateEnabled      *bool
 	updateResourceName string
 	updateResourceKind string
+	queryTimeout       time.Duration
+	traversalTimeout   time.Duration
+	// appSelection selects the applications whose resources are tracked
+	appSelection argocd.ApplicationSelection
+	// compact enables the compaction of the resource inclusions, with compactMaxUnneededKinds
+	compact                 bool
+	compactMaxUnneededKinds int
+	// diffFormat is the format of the changes to the resource inclusions logged before they are
+	// updated, DiffFormatText or DiffFormatJSON
+	diffFormat string
 }
 
 type BaseController struct {
 	dynamicClient        dynamic.Interface
 	restConfig           *rest.Config
 	previousGroupedKinds common.GroupedResourceKinds
-	queryServers         map[string]*graph.QueryServer
 	argoCDClient         argocd.ArgoCD
 	lastRunTime          time.Time
+	// compaction is the policy compacting the resource inclusions, nil if they are not compacted
+	compaction *common.CompactionPolicy
+	// kindsDiscoverer discovers the kinds served by the clusters the resource inclusions are
+	// compacted with
+	kindsDiscoverer analyzer.KindsDiscoverer
+	// servedKinds are the kinds last discovered by kindsDiscoverer, discovered again by the next
+	// run if servedKindsStale is true
+	servedKinds      common.GroupedResourceKinds
+	servedKindsStale bool
+	// remediatedKinds holds the kinds added from ExcludedResourceWarning conditions since the
+	// last full run
+	remediatedKinds common.GroupedResourceKinds
 }
 
 func newBaseController(cfg *BaseControllerConfig) (*BaseController, error) {
 	if cfg.updateResourceKind != ConfigMapResourceKind && cfg.updateResourceName != ArgoCDResourceKind {
 		return nil, fmt.Errorf("invalid update-resource-kind, valid values are ConfigMap and ArgoCD")
 	}
+	if cfg.diffFormat != DiffFormatText && cfg.diffFormat != DiffFormatJSON {
+		return nil, fmt.Errorf("invalid diff-format, valid values are %s and %s", DiffFormatText, DiffFormatJSON)
+	}
+	if err := cfg.appSelection.Validate(); err != nil {
+		return nil, err
+	}
 	restConfig, err := kube.GetKubeConfig(cfg.kubeConfig)
 	if err != nil {
 		return nil, err
@@ -66,12 +109,6 @@ func newBaseController(cfg *BaseControllerConfig) (*BaseController, error) {
 		return nil, err
 	}
 
-	queryServerMap := map[string]*graph.QueryServer{}
-	clusterConfigs, err := listClusterConfigs(dynamicClient, cfg.argocdNamespace)
-	if err != nil {
-		return nil, err
-	}
-	clusterConfigs = append(clusterConfigs, restConfig)
 	argoClient, err := argocd.NewArgoCD(
 		restConfig,
 		cfg.argocdNamespace,
@@ -80,14 +117,36 @@ func newBaseController(cfg *BaseControllerConfig) (*BaseController, error) {
 		10,
 		false,
 		false,
+		"",
+		"",
 	)
 	if err != nil {
 		return nil, err
 	}
-	trackingMethod, err := argoClient.GetTrackingMethod()
+	base := &BaseController{
+		dynamicClient: dynamicClient,
+		restConfig:    restConfig,
+		argoCDClient:  argoClient,
+	}
+	if cfg.compact {
+		base.compaction = &common.CompactionPolicy{MaxUnneededKinds: cfg.compactMaxUnneededKinds}
+	}
+	return base, nil
+}
+
+// newQueryServers returns a query server for the cluster of the control plane and for every
+// cluster of the Argo CD cluster secrets, by host.
+func (c *BaseController) newQueryServers(cfg *BaseControllerConfig) (map[string]*graph.QueryServer, error) {
+	clusterConfigs, err := listClusterConfigs(c.dynamicClient, cfg.argocdNamespace)
+	if err != nil {
+		return nil, err
+	}
+	clusterConfigs = append(clusterConfigs, c.restConfig)
+	trackingMethod, err := c.argoCDClient.GetTrackingMethod()
 	if err != nil {
 		return nil, err
 	}
+	queryServerMap := map[string]*graph.QueryServer{}
 	for _, clusterConfig := range clusterConfigs {
 		if len(clusterConfig.Host) == 0 {
 			continue
@@ -96,22 +155,199 @@ func newBaseController(cfg *BaseControllerConfig) (*BaseController, error) {
 		if err != nil {
 			return nil, err
 		}
+		queryServer.QueryTimeout = cfg.queryTimeout
+		queryServer.ControlPlaneNamespace = cfg.argocdNamespace
 		queryServerMap[clusterConfig.Host] = queryServer
 	}
-	return &BaseController{
-		dynamicClient: dynamicClient,
-		restConfig:    restConfig,
-		queryServers:  queryServerMap,
-		argoCDClient:  argoClient,
-	}, nil
+	return queryServerMap, nil
+}
+
+// inclusionsString returns the YAML output of the resource inclusions, compacted with the kinds
+// served by the clusters if compaction is enabled.
+func (c *BaseController) inclusionsString(groupedKinds common.GroupedResourceKinds) string {
+	if c.compaction == nil || c.kindsDiscoverer == nil {
+		return groupedKinds.String()
+	}
+	served := c.discoverServedKinds()
+	if served == nil {
+		return groupedKinds.String()
+	}
+	return groupedKinds.CompactString(served, *c.compaction)
+}
+
+// discoverServedKinds returns the kinds served by the clusters, discovered once per run. If the
+// discovery fails, the kinds discovered by a previous run are returned so that the resource
+// inclusions keep their compaction, or nil if none were discovered yet.
+func (c *BaseController) discoverServedKinds() common.GroupedResourceKinds {
+	if c.servedKinds != nil && !c.servedKindsStale {
+		return c.servedKinds
+	}
+	served, err := c.kindsDiscoverer.ServedKinds()
+	if err != nil {
+		c.servedKindsStale = false
+		if c.servedKinds == nil {
+			log.WithError(err).Warn("error discovering the served kinds, the resource inclusions are not compacted")
+		} else {
+			log.WithError(err).Warn("error discovering the served kinds, the resource inclusions are compacted with the kinds previously discovered")
+		}
+		return c.servedKinds
+	}
+	c.servedKinds = served
+	c.servedKindsStale = false
+	return served
+}
+
+// updateInclusions prints the given resource inclusions if they changed since the previous run,
+// or updates them in the target resource if updates are enabled. When only some applications are
+// selected, the given kinds are added to the resource inclusions of the target resource instead of
+// replacing them.
+func (c *BaseController) updateInclusions(cfg *BaseControllerConfig, groupedKinds common.GroupedResourceKinds) error {
+	// The kinds served by the clusters are discovered again for each run
+	c.servedKindsStale = true
+	if len(c.remediatedKinds) > 0 {
+		log.Infof("kinds added by the fast path since the last run:\n%s", c.remediatedKinds.String())
+		c.remediatedKinds = nil
+	}
+	if !*cfg.updateEnabled {
+		if !c.previousGroupedKinds.Equal(&groupedKinds) {
+			log.Info("direct update or argocd-cm is disabled, printing the output on terminal")
+			resourceInclusionString := c.inclusionsString(groupedKinds)
+			if strings.HasPrefix(resourceInclusionString, "error:") {
+				return fmt.Errorf("error in yaml string of resource.inclusions: %s", resourceInclusionString)
+			}
+			fmt.Printf("resource.inclusions: |\n%sresource.exclusions: ''\n", resourceInclusionString)
+		} else {
+			log.Infof("no changes detected in previously computed resource inclusions and current computed resource inclusions")
+		}
+		c.previousGroupedKinds = groupedKinds
+		return nil
+	}
+	if !cfg.appSelection.SelectsAll() {
+		// The applications which are not selected need the kinds included so far, the kinds of the
+		// selected ones are added to them
+		gvr, resourceName := cfg.updateTarget()
+		currentResourceInclusions, err := c.argoCDClient.GetCurrentResourceInclusions(gvr, resourceName, cfg.argocdNamespace)
+		if err != nil {
+			return err
+		}
+		current := make(common.GroupedResourceKinds)
+		if err := current.FromYaml(currentResourceInclusions); err != nil {
+			return err
+		}
+		if len(current) == 0 {
+			log.Infof("resource inclusions of %s/%s are empty, no kind is excluded by them", cfg.argocdNamespace, resourceName)
+			c.previousGroupedKinds = groupedKinds
+			return nil
+		}
+		groupedKinds = current.Union(groupedKinds)
+	}
+	if cfg.updateResourceKind == ArgoCDResourceKind {
+		if err := handleUpdateInArgoCDCR(c.argoCDClient, cfg.updateResourceName, cfg.argocdNamespace, c.inclusionsString(groupedKinds), cfg.diffFormat); err != nil {
+			return err
+		}
+	} else {
+		if err := handleUpdateInCM(c.argoCDClient, cfg.argocdNamespace, c.inclusionsString(groupedKinds), cfg.diffFormat); err != nil {
+			return err
+		}
+	}
+	c.previousGroupedKinds = groupedKinds
+	return nil
+}
+
+// remediateKinds adds the kinds of the resources reported by new ExcludedResourceWarning conditions
+// of the given application to the resource inclusions right away, regardless of the check interval
+// and without analyzing the applications. The application is failing to sync until its kinds are
+// included, the next full run keeps them as they are reported in its status.
+func (c *BaseController) remediateKinds(cfg *BaseControllerConfig, app *v1alpha1.Application, resources []*common.ResourceInfo) error {
+	appLogger := log.WithField("application", fmt.Sprintf("%s/%s", app.Namespace, app.Name))
+	var added common.GroupedResourceKinds
+	if !*cfg.updateEnabled {
+		if len(c.previousGroupedKinds) == 0 {
+			appLogger.Debug("no resource inclusions computed yet, the kinds excluded are added by the first run")
+			return nil
+		}
+		reported := make(common.GroupedResourceKinds)
+		reported.MergeResourceInfos(resources)
+		added = reported.Difference(c.previousGroupedKinds)
+		if len(added) > 0 {
+			c.previousGroupedKinds.Merge(added)
+			fmt.Printf("resource.inclusions: |\n%sresource.exclusions: ''\n", c.inclusionsString(c.previousGroupedKinds))
+		}
+	} else {
+		gvr, resourceName := cfg.updateTarget()
+		var err error
+		added, err = c.addResourceInclusions(gvr, resourceName, cfg.argocdNamespace, resources)
+		if err != nil {
+			return fmt.Errorf("error adding the kinds excluded for application %s/%s: %w", app.Namespace, app.Name, err)
+		}
+		if len(added) > 0 {
+			// The kinds added are recorded on the target resource for auditing, the next full run
+			// may drop them once the application no longer needs them
+			remediation := common.NewRemediation(fmt.Sprintf("%s/%s", app.Namespace, app.Name), added, time.Now())
+			if err := c.argoCDClient.RecordRemediation(gvr, resourceName, cfg.argocdNamespace, remediation); err != nil {
+				appLogger.WithError(err).Warnf("error recording the kinds added in the %s annotation", common.RemediatedKindsAnnotation)
+			}
+		}
+	}
+	if len(added) == 0 {
+		appLogger.Debug("kinds reported by ExcludedResourceWarning conditions are already included")
+		return nil
+	}
+	if c.remediatedKinds == nil {
+		c.remediatedKinds = make(common.GroupedResourceKinds)
+	}
+	c.remediatedKinds.Merge(added)
+	appLogger.Infof("fast path: added kinds reported by ExcludedResourceWarning conditions to resource inclusions:\n%s", added.String())
+	return nil
+}
+
+// addBaseFlags adds the flags of the base controller configuration to the given command.
+func addBaseFlags(cmd *cobra.Command, cfg *BaseControllerConfig) {
+	cmd.Flags().StringVar(&cfg.logLevel, "loglevel", env.GetStringVal("RESOURCE_TRACKER_LOGLEVEL", "info"), "set the loglevel to one of trace|debug|info|warn|error")
+	cmd.Flags().StringVar(&cfg.kubeConfig, "kubeconfig", "", "full path to kube client configuration, i.e. ~/.kube/config")
+	cmd.Flags().StringVar(&cfg.argocdNamespace, "argocd-namespace", "argocd", "namespace where argocd control plane components are running")
+	cmd.Flags().StringVarP(&cfg.appSelection.LabelSelector, "selector", "l", "", "label selector of the applications whose resources are tracked, e.g. 'team=payments'. "+
+		"With a selection, the kinds of the selected applications are added to the resource inclusions instead of replacing them")
+	cmd.Flags().StringSliceVar(&cfg.appSelection.Projects, "project", nil, "AppProjects of the applications whose resources are tracked, all projects if empty")
+	cmd.Flags().StringSliceVar(&cfg.appSelection.Namespaces, "app-namespaces", nil, "namespaces of the applications whose resources are tracked, the namespaces enabled by application.namespaces in argocd-cmd-params-cm if empty")
+	cfg.updateEnabled = cmd.Flags().Bool("update-enabled", false, "if enabled updates the argocd-cm directly, else prints the output on screen")
+	cmd.Flags().StringVar(&cfg.updateResourceName, "update-resource-name", "argocd-cm", "name of the resource that needs to be updated. Default: argocd-cm")
+	cmd.Flags().StringVar(&cfg.updateResourceKind, "update-resource-kind", "ConfigMap", "kind of resource that needs to be updated, "+
+		"users can choose to update either spec.data in argocd-cm or spec.extraConfigs in ArgoCD resource, Default: ConfigMap")
+	cmd.Flags().DurationVar(&cfg.checkInterval, "interval", DefaultCheckInterval, "interval for how often to check for updates, "+
+		"to avoid frequent execution of compute and memory intensive graph queries")
+	cmd.Flags().DurationVar(&cfg.traversalTimeout, "traversal-timeout", graph.DefaultTraversalTimeout, "total time budget for traversing the application children in a single run, "+
+		"partial results are not written to the target resource. 0 disables the budget")
+	cmd.Flags().BoolVar(&cfg.compact, "compact", false, "compact the resource inclusions, replacing the kinds of a group with '*' when every kind served in the group is needed "+
+		"and merging the groups which need the same kinds")
+	cmd.Flags().IntVar(&cfg.compactMaxUnneededKinds, "compact-max-unneeded-kinds", 0, "with --compact, number of kinds served in a group which are not needed but may be included by "+
+		"replacing the kinds of the group with '*', -1 only merges groups")
+	cmd.Flags().StringVar(&cfg.diffFormat, "diff-format", DiffFormatText, "format of the changes to the resource inclusions logged before they are updated, text or json")
+}
+
+// setLogLevel sets the log level of the logger and of the cyphernetes library.
+func (cfg *BaseControllerConfig) setLogLevel() error {
+	level, err := log.ParseLevel(cfg.logLevel)
+	if err != nil {
+		return fmt.Errorf("failed to parse log level: %w", err)
+	}
+	log.SetLevel(level)
+	core.LogLevel = cfg.logLevel
+	return nil
 }
 
 // initApplicationInformer initializes the shared informers for Argo CD Application objects.
-// whenever a change to any Argo Application is detected, the graph query is executed and the resource inclusion
+// whenever a change to any selected Argo Application is detected, the graph query is executed and the resource inclusion
 // entries are computed.
-func initApplicationInformer(dynamicClient dynamic.Interface, executor Executable) error {
-	// Create a dynamic shared informer factory
-	informerFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, 1*time.Minute, "", nil)
+func initApplicationInformer(dynamicClient dynamic.Interface, selection argocd.ApplicationSelection, executor Executable) error {
+	// Create a dynamic shared informer factory, the label selector is applied by the API server
+	namespace := metav1.NamespaceAll
+	if len(selection.Namespaces) == 1 {
+		namespace = selection.Namespaces[0]
+	}
+	informerFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, 1*time.Minute, namespace, func(options *metav1.ListOptions) {
+		options.LabelSelector = selection.LabelSelector
+	})
 
 	// Get the informer for the specified GVR
 	informer := informerFactory.ForResource(graph.ArgoAppGVR).Informer()
@@ -120,6 +356,9 @@ func initApplicationInformer(dynamicClient dynamic.Interface, executor Executabl
 	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			unstructuredObj := obj.(*unstructured.Unstructured)
+			if !isSelected(selection, unstructuredObj) {
+				return
+			}
 			log.Infof("Object Added: %s/%s", unstructuredObj.GetNamespace(), unstructuredObj.GetName())
 			if err := executor.execute(); err != nil {
 				log.Error(err)
@@ -128,15 +367,28 @@ func initApplicationInformer(dynamicClient dynamic.Interface, executor Executabl
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			oldUnstructured := oldObj.(*unstructured.Unstructured)
 			newUnstructured := newObj.(*unstructured.Unstructured)
+			// An application leaving the selection changes the result too
+			if !isSelected(selection, oldUnstructured) && !isSelected(selection, newUnstructured) {
+				return
+			}
 			log.Infof("Object Updated: %s/%s (ResourceVersion: %s -> %s)",
 				newUnstructured.GetNamespace(), newUnstructured.GetName(),
 				oldUnstructured.GetResourceVersion(), newUnstructured.GetResourceVersion())
+			if remediator, ok := executor.(Remediator); ok && isSelected(selection, newUnstructured) {
+				remediateExcludedResources(remediator, oldUnstructured, newUnstructured)
+			}
 			if err := executor.execute(); err != nil {
 				log.Error(err)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
-			unstructuredObj := obj.(*unstructured.Unstructured)
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			unstructuredObj, ok := obj.(*unstructured.Unstructured)
+			if !ok || !isSelected(selection, unstructuredObj) {
+				return
+			}
 			log.Infof("Object Deleted: %s/%s", unstructuredObj.GetNamespace(), unstructuredObj.GetName())
 			if err := executor.execute(); err != nil {
 				log.Error(err)
@@ -170,6 +422,54 @@ func initApplicationInformer(dynamicClient dynamic.Interface, executor Executabl
 	return nil
 }
 
+// remediateExcludedResources passes the resources reported by the ExcludedResourceWarning
+// conditions which appeared in the updated Application to the given remediator.
+func remediateExcludedResources(remediator Remediator, oldObj, newObj *unstructured.Unstructured) {
+	oldApp, err := toApplication(oldObj)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	newApp, err := toApplication(newObj)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	resources, err := argocd.NewExcludedResources(oldApp, newApp)
+	if err != nil {
+		log.Errorf("error getting excluded resources of application %s/%s: %v", newApp.Namespace, newApp.Name, err)
+		return
+	}
+	if len(resources) == 0 {
+		return
+	}
+	if err := remediator.remediate(newApp, resources); err != nil {
+		log.Error(err)
+	}
+}
+
+// isSelected returns true if the given Application object is selected.
+func isSelected(selection argocd.ApplicationSelection, obj *unstructured.Unstructured) bool {
+	if selection.SelectsAll() {
+		return true
+	}
+	app, err := toApplication(obj)
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+	return selection.Matches(app)
+}
+
+// toApplication converts the given Application object.
+func toApplication(obj *unstructured.Unstructured) (*v1alpha1.Application, error) {
+	var app v1alpha1.Application
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &app); err != nil {
+		return nil, fmt.Errorf("failed to convert object %s/%s to an Application: %w", obj.GetNamespace(), obj.GetName(), err)
+	}
+	return &app, nil
+}
+
 // Reads all the cluster credential secrets in the cluster and returns the kubeconfig instance
 func listClusterConfigs(dynamicClient dynamic.Interface, argocdNS string) ([]*rest.Config, error) {
 	log.Info("Listing Argo CD cluster secrets")
@@ -211,19 +511,22 @@ func listClusterConfigs(dynamicClient dynamic.Interface, argocdNS string) ([]*re
 }
 
 // handleUpdateInArgoCDCR handles the update of resource.inclusions settings in ArgoCD CustomResource
-func handleUpdateInArgoCDCR(argoCDClient argocd.ArgoCD, resourceName, resourceNamespace string, groupedKinds common.GroupedResourceKinds) error {
+func handleUpdateInArgoCDCR(argoCDClient argocd.ArgoCD, resourceName, resourceNamespace string, resourceInclusions string, diffFormat string) error {
 	currentResourceInclusions, err := argoCDClient.GetCurrentResourceInclusions(&graph.ArgoCDGVR, resourceName, resourceNamespace)
 	if err != nil {
 		return err
 	}
-	existingGroupKinds := make(common.GroupedResourceKinds)
-	err = existingGroupKinds.FromYaml(currentResourceInclusions)
+	diff, err := inclusionsDiff(currentResourceInclusions, resourceInclusions)
 	if err != nil {
 		return err
 	}
-	if !existingGroupKinds.Equal(&groupedKinds) {
-		log.Infof("changes detected in resource inclusions, updating the argocd-cm configmap")
-		err = argoCDClient.UpdateResourceInclusions(&graph.ArgoCDGVR, resourceName, resourceNamespace, groupedKinds.String())
+	if !diff.Empty() {
+		formatted, err := formatDiff(diff, diffFormat)
+		if err != nil {
+			return err
+		}
+		log.Infof("changes detected in resource inclusions, updating the argocd-cm configmap:\n%s", formatted)
+		err = argoCDClient.UpdateResourceInclusions(&graph.ArgoCDGVR, resourceName, resourceNamespace, resourceInclusions)
 		if err != nil {
 			return err
 		}
@@ -233,20 +536,138 @@ func handleUpdateInArgoCDCR(argoCDClient argocd.ArgoCD, resourceName, resourceNa
 	return nil
 }
 
+// inclusionsDiff returns the kinds added and removed by the computed resource.inclusions settings
+// from the current ones. Both settings are matched by the filter of Argo CD, so that a kind covered
+// by a wildcard is not reported, and a kind included for some clusters only is reported as added.
+// Empty current settings are replaced by the computed ones, all their kinds are added.
+func inclusionsDiff(currentResourceInclusions, resourceInclusions string) (*common.KindsDiff, error) {
+	existingGroupKinds := make(common.GroupedResourceKinds)
+	if err := existingGroupKinds.FromYaml(currentResourceInclusions); err != nil {
+		return nil, err
+	}
+	groupedKinds := make(common.GroupedResourceKinds)
+	if err := groupedKinds.FromYaml(resourceInclusions); err != nil {
+		return nil, err
+	}
+	var diff *common.KindsDiff
+	if len(existingGroupKinds) == 0 {
+		diff = existingGroupKinds.Diff(groupedKinds)
+	} else {
+		current, err := common.ParseResourceFilter(currentResourceInclusions, "")
+		if err != nil {
+			return nil, err
+		}
+		computed, err := common.ParseResourceFilter(resourceInclusions, "")
+		if err != nil {
+			return nil, err
+		}
+		// A kind included by a single side is not included by the other one, both sets differ
+		removed := notIncluded(computed, current, existingGroupKinds)
+		diff = removed.Diff(notIncluded(current, computed, groupedKinds))
+	}
+	diff.Base, diff.Other = "current resource.inclusions", "computed resource.inclusions"
+	return diff, nil
+}
+
+// notIncluded returns the given kinds which are included by other on every cluster, but not by
+// filter.
+func notIncluded(filter, other *common.ResourceFilter, groupedKinds common.GroupedResourceKinds) common.GroupedResourceKinds {
+	result := make(common.GroupedResourceKinds)
+	for group, kinds := range groupedKinds {
+		filterGroup := group
+		if filterGroup == "core" {
+			filterGroup = ""
+		}
+		for kind := range kinds {
+			if filter.Includes(filterGroup, kind, "") || !other.Includes(filterGroup, kind, "") {
+				continue
+			}
+			if result[group] == nil {
+				result[group] = make(common.Kinds)
+			}
+			result[group][kind] = common.Void{}
+		}
+	}
+	return result
+}
+
+// formatDiff returns the given changes to the resource inclusions as text or, if diffFormat is
+// DiffFormatJSON, as JSON.
+func formatDiff(diff *common.KindsDiff, diffFormat string) (string, error) {
+	if diffFormat != DiffFormatJSON {
+		return diff.String(), nil
+	}
+	out, err := diff.JSON()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// updateTarget returns the GroupVersionResource and name of the resource holding the
+// resource.inclusions settings.
+func (cfg *BaseControllerConfig) updateTarget() (*schema.GroupVersionResource, string) {
+	if cfg.updateResourceKind == ArgoCDResourceKind {
+		return &graph.ArgoCDGVR, cfg.updateResourceName
+	}
+	return &graph.ConfigMapGVR, "argocd-cm"
+}
+
+// addResourceInclusions adds the kinds of the given resources to the resource.inclusions settings
+// of the given resource, and returns the kinds which were not included yet. Empty
+// resource.inclusions include every kind, so they are left as is.
+func (c *BaseController) addResourceInclusions(gvr *schema.GroupVersionResource, resourceName, resourceNamespace string, resources []*common.ResourceInfo) (common.GroupedResourceKinds, error) {
+	currentResourceInclusions, err := c.argoCDClient.GetCurrentResourceInclusions(gvr, resourceName, resourceNamespace)
+	if err != nil {
+		return nil, err
+	}
+	groupedKinds := make(common.GroupedResourceKinds)
+	if err := groupedKinds.FromYaml(currentResourceInclusions); err != nil {
+		return nil, err
+	}
+	if len(groupedKinds) == 0 {
+		log.Infof("resource inclusions of %s/%s are empty, no kind is excluded by them", resourceNamespace, resourceName)
+		return nil, nil
+	}
+	filter, err := common.ParseResourceFilter(currentResourceInclusions, "")
+	if err != nil {
+		return nil, err
+	}
+	// The kinds Argo CD always excludes are not watched whatever the resource inclusions
+	defaults := &common.ResourceFilter{}
+	added := make(common.GroupedResourceKinds)
+	for _, resource := range resources {
+		if !filter.Includes(resource.Group, resource.Kind, "") && defaults.Includes(resource.Group, resource.Kind, "") {
+			added.MergeResourceInfos([]*common.ResourceInfo{resource})
+		}
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+	groupedKinds.Merge(added)
+	if err := c.argoCDClient.UpdateResourceInclusions(gvr, resourceName, resourceNamespace, c.inclusionsString(groupedKinds)); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
 // handleUpdateInCM handles the update of resource.inclusions settings in argocd-cm ConfigMap
-func handleUpdateInCM(argoCDClient argocd.ArgoCD, resourceNamespace string, groupedKinds common.GroupedResourceKinds) error {
+func handleUpdateInCM(argoCDClient argocd.ArgoCD, resourceNamespace string, resourceInclusions string, diffFormat string) error {
 	currentResourceInclusions, err := argoCDClient.GetCurrentResourceInclusions(&graph.ConfigMapGVR, "argocd-cm", resourceNamespace)
 	if err != nil {
 		return err
 	}
-	existingGroupKinds := make(common.GroupedResourceKinds)
-	err = existingGroupKinds.FromYaml(currentResourceInclusions)
+	diff, err := inclusionsDiff(currentResourceInclusions, resourceInclusions)
 	if err != nil {
 		return err
 	}
-	if !existingGroupKinds.Equal(&groupedKinds) {
-		log.Infof("changes detected in resource inclusions, updating the argocd-cm configmap")
-		err = argoCDClient.UpdateResourceInclusions(&graph.ConfigMapGVR, "argocd-cm", resourceNamespace, groupedKinds.String())
+	if !diff.Empty() {
+		formatted, err := formatDiff(diff, diffFormat)
+		if err != nil {
+			return err
+		}
+		log.Infof("changes detected in resource inclusions, updating the argocd-cm configmap:\n%s", formatted)
+		err = argoCDClient.UpdateResourceInclusions(&graph.ConfigMapGVR, "argocd-cm", resourceNamespace, resourceInclusions)
 		if err != nil {
 			return err
 		}
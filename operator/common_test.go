@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKindsDiscoverer returns the given served kinds, or fails with err, and counts the discoveries.
+type fakeKindsDiscoverer struct {
+	served      common.GroupedResourceKinds
+	err         error
+	discoveries int
+}
+
+func (f *fakeKindsDiscoverer) ServedKinds() (common.GroupedResourceKinds, error) {
+	f.discoveries++
+	return f.served, f.err
+}
+
+func Test_inclusionsString(t *testing.T) {
+	needed := common.GroupedResourceKinds{"apps": {"Deployment": {}, "StatefulSet": {}}}
+	served := common.GroupedResourceKinds{"apps": {"Deployment": {}, "StatefulSet": {}}}
+	compacted := needed.CompactString(served, common.CompactionPolicy{})
+	discoverer := &fakeKindsDiscoverer{served: served}
+	controller := &BaseController{compaction: &common.CompactionPolicy{}, kindsDiscoverer: discoverer}
+
+	// The served kinds are discovered once per run
+	assert.Equal(t, compacted, controller.inclusionsString(needed))
+	assert.Equal(t, compacted, controller.inclusionsString(needed))
+	assert.Equal(t, 1, discoverer.discoveries)
+
+	// A failed discovery keeps the compaction of the previous run
+	controller.servedKindsStale = true
+	discoverer.err = errors.New("discovery failed")
+	assert.Equal(t, compacted, controller.inclusionsString(needed))
+	assert.Equal(t, compacted, controller.inclusionsString(needed))
+	assert.Equal(t, 2, discoverer.discoveries)
+
+	// Without kinds discovered yet, the resource inclusions are not compacted
+	controller = &BaseController{compaction: &common.CompactionPolicy{}, kindsDiscoverer: discoverer}
+	assert.Equal(t, needed.String(), controller.inclusionsString(needed))
+}
+
+func Test_formatDiff(t *testing.T) {
+	current := common.GroupedResourceKinds{"apps": {"Deployment": {}}}
+	diff := current.Diff(common.GroupedResourceKinds{"core": {"ConfigMap": {}}})
+
+	text, err := formatDiff(diff, DiffFormatText)
+	require.NoError(t, err)
+	assert.Equal(t, diff.String(), text)
+
+	out, err := formatDiff(diff, DiffFormatJSON)
+	require.NoError(t, err)
+	var decoded common.KindsDiff
+	require.NoError(t, json.Unmarshal([]byte(out), &decoded))
+	assert.Equal(t, []common.KindChange{{Group: "", Kind: "ConfigMap", Side: common.DiffSideOther}}, decoded.Added)
+	assert.Equal(t, []common.KindChange{{Group: "apps", Kind: "Deployment", Side: common.DiffSideBase}}, decoded.Removed)
+}
+
+func Test_inclusionsDiff(t *testing.T) {
+	computed := `- apiGroups:
+  - apps
+  kinds:
+  - Deployment
+- apiGroups:
+  - ""
+  kinds:
+  - ConfigMap
+`
+	// The Deployments are covered by the wildcard, which is not kept by the computed settings. The
+	// ConfigMaps are only included on a remote cluster and the Services are not included anymore.
+	current := `- apiGroups:
+  - apps
+  kinds:
+  - '*'
+- apiGroups:
+  - ""
+  kinds:
+  - ConfigMap
+  clusters:
+  - https://remote.cluster
+- apiGroups:
+  - ""
+  kinds:
+  - Service
+`
+	diff, err := inclusionsDiff(current, computed)
+	require.NoError(t, err)
+	assert.Equal(t, []common.KindChange{{Group: "", Kind: "ConfigMap", Side: common.DiffSideOther}}, diff.Added)
+	assert.Equal(t, []common.KindChange{
+		{Group: "", Kind: "Service", Side: common.DiffSideBase},
+		{Group: "apps", Kind: "*", Side: common.DiffSideBase},
+	}, diff.Removed)
+
+	diff, err = inclusionsDiff(computed, computed)
+	require.NoError(t, err)
+	assert.True(t, diff.Empty())
+
+	// Empty resource inclusions are replaced by the computed ones
+	diff, err = inclusionsDiff("", computed)
+	require.NoError(t, err)
+	assert.Len(t, diff.Added, 2)
+	assert.Empty(t, diff.Removed)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anandf/resource-tracker/pkg/analyzer"
+	dynamicbackend "github.com/anandf/resource-tracker/pkg/analyzer/dynamic"
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/anandf/resource-tracker/pkg/dynamic"
+	"github.com/anandf/resource-tracker/pkg/version"
+	argocdcommon "github.com/argoproj/argo-cd/v3/common"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type DynamicControllerConfig struct {
+	BaseControllerConfig
+	// watchRelations keeps the relations current from metadata watches, and runs the analysis
+	// when a relation appears or disappears
+	watchRelations bool
+	// relationTTL is the time after which a scanned relation which was not seen again is evicted
+	relationTTL time.Duration
+}
+
+// DynamicController computes the resource inclusions with the dynamic backend, which keeps the
+// relations found on the destination clusters across runs.
+type DynamicController struct {
+	*BaseController
+	cfg     *DynamicControllerConfig
+	backend *dynamicbackend.Backend
+	// mu serializes the runs triggered by the application informer and by relation changes
+	mu sync.Mutex
+	// relationChanges holds a pending run triggered by relation changes
+	relationChanges chan struct{}
+}
+
+// newDynamicCommand implements the "run-dynamic" command which follows the owner references
+// and the relations found on the destination clusters.
+func newDynamicCommand() *cobra.Command {
+	cfg := &DynamicControllerConfig{}
+	var runDynamicCmd = &cobra.Command{
+		Use:   "run-dynamic",
+		Short: "Runs the resource-tracker which follows the owner references and relations found on the destination clusters",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Infof("%s %s starting [loglevel:%s, interval:%s, watchRelations:%t]",
+				fmt.Sprintf("%s-%s", version.BinaryName(), "operator"),
+				version.Version(),
+				strings.ToUpper(cfg.logLevel),
+				cfg.checkInterval,
+				cfg.watchRelations,
+			)
+			if err := cfg.setLogLevel(); err != nil {
+				return err
+			}
+			controller, err := newDynamicController(cfg)
+			if err != nil {
+				return err
+			}
+			defer controller.backend.Stop()
+			if cfg.watchRelations {
+				go controller.runOnRelationChanges()
+			}
+			return initApplicationInformer(controller.dynamicClient, cfg.appSelection, controller)
+		},
+	}
+	addBaseFlags(runDynamicCmd, &cfg.BaseControllerConfig)
+	runDynamicCmd.Flags().BoolVar(&cfg.watchRelations, "watch-relations", false, "keep the relations current from metadata watches on the destination clusters instead of full scans, "+
+		"and compute the resource inclusions again as soon as a relation appears or disappears")
+	runDynamicCmd.Flags().DurationVar(&cfg.relationTTL, "relation-ttl", 0, "evict the relations found by scanning the destination clusters that were not seen again within this duration, "+
+		"0 keeps them forever")
+	return runDynamicCmd
+}
+
+func newDynamicController(cfg *DynamicControllerConfig) (*DynamicController, error) {
+	base, err := newBaseController(&cfg.BaseControllerConfig)
+	if err != nil {
+		return nil, err
+	}
+	backend := dynamicbackend.NewBackend()
+	base.kindsDiscoverer = backend
+	d := &DynamicController{
+		BaseController:  base,
+		cfg:             cfg,
+		backend:         backend,
+		relationChanges: make(chan struct{}, 1),
+	}
+	backend.OnRelationChange(d.relationChanged)
+	return d, nil
+}
+
+// relationChanged queues a run for a relation which appeared or disappeared. Changes arriving
+// while a run is already queued are folded into it.
+func (d *DynamicController) relationChanged(change dynamic.RelationChange) {
+	log.WithFields(log.Fields{
+		"cluster": change.Cluster,
+		"parent":  change.Parent,
+		"child":   change.Child,
+		"added":   change.Added,
+	}).Info("relation changed, computing the resource inclusions again")
+	select {
+	case d.relationChanges <- struct{}{}:
+	default:
+	}
+}
+
+// runOnRelationChanges runs the analysis for every queued relation change, regardless of the
+// check interval.
+func (d *DynamicController) runOnRelationChanges() {
+	for range d.relationChanges {
+		if err := d.run(true); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// options returns the analyzer options of the selected applications.
+func (d *DynamicController) options() analyzer.Options {
+	return analyzer.Options{
+		KubeConfig:               d.restConfig,
+		KubeConfigPath:           d.cfg.kubeConfig,
+		ArgoCDNamespace:          d.cfg.argocdNamespace,
+		AppSelection:             d.cfg.appSelection,
+		RepoServerAddress:        argocdcommon.DefaultRepoServerAddr,
+		RepoServerTimeoutSeconds: 10,
+		WatchRelations:           d.cfg.watchRelations,
+		RelationTTL:              d.cfg.relationTTL,
+	}
+}
+
+// remediate adds the kinds reported by new ExcludedResourceWarning conditions of the given
+// application to the resource inclusions right away, between the runs.
+func (d *DynamicController) remediate(app *v1alpha1.Application, resources []*common.ResourceInfo) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.remediateKinds(&d.cfg.BaseControllerConfig, app, resources)
+}
+
+// execute runs the dynamic analysis of the selected applications and updates the
+// resource.inclusions settings like GraphQueryController.execute.
+func (d *DynamicController) execute() error {
+	return d.run(false)
+}
+
+// run runs the dynamic analysis, before the check interval has passed since the previous run only
+// if force is true. A partial result is discarded and the next run is not delayed.
+func (d *DynamicController) run(force bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !force && !d.lastRunTime.IsZero() && time.Since(d.lastRunTime) < d.cfg.checkInterval {
+		log.Info("skipping dynamic analysis due to last run not lapsed the check interval")
+		return nil
+	}
+	ctx := context.Background()
+	if d.cfg.traversalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.cfg.traversalTimeout)
+		defer cancel()
+	}
+	d.lastRunTime = time.Now()
+	groupedKinds, err := d.backend.Execute(ctx, d.options())
+	if errors.Is(err, analyzer.ErrIncompleteResult) {
+		log.Warnf("skipping update as the analysis of the Argo CD applications is incomplete: %v", err)
+		d.lastRunTime = time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return d.updateInclusions(&d.cfg.BaseControllerConfig, *groupedKinds)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/anandf/resource-tracker/pkg/argocd"
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/anandf/resource-tracker/pkg/dynamic"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_relationChanged(t *testing.T) {
+	d := &DynamicController{relationChanges: make(chan struct{}, 1)}
+	d.relationChanged(dynamic.RelationChange{Cluster: "https://kubernetes.default.svc", Parent: "apps/Deployment", Child: "apps/ReplicaSet", Added: true})
+	// A change arriving while a run is queued does not block and is folded into it
+	d.relationChanged(dynamic.RelationChange{Cluster: "https://kubernetes.default.svc", Parent: "apps/ReplicaSet", Child: "core/Pod", Added: true})
+	assert.Len(t, d.relationChanges, 1)
+	<-d.relationChanges
+	assert.Len(t, d.relationChanges, 0)
+}
+
+func Test_DynamicController_remediate(t *testing.T) {
+	current := common.GroupedResourceKinds{"apps": {"Deployment": {}}}
+	argoCDClient := &fakeArgoCD{inclusions: current.String()}
+	updateEnabled := true
+	d := &DynamicController{
+		BaseController: &BaseController{argoCDClient: argoCDClient},
+		cfg: &DynamicControllerConfig{BaseControllerConfig: BaseControllerConfig{
+			argocdNamespace:    "argocd",
+			updateEnabled:      &updateEnabled,
+			updateResourceKind: ConfigMapResourceKind,
+		}},
+	}
+	oldApp := &v1alpha1.Application{}
+	oldApp.Namespace, oldApp.Name = "argocd", "guestbook"
+	newApp := oldApp.DeepCopy()
+	newApp.Status.Conditions = []v1alpha1.ApplicationCondition{
+		{Type: argocd.ConditionTypeExcludedResourceWarning, Message: "Resource example.com/Widget widget is excluded in the settings"},
+	}
+	toUnstructured := func(app *v1alpha1.Application) *unstructured.Unstructured {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(app)
+		require.NoError(t, err)
+		return &unstructured.Unstructured{Object: obj}
+	}
+
+	// The run-dynamic controller takes the fast path of the application informer
+	remediateExcludedResources(d, toUnstructured(oldApp), toUnstructured(newApp))
+	assert.Equal(t, 1, argoCDClient.updates)
+	updated := make(common.GroupedResourceKinds)
+	require.NoError(t, updated.FromYaml(argoCDClient.inclusions))
+	assert.Equal(t, common.GroupedResourceKinds{"apps": {"Deployment": {}}, "example.com": {"Widget": {}}}, updated)
+	require.Len(t, argoCDClient.remediations, 1)
+	assert.Equal(t, "argocd/guestbook", argoCDClient.remediations[0].Application)
+	assert.Equal(t, common.GroupedResourceKinds{"example.com": {"Widget": {}}}, d.remediatedKinds)
+}
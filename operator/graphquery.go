@@ -1,14 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/anandf/resource-tracker/pkg/common"
-	"github.com/anandf/resource-tracker/pkg/env"
+	"github.com/anandf/resource-tracker/pkg/graph"
 	"github.com/anandf/resource-tracker/pkg/version"
-	"github.com/avitaltamir/cyphernetes/pkg/core"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -19,7 +21,8 @@ type GraphQueryControllerConfig struct {
 
 type GraphQueryController struct {
 	*BaseController
-	cfg *GraphQueryControllerConfig
+	cfg          *GraphQueryControllerConfig
+	queryServers map[string]*graph.QueryServer
 }
 
 // newGraphQueryCommand implements "runQuery" command which executes a cyphernetes graph query against a given kubeconfig
@@ -35,29 +38,18 @@ func newGraphQueryCommand() *cobra.Command {
 				strings.ToUpper(cfg.logLevel),
 				cfg.checkInterval,
 			)
-			var err error
-			level, err := log.ParseLevel(cfg.logLevel)
-			if err != nil {
-				return fmt.Errorf("failed to parse log level: %w", err)
+			if err := cfg.setLogLevel(); err != nil {
+				return err
 			}
-			log.SetLevel(level)
-			core.LogLevel = cfg.logLevel
 			controller, err := newGraphQueryController(cfg)
 			if err != nil {
 				return err
 			}
-			return initApplicationInformer(controller.dynamicClient, controller)
+			return initApplicationInformer(controller.dynamicClient, cfg.appSelection, controller)
 		},
 	}
-	runQueryCmd.Flags().StringVar(&cfg.logLevel, "loglevel", env.GetStringVal("RESOURCE_TRACKER_LOGLEVEL", "info"), "set the loglevel to one of trace|debug|info|warn|error")
-	runQueryCmd.Flags().StringVar(&cfg.kubeConfig, "kubeconfig", "", "full path to kube client configuration, i.e. ~/.kube/config")
-	runQueryCmd.Flags().StringVar(&cfg.argocdNamespace, "argocd-namespace", "argocd", "namespace where argocd control plane components are running")
-	cfg.updateEnabled = runQueryCmd.Flags().Bool("update-enabled", false, "if enabled updates the argocd-cm directly, else prints the output on screen")
-	runQueryCmd.Flags().StringVar(&cfg.updateResourceName, "update-resource-name", "argocd-cm", "name of the resource that needs to be updated. Default: argocd-cm")
-	runQueryCmd.Flags().StringVar(&cfg.updateResourceKind, "update-resource-kind", "ConfigMap", "kind of resource that needs to be updated, "+
-		"users can choose to update either spec.data in argocd-cm or spec.extraConfigs in ArgoCD resource, Default: ConfigMap")
-	runQueryCmd.Flags().DurationVar(&cfg.checkInterval, "interval", DefaultCheckInterval, "interval for how often to check for updates, "+
-		"to avoid frequent execution of compute and memory intensive graph queries")
+	addBaseFlags(runQueryCmd, &cfg.BaseControllerConfig)
+	runQueryCmd.Flags().DurationVar(&cfg.queryTimeout, "query-timeout", graph.DefaultQueryTimeout, "timeout for a single graph query, 0 disables the timeout")
 	return runQueryCmd
 }
 
@@ -66,39 +58,99 @@ func newGraphQueryController(cfg *GraphQueryControllerConfig) (*GraphQueryContro
 	if err != nil {
 		return nil, err
 	}
+	queryServers, err := base.newQueryServers(&cfg.BaseControllerConfig)
+	if err != nil {
+		return nil, err
+	}
+	base.kindsDiscoverer = queryServerKinds(queryServers)
 	return &GraphQueryController{
 		BaseController: base,
 		cfg:            cfg,
+		queryServers:   queryServers,
 	}, nil
 }
 
+// queryServerKinds discovers the kinds served by the clusters of the query servers.
+type queryServerKinds map[string]*graph.QueryServer
+
+// ServedKinds returns the kinds served by the clusters of the query servers, by group.
+func (q queryServerKinds) ServedKinds() (common.GroupedResourceKinds, error) {
+	served := make(common.GroupedResourceKinds)
+	for host, qs := range q {
+		kinds, err := qs.ServedKinds()
+		if err != nil {
+			return nil, fmt.Errorf("error discovering the kinds served in host %s: %w", host, err)
+		}
+		served.Merge(kinds)
+	}
+	return served, nil
+}
+
+// remediate adds the kinds reported by new ExcludedResourceWarning conditions of the given
+// application to the resource inclusions right away.
+func (g *GraphQueryController) remediate(app *v1alpha1.Application, resources []*common.ResourceInfo) error {
+	return g.remediateKinds(&g.cfg.BaseControllerConfig, app, resources)
+}
+
 // execute runs the graph query, computes the resources managed via Argo CD and update the resource.inclusions
 // settings in the argocd-cm config map if it detects any new changes compared to the previous computed value or if its
 // value is different from what is present in the argocd-cm config map.
 // if the check interval time has not passed since the previous run, then the method returns without executing any queries.
+// if the traversal does not complete within the traversal timeout, the partial result is discarded and the next run
+// is not delayed by the check interval.
 func (g *GraphQueryController) execute() error {
 	if !g.lastRunTime.IsZero() && time.Since(g.lastRunTime) < g.cfg.checkInterval {
 		log.Info("skipping query executor due to last run not lapsed the check interval")
 		return nil
 	}
+	ctx := context.Background()
+	if g.cfg.traversalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.cfg.traversalTimeout)
+		defer cancel()
+	}
 	var allAppChildren []*common.ResourceInfo
 	g.lastRunTime = time.Now()
-	for host, qs := range g.queryServers {
-		log.Infof("Querying Argo CD application globally for application in host %s", host)
-		qs.VisitedKinds = make(map[common.ResourceInfo]bool)
-		appChildren, err := qs.GetApplicationChildResources("", "")
+	// Without selection all the applications are queried at once, otherwise each selected application is queried
+	selectedApps := []v1alpha1.Application{{}}
+	if !g.cfg.appSelection.SelectsAll() {
+		var err error
+		selectedApps, err = g.argoCDClient.SelectApplications(ctx, g.cfg.appSelection)
 		if err != nil {
 			return err
 		}
-		log.Infof("Children of Argo CD application globally for application: %v", appChildren)
-		for appChild := range appChildren {
-			allAppChildren = append(allAppChildren, &appChild)
+		log.Infof("Found %d selected applications", len(selectedApps))
+		if len(selectedApps) == 0 {
+			log.Info("no application is selected, the resource inclusions are left as they are")
+			return nil
+		}
+	}
+	for host, qs := range g.queryServers {
+		log.Infof("Querying Argo CD application globally for application in host %s", host)
+		// The provenance of the nodes is only needed for the traversals of a run
+		qs.Provenance = make(map[common.ResourceInfo]common.Provenance)
+		for _, app := range selectedApps {
+			// Kinds visited for an application are visited again for the next one
+			qs.VisitedKinds = make(map[common.ResourceInfo]bool)
+			appChildren, err := qs.GetApplicationChildResources(ctx, app.Name, app.Namespace)
+			if errors.Is(err, graph.ErrTraversalIncomplete) {
+				log.Warnf("skipping update as the children of Argo CD applications in host %s are incomplete: %v", host, err)
+				g.lastRunTime = time.Time{}
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			log.Infof("Children of Argo CD application globally for application: %v", appChildren)
+			for appChild := range appChildren {
+				allAppChildren = append(allAppChildren, &appChild)
+			}
 		}
 	}
 
 	groupedKinds := make(common.GroupedResourceKinds)
 	groupedKinds.MergeResourceInfos(allAppChildren)
-	missingResources, err := g.argoCDClient.GetAllMissingResources()
+	missingResources, err := g.argoCDClient.GetAllMissingResources(g.cfg.appSelection)
 	if err != nil {
 		return err
 	}
@@ -111,30 +163,5 @@ func (g *GraphQueryController) execute() error {
 			groupedKinds[resource.Group][resource.Kind] = common.Void{}
 		}
 	}
-	if !*g.cfg.updateEnabled {
-		if !g.previousGroupedKinds.Equal(&groupedKinds) {
-			log.Info("direct update or argocd-cm is disabled, printing the output on terminal")
-			resourceInclusionString := groupedKinds.String()
-			if strings.HasPrefix(resourceInclusionString, "error:") {
-				return fmt.Errorf("error in yaml string of resource.inclusions: %s", resourceInclusionString)
-			}
-			fmt.Printf("resource.inclusions: |\n%sresource.exclusions: ''\n", resourceInclusionString)
-		} else {
-			log.Infof("no changes detected in previously computed resource inclusions and current computed resource inclusions")
-		}
-	} else {
-		if g.cfg.updateResourceKind == ArgoCDResourceKind {
-			err = handleUpdateInArgoCDCR(g.argoCDClient, g.cfg.updateResourceName, g.cfg.argocdNamespace, groupedKinds)
-			if err != nil {
-				return err
-			}
-		} else {
-			err = handleUpdateInCM(g.argoCDClient, g.cfg.argocdNamespace, groupedKinds)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	g.previousGroupedKinds = groupedKinds
-	return nil
+	return g.updateInclusions(&g.cfg.BaseControllerConfig, groupedKinds)
 }
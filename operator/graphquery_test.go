@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anandf/resource-tracker/pkg/argocd"
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/anandf/resource-tracker/pkg/graph"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeArgoCD selects the given applications, reports the given missing resources, and holds the
+// resource inclusions of argocd-cm.
+type fakeArgoCD struct {
+	argocd.ArgoCD
+	apps         []v1alpha1.Application
+	missing      []*common.ResourceInfo
+	inclusions   string
+	updates      int
+	remediations []common.Remediation
+}
+
+func (f *fakeArgoCD) SelectApplications(ctx context.Context, selection argocd.ApplicationSelection) ([]v1alpha1.Application, error) {
+	return f.apps, nil
+}
+
+func (f *fakeArgoCD) GetAllMissingResources(selection argocd.ApplicationSelection) ([]*common.ResourceInfo, error) {
+	return f.missing, nil
+}
+
+func (f *fakeArgoCD) GetCurrentResourceInclusions(gvr *schema.GroupVersionResource, resourceName, resourceNamespace string) (string, error) {
+	return f.inclusions, nil
+}
+
+func (f *fakeArgoCD) UpdateResourceInclusions(gvr *schema.GroupVersionResource, resourceName, resourceNamespace, resourceInclusionYaml string) error {
+	f.inclusions = resourceInclusionYaml
+	f.updates++
+	return nil
+}
+
+func (f *fakeArgoCD) RecordRemediation(gvr *schema.GroupVersionResource, resourceName, resourceNamespace string, remediation common.Remediation) error {
+	f.remediations = append(f.remediations, remediation)
+	return nil
+}
+
+func newTestGraphQueryController(argoCDClient argocd.ArgoCD, selection argocd.ApplicationSelection) *GraphQueryController {
+	updateEnabled := true
+	return &GraphQueryController{
+		BaseController: &BaseController{argoCDClient: argoCDClient},
+		cfg: &GraphQueryControllerConfig{BaseControllerConfig: BaseControllerConfig{
+			argocdNamespace:    "argocd",
+			updateEnabled:      &updateEnabled,
+			updateResourceKind: ConfigMapResourceKind,
+			appSelection:       selection,
+		}},
+		queryServers: map[string]*graph.QueryServer{},
+	}
+}
+
+func Test_execute(t *testing.T) {
+	current := common.GroupedResourceKinds{"apps": {"Deployment": {}}, "core": {"ConfigMap": {}}}
+	selection := argocd.ApplicationSelection{LabelSelector: "team=payments"}
+
+	t.Run("kinds of the selected applications are added to the resource inclusions", func(t *testing.T) {
+		argoCDClient := &fakeArgoCD{
+			apps:       []v1alpha1.Application{{}},
+			missing:    []*common.ResourceInfo{{Kind: "Widget", Group: "example.com"}},
+			inclusions: current.String(),
+		}
+		require.NoError(t, newTestGraphQueryController(argoCDClient, selection).execute())
+
+		updated := make(common.GroupedResourceKinds)
+		require.NoError(t, updated.FromYaml(argoCDClient.inclusions))
+		assert.Equal(t, common.GroupedResourceKinds{
+			"apps":        {"Deployment": {}},
+			"core":        {"ConfigMap": {}},
+			"example.com": {"Widget": {}},
+		}, updated)
+	})
+
+	t.Run("resource inclusions are left as they are without selected applications", func(t *testing.T) {
+		argoCDClient := &fakeArgoCD{
+			missing:    []*common.ResourceInfo{{Kind: "Widget", Group: "example.com"}},
+			inclusions: current.String(),
+		}
+		require.NoError(t, newTestGraphQueryController(argoCDClient, selection).execute())
+		assert.Zero(t, argoCDClient.updates)
+		assert.Equal(t, current.String(), argoCDClient.inclusions)
+	})
+
+	t.Run("empty resource inclusions are left as they are", func(t *testing.T) {
+		argoCDClient := &fakeArgoCD{
+			apps:    []v1alpha1.Application{{}},
+			missing: []*common.ResourceInfo{{Kind: "Widget", Group: "example.com"}},
+		}
+		require.NoError(t, newTestGraphQueryController(argoCDClient, selection).execute())
+		assert.Zero(t, argoCDClient.updates)
+	})
+
+	t.Run("resource inclusions are replaced without selection", func(t *testing.T) {
+		argoCDClient := &fakeArgoCD{
+			missing:    []*common.ResourceInfo{{Kind: "Widget", Group: "example.com"}},
+			inclusions: current.String(),
+		}
+		require.NoError(t, newTestGraphQueryController(argoCDClient, argocd.ApplicationSelection{}).execute())
+
+		updated := make(common.GroupedResourceKinds)
+		require.NoError(t, updated.FromYaml(argoCDClient.inclusions))
+		assert.Equal(t, common.GroupedResourceKinds{"example.com": {"Widget": {}}}, updated)
+	})
+}
+
+func Test_remediate(t *testing.T) {
+	current := common.GroupedResourceKinds{"apps": {"Deployment": {}}}
+	app := &v1alpha1.Application{}
+	app.Namespace, app.Name = "argocd", "guestbook"
+
+	argoCDClient := &fakeArgoCD{inclusions: current.String()}
+	controller := newTestGraphQueryController(argoCDClient, argocd.ApplicationSelection{})
+	require.NoError(t, controller.remediate(app, []*common.ResourceInfo{
+		{Group: "apps", Kind: "Deployment", Name: "guestbook-ui"},
+		{Group: "example.com", Kind: "Widget", Name: "widget"},
+	}))
+	assert.Equal(t, 1, argoCDClient.updates)
+	require.Len(t, argoCDClient.remediations, 1)
+	assert.Equal(t, "argocd/guestbook", argoCDClient.remediations[0].Application)
+	assert.Equal(t, map[string][]string{"example.com": {"Widget"}}, argoCDClient.remediations[0].Kinds)
+
+	// Kinds already included are not recorded again
+	require.NoError(t, controller.remediate(app, []*common.ResourceInfo{{Group: "example.com", Kind: "Widget", Name: "widget"}}))
+	assert.Len(t, argoCDClient.remediations, 1)
+}
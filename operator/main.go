@@ -14,6 +14,7 @@ func newRootCommand() error {
 	}
 
 	rootCmd.AddCommand(newGraphQueryCommand())
+	rootCmd.AddCommand(newDynamicCommand())
 	err := rootCmd.Execute()
 	return err
 }
@@ -0,0 +1,27 @@
+package analyzer
+
+import (
+	"context"
+	"slices"
+
+	"github.com/anandf/resource-tracker/pkg/argocd"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+)
+
+// ApplicationTreeManifests returns the manifests of the given application and, down to depth
+// levels, the manifests of the applications found in them. The applications analyzed anyway are
+// left out of the tree. A depth of zero returns the manifests of the application alone.
+func ApplicationTreeManifests(ctx context.Context, ac argocd.ArgoCD, app *v1alpha1.Application, kubeconfigPath string, depth int, analyzed []*v1alpha1.Application) ([]argocd.ApplicationManifests, error) {
+	if depth <= 0 {
+		manifests, err := ac.GetApplicationChildManifests(ctx, app, kubeconfigPath, "")
+		if err != nil {
+			return nil, err
+		}
+		return []argocd.ApplicationManifests{{Application: app, Manifests: manifests}}, nil
+	}
+	return ac.GetApplicationTreeManifests(ctx, app, kubeconfigPath, depth, func(child *v1alpha1.Application) bool {
+		return slices.ContainsFunc(analyzed, func(a *v1alpha1.Application) bool {
+			return ApplicationName(a) == ApplicationName(child)
+		})
+	})
+}
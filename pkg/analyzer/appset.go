@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/anandf/resource-tracker/pkg/argocd"
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	log "github.com/sirupsen/logrus"
+)
+
+// AppSetReport is the result of the analysis of the applications of an ApplicationSet.
+type AppSetReport struct {
+	// ApplicationSet is the namespace/name of the ApplicationSet.
+	ApplicationSet string
+	// Combined holds the kinds required by any of the applications.
+	Combined common.GroupedResourceKinds
+	// Unique holds, for each application, the kinds no other application of the set requires.
+	Unique map[string]common.GroupedResourceKinds
+}
+
+// NewAppSetReport returns the report of the given ApplicationSet, from the kinds required by each
+// of its applications.
+func NewAppSetReport(appSet string, appKinds map[string]common.GroupedResourceKinds) *AppSetReport {
+	report := &AppSetReport{
+		ApplicationSet: appSet,
+		Combined:       make(common.GroupedResourceKinds),
+		Unique:         make(map[string]common.GroupedResourceKinds, len(appKinds)),
+	}
+	for app, groupedKinds := range appKinds {
+		report.Combined.Merge(groupedKinds)
+		others := make(common.GroupedResourceKinds)
+		for other, otherKinds := range appKinds {
+			if other != app {
+				others.Merge(otherKinds)
+			}
+		}
+		report.Unique[app] = groupedKinds.Difference(others)
+	}
+	return report
+}
+
+func (r *AppSetReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "ApplicationSet %s: %d applications\nresource.inclusions: |\n%s", r.ApplicationSet, len(r.Unique), r.Combined.String())
+	for _, app := range slices.Sorted(maps.Keys(r.Unique)) {
+		unique := r.Unique[app]
+		if len(unique) == 0 {
+			fmt.Fprintf(&sb, "Application %s requires no kind of its own\n", app)
+			continue
+		}
+		fmt.Fprintf(&sb, "Application %s alone requires:\n%s", app, unique.String())
+	}
+	return sb.String()
+}
+
+// AppSetAnalyzer is implemented by the backends which can analyze the applications of an
+// ApplicationSet as one group.
+type AppSetAnalyzer interface {
+	// AnalyzeAppSet analyzes the applications of the ApplicationSet selected by Options.AppSet.
+	AnalyzeAppSet(ctx context.Context, opts Options) (*AppSetReport, error)
+}
+
+// AppSetName returns the namespace and name of the ApplicationSet selected by the given options,
+// which is in the namespace of the applications, or else the Argo CD namespace, unless given as
+// namespace/name.
+func AppSetName(opts Options) (string, string) {
+	if parts := strings.SplitN(opts.AppSet, "/", 2); len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	if opts.TargetAppNamespace == "" {
+		return opts.ArgoCDNamespace, opts.AppSet
+	}
+	return opts.TargetAppNamespace, opts.AppSet
+}
+
+// AppSetApplications returns the applications of the ApplicationSet selected by the given
+// options: the applications it owns, or the applications its template renders to if
+// Options.AppSetPreview is set.
+func AppSetApplications(ctx context.Context, ac argocd.ArgoCD, opts Options) ([]*v1alpha1.Application, error) {
+	namespace, name := AppSetName(opts)
+	appSet, err := ac.GetApplicationSet(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	var appList []v1alpha1.Application
+	if opts.AppSetPreview {
+		appList, err = ac.PreviewApplicationSetApplications(ctx, appSet)
+	} else {
+		appList, err = ac.GetApplicationSetApplications(ctx, appSet)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.WithFields(log.Fields{
+		"applicationSet": fmt.Sprintf("%s/%s", namespace, name),
+		"preview":        opts.AppSetPreview,
+	}).Infof("Found %d applications", len(appList))
+	apps := make([]*v1alpha1.Application, 0, len(appList))
+	for i := range appList {
+		apps = append(apps, &appList[i])
+	}
+	return apps, nil
+}
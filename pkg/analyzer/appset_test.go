@@ -0,0 +1,32 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewAppSetReport(t *testing.T) {
+	report := NewAppSetReport("argocd/guestbook", map[string]common.GroupedResourceKinds{
+		"guestbook-dev":     {"apps": {"Deployment": {}}, "core": {"ConfigMap": {}}},
+		"guestbook-prod":    {"apps": {"Deployment": {}, "StatefulSet": {}}, "monitoring.coreos.com": {"ServiceMonitor": {}}},
+		"guestbook-staging": {"apps": {"Deployment": {}}, "core": {"ConfigMap": {}}},
+	})
+	assert.Equal(t, common.GroupedResourceKinds{
+		"apps":                  {"Deployment": {}, "StatefulSet": {}},
+		"core":                  {"ConfigMap": {}},
+		"monitoring.coreos.com": {"ServiceMonitor": {}},
+	}, report.Combined)
+	assert.Equal(t, map[string]common.GroupedResourceKinds{
+		"guestbook-dev":     {},
+		"guestbook-prod":    {"apps": {"StatefulSet": {}}, "monitoring.coreos.com": {"ServiceMonitor": {}}},
+		"guestbook-staging": {},
+	}, report.Unique)
+
+	// The kinds of a single application are all unique to it
+	report = NewAppSetReport("argocd/guestbook", map[string]common.GroupedResourceKinds{
+		"guestbook": {"apps": {"Deployment": {}}},
+	})
+	assert.Equal(t, map[string]common.GroupedResourceKinds{"guestbook": {"apps": {"Deployment": {}}}}, report.Unique)
+}
@@ -3,6 +3,7 @@ package dynamicbackend
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 
 	"github.com/anandf/resource-tracker/pkg/analyzer"
@@ -11,24 +12,211 @@ import (
 	"github.com/anandf/resource-tracker/pkg/dynamic"
 	"github.com/anandf/resource-tracker/pkg/kube"
 	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
-	"github.com/emirpasic/gods/sets/hashset"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 )
 
+// DefaultAppConcurrency is the number of applications analyzed in parallel when
+// Options.AppConcurrency is not set.
+const DefaultAppConcurrency = 4
+
 // Backend implements the analysis using OwnerRefs and the dynamic resource graph logic.
-type Backend struct{}
+// It keeps one DynamicTracker across executions, so that relations discovered by a previous
+// execution are reused.
+type Backend struct {
+	mu               sync.Mutex
+	tracker          *dynamic.DynamicTracker
+	onRelationChange func(dynamic.RelationChange)
+}
 
 func NewBackend() *Backend {
 	return &Backend{}
 }
 
+// OnRelationChange registers fn to be called when a relation appears or disappears on a
+// destination cluster. It is only called when Options.WatchRelations is set, and must be
+// registered before the first execution.
+func (b *Backend) OnRelationChange(fn func(dynamic.RelationChange)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onRelationChange = fn
+}
+
+// Stop stops the informers the backend runs on the destination clusters. The relations found so
+// far are dropped, a later execution starts with a new DynamicTracker.
+func (b *Backend) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tracker != nil {
+		b.tracker.Stop()
+		b.tracker = nil
+	}
+}
+
+// getTracker returns the DynamicTracker of the backend, creating it on first use.
+func (b *Backend) getTracker(opts analyzer.Options, logger *log.Entry) *dynamic.DynamicTracker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tracker == nil {
+		b.tracker = dynamic.NewDynamicTracker(logger, dynamic.ScanOptions{
+			QPS:     opts.ClusterQPS,
+			Burst:   opts.ClusterBurst,
+			Workers: opts.ScanWorkers,
+		})
+		if opts.WatchRelations {
+			b.tracker.EnableRelationWatch(b.onRelationChange)
+		}
+		b.tracker.SetRelationTTL(opts.RelationTTL)
+	}
+	return b.tracker
+}
+
+// appAnalysis holds the resources found for one application.
+type appAnalysis struct {
+	app       *v1alpha1.Application
+	server    string
+	manifests []*common.ResourceInfo
+	children  []*common.ResourceInfo
+}
+
+// analysis holds the result of an execution of the backend.
+type analysis struct {
+	groupedKinds common.GroupedResourceKinds
+	apps         []*v1alpha1.Application
+	// statusResources holds the resources reported in the status of each application
+	statusResources map[*v1alpha1.Application][]*common.ResourceInfo
+	results         []appAnalysis
+	tracker         *dynamic.DynamicTracker
+	ac              argocd.ArgoCD
+}
+
 // Execute runs the dynamic analysis and returns grouped resource kinds.
 func (b *Backend) Execute(ctx context.Context, opts analyzer.Options) (*common.GroupedResourceKinds, error) {
+	result, err := b.analyze(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &result.groupedKinds, nil
+}
+
+// ServedKinds returns the kinds served by the destination clusters of the applications analyzed
+// so far, by group.
+func (b *Backend) ServedKinds() (common.GroupedResourceKinds, error) {
+	b.mu.Lock()
+	tracker := b.tracker
+	b.mu.Unlock()
+	served := make(common.GroupedResourceKinds)
+	if tracker == nil {
+		return served, nil
+	}
+	tracker.CacheMu.RLock()
+	mappers := make(map[string]*dynamic.ResourceMapper, len(tracker.ResourceMapperStore))
+	for server, mapper := range tracker.ResourceMapperStore {
+		mappers[server] = mapper
+	}
+	tracker.CacheMu.RUnlock()
+	for server, mapper := range mappers {
+		kinds, err := mapper.ServedKinds()
+		if err != nil {
+			return nil, fmt.Errorf("dynamic backend: error discovering the kinds of cluster %s: %w", server, err)
+		}
+		served.Merge(kinds)
+	}
+	return served, nil
+}
+
+// AnalyzeAppSet runs the dynamic analysis of the applications of an ApplicationSet, and reports
+// the kinds they require together and the kinds each of them requires alone.
+func (b *Backend) AnalyzeAppSet(ctx context.Context, opts analyzer.Options) (*analyzer.AppSetReport, error) {
+	if opts.AppSet == "" {
+		return nil, fmt.Errorf("dynamic backend: no ApplicationSet in Options")
+	}
+	result, err := b.analyze(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	appKinds := make(map[string]common.GroupedResourceKinds, len(result.apps))
+	for _, app := range result.apps {
+		groupedKinds := make(common.GroupedResourceKinds)
+		groupedKinds.MergeResourceInfos(result.statusResources[app])
+		appKinds[analyzer.ApplicationName(app)] = groupedKinds
+	}
+	for _, appResult := range result.results {
+		name := analyzer.ApplicationName(appResult.app)
+		if appKinds[name] == nil {
+			// Child application of an app-of-apps
+			appKinds[name] = make(common.GroupedResourceKinds)
+		}
+		groupedKinds := appKinds[name]
+		groupedKinds.MergeResourceInfos(appResult.children)
+	}
+	namespace, name := analyzer.AppSetName(opts)
+	return analyzer.NewAppSetReport(fmt.Sprintf("%s/%s", namespace, name), appKinds), nil
+}
+
+// CheckProjects runs the dynamic analysis, and checks the kinds the applications require against
+// their AppProjects.
+func (b *Backend) CheckProjects(ctx context.Context, opts analyzer.Options) ([]analyzer.ProjectReport, error) {
+	result, err := b.analyze(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	apps := make([]analyzer.AppKinds, 0, len(result.results))
+	for _, appResult := range result.results {
+		apps = append(apps, analyzer.AppKinds{
+			Application: appResult.app,
+			Manifests:   appResult.manifests,
+			Resources:   append(slices.Clone(appResult.children), result.statusResources[appResult.app]...),
+		})
+	}
+	// The applications whose destination cluster could not be reached only have their status
+	for _, app := range result.apps {
+		if !slices.ContainsFunc(result.results, func(appResult appAnalysis) bool { return appResult.app == app }) {
+			apps = append(apps, analyzer.AppKinds{Application: app, Resources: result.statusResources[app]})
+		}
+	}
+	return analyzer.CheckProjects(ctx, result.ac, opts.KubeConfigPath, apps)
+}
+
+// Explain runs the dynamic analysis and returns the chains of relations through which the
+// applications lead to resources of the given group and kind. The relations below the
+// manifests of an application are reported with an example of the objects they were seen on.
+func (b *Backend) Explain(ctx context.Context, opts analyzer.Options, group, kind string) ([]analyzer.Chain, error) {
+	result, err := b.analyze(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	target := dynamic.ResourceInfoKey(group, kind)
+	var chains []analyzer.Chain
+	for _, appResult := range result.results {
+		// Relations are known per kind, a single chain is reported for the manifests of a kind
+		seenRoots := make(map[string]bool)
+		for _, manifest := range appResult.manifests {
+			root := dynamic.ResourceInfoKey(manifest.Group, manifest.Kind)
+			if seenRoots[root] {
+				continue
+			}
+			seenRoots[root] = true
+			links := result.tracker.FindRelationChain(appResult.server, root, target)
+			if links == nil {
+				continue
+			}
+			chains = append(chains, analyzer.Chain{
+				Application: analyzer.ApplicationName(appResult.app),
+				Links:       append([]common.Provenance{analyzer.ManifestLink(appResult.app, appResult.server, manifest)}, links...),
+			})
+		}
+	}
+	return append(chains, analyzer.StatusChains(result.apps, group, kind)...), nil
+}
+
+// analyze runs the dynamic analysis of the applications selected by the given options.
+func (b *Backend) analyze(ctx context.Context, opts analyzer.Options) (*analysis, error) {
 	logger := log.WithFields(log.Fields{
 		"controllerNamespace": opts.ArgoCDNamespace,
 		"strategy":            "dynamic",
 		"allApps":             opts.TargetApp == "",
+		"applicationSet":      opts.AppSet,
 	})
 	logger.Info("Starting dynamic analysis backend...")
 
@@ -45,36 +233,35 @@ func (b *Backend) Execute(ctx context.Context, opts analyzer.Options) (*common.G
 		opts.RepoServerTimeoutSeconds,
 		opts.RepoServerPlaintext,
 		opts.RepoServerStrictTLS,
+		opts.ManifestCacheDir,
+		opts.LocalCheckoutDir,
 	)
 	if err != nil {
 		return nil, err
 	}
-	// Initialize the shared DynamicTracker used to discover relations across clusters.
-	rt := dynamic.NewDynamicTracker(logger)
+	// Get the shared DynamicTracker used to discover relations across clusters.
+	rt := b.getTracker(opts, logger)
 	var apps []*v1alpha1.Application
-	groupedKinds := make(common.GroupedResourceKinds)
-	if opts.TargetApp == "" {
+	switch {
+	case opts.AppSet != "":
+		// Analyze the apps of an ApplicationSet
+		apps, err = analyzer.AppSetApplications(ctx, ac, opts)
+		if err != nil {
+			return nil, err
+		}
+	case opts.TargetApp == "":
 		// Analyze all apps
 		logger.Info("Listing all applications...")
-		appsList, err := ac.ListApplications()
+		appsList, err := ac.SelectApplications(ctx, opts.AppSelection)
 		if err != nil {
 			return nil, err
 		}
 		logger.Infof("Found %d applications", len(appsList))
 		apps = make([]*v1alpha1.Application, 0, len(appsList))
 		for i := range appsList {
-			app := appsList[i]
-			apps = append(apps, &app)
-			missingResources, err := ac.GetResourcesFromApplicationStatus(ctx, &app)
-			if err != nil {
-				logger.WithError(err).Error("Error getting missing resources from application conditions")
-				continue
-			}
-			logger.Debugf("Found %d missing resources from application conditions", len(missingResources))
-			groupedKinds.MergeResourceInfos(missingResources)
+			apps = append(apps, &appsList[i])
 		}
-
-	} else {
+	default:
 		// Analyze a single app
 		logger.Infof("Getting application %q", opts.TargetApp)
 		app, err := ac.GetApplication(opts.TargetApp)
@@ -82,38 +269,61 @@ func (b *Backend) Execute(ctx context.Context, opts analyzer.Options) (*common.G
 			return nil, err
 		}
 		apps = []*v1alpha1.Application{app}
+	}
+	groupedKinds := make(common.GroupedResourceKinds)
+	statusResources := make(map[*v1alpha1.Application][]*common.ResourceInfo, len(apps))
+	for _, app := range apps {
 		missingResources, err := ac.GetResourcesFromApplicationStatus(ctx, app)
 		if err != nil {
-			return nil, err
+			if opts.TargetApp != "" && opts.AppSet == "" {
+				return nil, err
+			}
+			logger.WithError(err).Error("Error getting missing resources from application conditions")
+			continue
 		}
 		logger.Debugf("Found %d missing resources from application conditions", len(missingResources))
+		statusResources[app] = missingResources
 		groupedKinds.MergeResourceInfos(missingResources)
 	}
 
 	// Use the v2 implementation based on errgroup for concurrency and cancellation.
-	appChildren := analyzeWithDynamicTracker(opts.KubeConfigPath, ctx, apps, ac, rt, logger)
-	groupedKinds.MergeResourceInfos(appChildren)
-	return &groupedKinds, nil
+	results := analyzeWithDynamicTracker(opts.KubeConfigPath, ctx, apps, ac, rt, opts.AppConcurrency, opts.AppOfAppsDepth, logger)
+	for _, appResult := range results {
+		groupedKinds.MergeResourceInfos(appResult.children)
+	}
+	return &analysis{
+		groupedKinds:    groupedKinds,
+		apps:            apps,
+		statusResources: statusResources,
+		results:         results,
+		tracker:         rt,
+		ac:              ac,
+	}, nil
 }
 
 // analyzeWithDynamicTracker analyzes the applications concurrently using errgroup
-// and returns the computed list of resources.
+// and returns the resources found for each application.
 func analyzeWithDynamicTracker(
 	kubeconfigPath string,
 	ctx context.Context,
 	apps []*v1alpha1.Application,
 	ac argocd.ArgoCD, // Passed in dependency
 	rt *dynamic.DynamicTracker, // Passed in dependency
+	concurrency int,
+	appOfAppsDepth int,
 	logger *log.Entry,
-) []*common.ResourceInfo {
+) []appAnalysis {
 	var (
-		mu          sync.Mutex
-		appChildren []*common.ResourceInfo
+		mu      sync.Mutex
+		results []appAnalysis
 	)
 
 	// errgroup handles concurrency, error propagation, and context cancellation.
 	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(4)
+	if concurrency <= 0 {
+		concurrency = DefaultAppConcurrency
+	}
+	g.SetLimit(concurrency)
 
 	for _, app := range apps {
 		// Lets not terminate if we encounter an error while processing an application, we are logging the error and returning nil to continue the loop.
@@ -130,104 +340,124 @@ func analyzeWithDynamicTracker(
 				"applicationNamespace": app.GetNamespace(),
 			})
 			appLogger.Info("Processing application")
-			server := app.Spec.Destination.Server
-			if server == "" {
-				if app.Spec.Destination.Name == "" {
-					err := fmt.Errorf("both destination server and name are empty")
-					appLogger.WithError(err).Error("Destination missing")
-					return nil
-				}
-				var err error
-				server, err = ac.GetApplicationClusterServerByName(ctx, app.Spec.Destination.Name)
-				if err != nil {
-					logger.WithError(err).Error("Error getting cluster by name")
-					return nil
-				}
-			}
-			appCluster, err := ac.GetAppCluster(ctx, server)
-			if err != nil {
-				appLogger.WithError(err).Error("Error getting cluster")
-				return nil
-			}
-			restCfg, err := kube.RestConfigFromCluster(appCluster, kubeconfigPath)
-			if err != nil {
-				appLogger.WithError(err).Error("Error creating rest config")
-				return nil
-			}
-			err = rt.SyncResourceMapper(server, restCfg)
-			if err != nil {
-				appLogger.WithError(err).Error("Error syncing resource mapper")
-				return nil
-			}
-			// Check if the mapper for this specific server was created successfully
-			rt.CacheMu.RLock()
-			mapperExists := rt.ResourceMapperStore[server] != nil
-			rt.CacheMu.RUnlock()
-			if !mapperExists {
-				appLogger.Error("Resource mapper for cluster was not created; ensure Applications have valid .spec.destination and Argo CD has access")
-				return nil
-			}
-			childManifests, err := ac.GetApplicationChildManifests(ctx, app, kubeconfigPath, server)
+			// The applications found in the manifests of an app-of-apps are analyzed along with it
+			tree, err := analyzer.ApplicationTreeManifests(ctx, ac, app, kubeconfigPath, appOfAppsDepth, apps)
 			if err != nil {
 				appLogger.WithError(err).Error("Error getting child manifests")
 				return nil
 			}
-			appLogger.Debugf("Children of Argo CD application %q: %v", app.GetName(), childManifests)
-			// Check if any direct resource is missing in cache
-			rt.CacheMu.RLock()
-			missingKeys := make([]string, 0)
-			for _, resource := range childManifests {
-				k := dynamic.GetResourceKey(resource.Group, resource.Kind)
-				if _, exists := rt.SharedRelationsCache[k]; !exists {
-					missingKeys = append(missingKeys, k)
-				}
-			}
-			rt.CacheMu.RUnlock()
-			if len(missingKeys) > 0 {
-				// Ensure only one worker per cluster performs the sync; others wait.
-				// The cluster lock prevents multiple goroutines from syncing the same cluster concurrently.
-				clusterLock := rt.GetClusterSyncLock(server)
-				clusterLock.Lock()
-				// Re-check under the cluster lock: another goroutine might have already synced
-				// the cache while we were waiting for the lock, making our sync unnecessary.
-				rt.CacheMu.RLock()
-				stillMissingKeys := make([]string, 0)
-				for _, k := range missingKeys {
-					if _, exists := rt.SharedRelationsCache[k]; !exists {
-						stillMissingKeys = append(stillMissingKeys, k)
-					}
+			for _, node := range tree {
+				nodeLogger := appLogger
+				if node.Parent != "" {
+					nodeLogger = appLogger.WithField("childApplication", analyzer.ApplicationName(node.Application))
 				}
-				rt.CacheMu.RUnlock()
-				if len(stillMissingKeys) > 0 {
-					appLogger.WithFields(log.Fields{
-						"cluster":          server,
-						"missingResources": stillMissingKeys,
-						"count":            len(stillMissingKeys),
-					}).Info("Syncing cache for missing resources")
-					rt.EnsureSyncedSharedCacheOnHost(ctx, server)
-					// Add direct resources as leaf nodes (empty children set) if they're still not in cache
-					// This ensures they're tracked even if they have no children or weren't discovered during sync
-					rt.CacheMu.Lock()
-					for _, k := range stillMissingKeys {
-						if _, exists := rt.SharedRelationsCache[k]; !exists {
-							rt.SharedRelationsCache[k] = hashset.New()
-						}
-					}
-					rt.CacheMu.Unlock()
+				appResult, ok := analyzeManifests(ctx, kubeconfigPath, node.Application, node.Manifests, ac, rt, nodeLogger)
+				if !ok {
+					continue
 				}
-				clusterLock.Unlock()
+				mu.Lock()
+				results = append(results, appResult)
+				mu.Unlock()
 			}
-			// Read cache with lock to ensure consistency during DFS traversal
-			rt.CacheMu.RLock()
-			relations := dynamic.GetResourceRelation(rt.SharedRelationsCache, childManifests)
-			rt.CacheMu.RUnlock()
-			mu.Lock()
-			appChildren = append(appChildren, relations...)
-			mu.Unlock()
 			return nil
 		})
 	}
 	// Wait for all workers to complete.
 	g.Wait()
-	return appChildren
+	return results
+}
+
+// analyzeManifests finds the relations below the given manifests of an application on its
+// destination cluster, syncing the relation cache of the cluster first if needed. ok is false if
+// the destination cluster could not be reached.
+func analyzeManifests(
+	ctx context.Context,
+	kubeconfigPath string,
+	app *v1alpha1.Application,
+	childManifests []*common.ResourceInfo,
+	ac argocd.ArgoCD,
+	rt *dynamic.DynamicTracker,
+	appLogger *log.Entry,
+) (appAnalysis, bool) {
+	server := app.Spec.Destination.Server
+	if server == "" {
+		if app.Spec.Destination.Name == "" {
+			err := fmt.Errorf("both destination server and name are empty")
+			appLogger.WithError(err).Error("Destination missing")
+			return appAnalysis{}, false
+		}
+		var err error
+		server, err = ac.GetApplicationClusterServerByName(ctx, app.Spec.Destination.Name)
+		if err != nil {
+			appLogger.WithError(err).Error("Error getting cluster by name")
+			return appAnalysis{}, false
+		}
+	}
+	appCluster, err := ac.GetAppCluster(ctx, server)
+	if err != nil {
+		appLogger.WithError(err).Error("Error getting cluster")
+		return appAnalysis{}, false
+	}
+	restCfg, err := kube.RestConfigFromCluster(appCluster, kubeconfigPath)
+	if err != nil {
+		appLogger.WithError(err).Error("Error creating rest config")
+		return appAnalysis{}, false
+	}
+	err = rt.SyncResourceMapper(server, restCfg)
+	if err != nil {
+		appLogger.WithError(err).Error("Error syncing resource mapper")
+		return appAnalysis{}, false
+	}
+	// Check if the mapper for this specific server was created successfully
+	rt.CacheMu.RLock()
+	mapper := rt.ResourceMapperStore[server]
+	rt.CacheMu.RUnlock()
+	if mapper == nil {
+		appLogger.Error("Resource mapper for cluster was not created; ensure Applications have valid .spec.destination and Argo CD has access")
+		return appAnalysis{}, false
+	}
+	appLogger.Debugf("Children of Argo CD application %q: %v", app.GetName(), childManifests)
+	// Check if any direct resource is missing in the cache of the destination cluster
+	keys := make([]string, 0, len(childManifests))
+	for _, resource := range childManifests {
+		keys = append(keys, dynamic.GetResourceKey(resource.Group, resource.Kind))
+	}
+	missingKeys := rt.MissingKeys(server, keys)
+	// Only the namespaces the application deploys into are scanned, unless it deploys
+	// objects which may own objects in any namespace.
+	namespaces := mapper.ScanNamespaces(app.Spec.Destination.Namespace, childManifests)
+	if len(missingKeys) > 0 || len(rt.NamespacesToScan(server, namespaces, false)) > 0 {
+		// Ensure only one worker per cluster performs the sync; others wait.
+		// The cluster lock prevents multiple goroutines from syncing the same cluster concurrently.
+		clusterLock := rt.GetClusterSyncLock(server)
+		clusterLock.Lock()
+		// Re-check under the cluster lock: another goroutine might have already synced
+		// the cache while we were waiting for the lock, making our sync unnecessary.
+		stillMissingKeys := rt.MissingKeys(server, missingKeys)
+		// Missing kinds may have appeared in the namespaces scanned before, they are scanned again.
+		namespacesToScan := rt.NamespacesToScan(server, namespaces, len(stillMissingKeys) > 0)
+		if len(namespacesToScan) > 0 {
+			appLogger.WithFields(log.Fields{
+				"cluster":          server,
+				"missingResources": stillMissingKeys,
+				"count":            len(stillMissingKeys),
+				"namespaces":       namespacesToScan,
+			}).Info("Syncing cache for missing resources and namespaces")
+			rt.EnsureSyncedSharedCacheOnHost(ctx, server, namespacesToScan)
+		}
+		if len(stillMissingKeys) > 0 {
+			// Add direct resources as leaf nodes (empty children set) if they're still not in cache
+			// This ensures they're tracked even if they have no children or weren't discovered during sync
+			rt.AddLeafKeys(server, stillMissingKeys)
+		}
+		clusterLock.Unlock()
+	}
+	// Only the relations seen on the destination cluster apply to the application
+	relations := rt.GetResourceRelation(server, childManifests)
+	return appAnalysis{
+		app:       app,
+		server:    server,
+		manifests: childManifests,
+		children:  relations,
+	}, true
 }
@@ -0,0 +1,58 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"github.com/anandf/resource-tracker/pkg/argocd"
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	log "github.com/sirupsen/logrus"
+)
+
+// MatchesKind returns true if the given resource is of the given group and kind, the core group
+// being named either "" or "core".
+func MatchesKind(resource *common.ResourceInfo, group, kind string) bool {
+	return resource.Kind == kind && coreGroup(resource.Group) == coreGroup(group)
+}
+
+func coreGroup(group string) string {
+	if group == "core" {
+		return ""
+	}
+	return group
+}
+
+// ApplicationName returns the namespace/name of the given application, as shown in a Chain.
+func ApplicationName(app *v1alpha1.Application) string {
+	return fmt.Sprintf("%s/%s", app.GetNamespace(), app.GetName())
+}
+
+// ManifestLink returns the relation linking the given application to a resource of its manifests
+// deployed on the given cluster.
+func ManifestLink(app *v1alpha1.Application, cluster string, manifest *common.ResourceInfo) common.Provenance {
+	return common.Provenance{
+		Source:  common.SourceManifest,
+		Cluster: cluster,
+		Parent:  argocd.ApplicationResourceInfo(app),
+		Child:   *manifest,
+	}
+}
+
+// StatusChains returns the chains linking the given applications to the resources of the given
+// group and kind reported in their status.
+func StatusChains(apps []*v1alpha1.Application, group, kind string) []Chain {
+	var chains []Chain
+	for _, app := range apps {
+		links, err := argocd.GetApplicationStatusProvenance(app)
+		if err != nil {
+			log.WithError(err).WithField("application", ApplicationName(app)).Warn("Error getting resources from application status")
+			continue
+		}
+		for _, link := range links {
+			if MatchesKind(&link.Child, group, kind) {
+				chains = append(chains, Chain{Application: ApplicationName(app), Links: []common.Provenance{link}})
+			}
+		}
+	}
+	return chains
+}
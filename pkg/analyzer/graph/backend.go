@@ -2,7 +2,9 @@ package graphbackend
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"sync"
 
 	"github.com/anandf/resource-tracker/pkg/analyzer"
@@ -11,6 +13,7 @@ import (
 	"github.com/anandf/resource-tracker/pkg/graph"
 	"github.com/anandf/resource-tracker/pkg/kube"
 	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/argo-cd/v3/util/argo"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -18,6 +21,9 @@ import (
 type Backend struct {
 	mu           sync.Mutex
 	queryServers map[string]*graph.QueryServer
+	// statusOnly makes the traversal start from the resources listed in the status of the
+	// applications and the live objects tracked to them, instead of their manifests.
+	statusOnly bool
 }
 
 func NewBackend() *Backend {
@@ -27,21 +33,206 @@ func NewBackend() *Backend {
 	}
 }
 
+// NewStatusBackend returns a Backend which never contacts the repo-server: the traversal starts
+// from the resources listed in Application.status and the live objects carrying the tracking
+// label or annotation of the applications. The kinds of the applications which have never synced
+// are only known from their status.
+func NewStatusBackend() *Backend {
+	b := NewBackend()
+	b.statusOnly = true
+	return b
+}
+
+// appAnalysis holds the resources found for one application.
+type appAnalysis struct {
+	app *v1alpha1.Application
+	// qs is the QueryServer of the destination cluster, nil if it could not be created
+	qs        *graph.QueryServer
+	manifests []*common.ResourceInfo
+	children  []*common.ResourceInfo
+	// tracked holds the manifests found from their tracking label or annotation, with statusOnly
+	tracked map[common.ResourceInfo]bool
+}
+
 // Execute performs a graph-based analysis and returns grouped resource kinds.
 func (b *Backend) Execute(ctx context.Context, opts analyzer.Options) (*common.GroupedResourceKinds, error) {
+	results, _, incomplete, err := b.analyze(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	var allAppChildren []*common.ResourceInfo
+	for _, appResult := range results {
+		allAppChildren = append(allAppChildren, appResult.children...)
+	}
+	groupedKinds := make(common.GroupedResourceKinds)
+	groupedKinds.MergeResourceInfos(allAppChildren)
+	if incomplete {
+		return &groupedKinds, fmt.Errorf("graph backend: %w", analyzer.ErrIncompleteResult)
+	}
+	return &groupedKinds, nil
+}
+
+// ServedKinds returns the kinds served by the destination clusters of the applications analyzed
+// so far, by group.
+func (b *Backend) ServedKinds() (common.GroupedResourceKinds, error) {
+	b.mu.Lock()
+	queryServers := make([]*graph.QueryServer, 0, len(b.queryServers))
+	for _, qs := range b.queryServers {
+		queryServers = append(queryServers, qs)
+	}
+	b.mu.Unlock()
+	served := make(common.GroupedResourceKinds)
+	for _, qs := range queryServers {
+		kinds, err := qs.ServedKinds()
+		if err != nil {
+			return nil, fmt.Errorf("graph backend: error discovering the kinds of cluster %s: %w", qs.Cluster, err)
+		}
+		served.Merge(kinds)
+	}
+	return served, nil
+}
+
+// AnalyzeAppSet performs a graph-based analysis of the applications of an ApplicationSet, and
+// reports the kinds they require together and the kinds each of them requires alone.
+func (b *Backend) AnalyzeAppSet(ctx context.Context, opts analyzer.Options) (*analyzer.AppSetReport, error) {
+	if opts.AppSet == "" {
+		return nil, fmt.Errorf("graph backend: no ApplicationSet in Options")
+	}
+	results, _, incomplete, err := b.analyze(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	appKinds := make(map[string]common.GroupedResourceKinds, len(results))
+	for _, appResult := range results {
+		groupedKinds := make(common.GroupedResourceKinds)
+		groupedKinds.MergeResourceInfos(appResult.children)
+		appKinds[analyzer.ApplicationName(appResult.app)] = groupedKinds
+	}
+	namespace, name := analyzer.AppSetName(opts)
+	report := analyzer.NewAppSetReport(fmt.Sprintf("%s/%s", namespace, name), appKinds)
+	if incomplete {
+		return report, fmt.Errorf("graph backend: %w", analyzer.ErrIncompleteResult)
+	}
+	return report, nil
+}
+
+// CheckProjects performs a graph-based analysis, and checks the kinds the applications require
+// against their AppProjects.
+func (b *Backend) CheckProjects(ctx context.Context, opts analyzer.Options) ([]analyzer.ProjectReport, error) {
+	results, argoCDClient, incomplete, err := b.analyze(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	reports, err := analyzer.CheckProjects(ctx, argoCDClient, opts.KubeConfigPath, appKinds(results))
+	if err != nil {
+		return nil, err
+	}
+	if incomplete {
+		return reports, fmt.Errorf("graph backend: %w", analyzer.ErrIncompleteResult)
+	}
+	return reports, nil
+}
+
+// appKinds returns the manifests and the resources found for each of the given applications.
+func appKinds(results []appAnalysis) []analyzer.AppKinds {
+	apps := make([]analyzer.AppKinds, 0, len(results))
+	for _, appResult := range results {
+		apps = append(apps, analyzer.AppKinds{
+			Application: appResult.app,
+			Manifests:   appResult.manifests,
+			Resources:   appResult.children,
+		})
+	}
+	return apps
+}
+
+// Explain performs a graph-based analysis and returns the chains of relations through which the
+// applications lead to resources of the given group and kind. A resource reached from the
+// manifests of several applications of a cluster is explained for the first of them.
+func (b *Backend) Explain(ctx context.Context, opts analyzer.Options, group, kind string) ([]analyzer.Chain, error) {
+	results, _, incomplete, err := b.analyze(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	chains := b.chains(results, group, kind)
+	if incomplete {
+		return chains, fmt.Errorf("graph backend: %w", analyzer.ErrIncompleteResult)
+	}
+	return chains, nil
+}
+
+// chains returns the chains of relations through which the given applications lead to resources
+// of the given group and kind.
+func (b *Backend) chains(results []appAnalysis, group, kind string) []analyzer.Chain {
+	var chains []analyzer.Chain
+	apps := make([]*v1alpha1.Application, 0, len(results))
+	for _, appResult := range results {
+		apps = append(apps, appResult.app)
+		if appResult.qs == nil {
+			continue
+		}
+		seen := make(map[common.ResourceInfo]bool)
+		for _, child := range appResult.children {
+			if seen[*child] || !analyzer.MatchesKind(child, group, kind) {
+				continue
+			}
+			seen[*child] = true
+			links := appResult.qs.ProvenanceChain(*child)
+			root := *child
+			if len(links) > 0 {
+				root = links[0].Parent
+			}
+			i := slices.IndexFunc(appResult.manifests, func(manifest *common.ResourceInfo) bool { return *manifest == root })
+			if i < 0 {
+				continue
+			}
+			link := analyzer.ManifestLink(appResult.app, appResult.qs.Cluster, appResult.manifests[i])
+			if b.statusOnly {
+				if !appResult.tracked[root] && len(links) == 0 {
+					// Explained by StatusChains
+					continue
+				}
+				link.Source = common.SourceApplicationStatus
+				if appResult.tracked[root] {
+					link.Source = common.SourceTracking
+				}
+			}
+			chains = append(chains, analyzer.Chain{
+				Application: analyzer.ApplicationName(appResult.app),
+				Links:       append([]common.Provenance{link}, links...),
+			})
+		}
+	}
+	return append(chains, analyzer.StatusChains(apps, group, kind)...)
+}
+
+// analyze traverses the children of the applications selected by the given options, and returns
+// the resources found for each of them along with the Argo CD client used. incomplete is true if
+// the traversal budget was spent.
+func (b *Backend) analyze(ctx context.Context, opts analyzer.Options) (results []appAnalysis, argoCDClient argocd.ArgoCD, incomplete bool, err error) {
+	strategy := "graph"
+	if b.statusOnly {
+		strategy = "status"
+	}
 	logger := log.WithFields(log.Fields{
 		"controllerNamespace":  opts.ArgoCDNamespace,
-		"strategy":             "graph",
+		"strategy":             strategy,
 		"applicationNamespace": opts.TargetAppNamespace,
 	})
 	logger.Info("Starting graph (Cyphernetes) analysis backend...")
 
 	if opts.KubeConfig == nil {
-		return nil, fmt.Errorf("graph backend: KubeConfig is nil in Options")
+		return nil, nil, false, fmt.Errorf("graph backend: KubeConfig is nil in Options")
+	}
+	if b.statusOnly && opts.AppSetPreview {
+		return nil, nil, false, fmt.Errorf("graph backend: the applications of an ApplicationSet preview have no status to analyze")
+	}
+	if b.statusOnly && opts.AppOfAppsDepth > 0 {
+		logger.Warn("The manifests of the child applications of an app-of-apps are not rendered with the status strategy, ignoring the app-of-apps depth")
 	}
 
 	// Initialize ArgoCD client against the control-plane cluster.
-	argoCDClient, err := argocd.NewArgoCD(
+	argoCDClient, err = argocd.NewArgoCD(
 		opts.KubeConfig,
 		opts.ArgoCDNamespace,
 		opts.TargetAppNamespace,
@@ -49,100 +240,209 @@ func (b *Backend) Execute(ctx context.Context, opts analyzer.Options) (*common.G
 		opts.RepoServerTimeoutSeconds,
 		opts.RepoServerPlaintext,
 		opts.RepoServerStrictTLS,
+		opts.ManifestCacheDir,
+		opts.LocalCheckoutDir,
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, false, err
 	}
 	trackingMethod, err := argoCDClient.GetTrackingMethod()
 	if err != nil {
-		return nil, err
+		return nil, nil, false, err
 	}
 
-	var allAppChildren []*common.ResourceInfo
-	if opts.TargetApp != "" {
-		appLogger := logger.WithField("applicationName", opts.TargetApp)
-		appLogger.Infof("Processing application")
+	// Bound the time spent traversing the destination clusters, once the budget is spent
+	// the children found so far are returned as an incomplete result.
+	traversalCtx := ctx
+	if opts.TraversalTimeout > 0 {
+		var cancel context.CancelFunc
+		traversalCtx, cancel = context.WithTimeout(ctx, opts.TraversalTimeout)
+		defer cancel()
+	}
+
+	var argoApps []*v1alpha1.Application
+	if opts.AppSet != "" {
+		argoApps, err = analyzer.AppSetApplications(ctx, argoCDClient, opts)
+		if err != nil {
+			return nil, nil, false, err
+		}
+	} else if opts.TargetApp != "" {
 		argoApp, err := argoCDClient.GetApplication(opts.TargetApp)
 		if err != nil {
 			// If the application itself cannot be fetched, fail fast.
-			return nil, err
+			return nil, nil, false, err
 		}
+		argoApps = []*v1alpha1.Application{argoApp}
+	} else {
+		appList, err := argoCDClient.SelectApplications(ctx, opts.AppSelection)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		logger.Infof("Found %d applications", len(appList))
+		for i := range appList {
+			argoApps = append(argoApps, &appList[i])
+		}
+	}
+	results, incomplete = b.analyzeApps(ctx, traversalCtx, argoCDClient, argoApps, opts, trackingMethod, logger)
+	return results, argoCDClient, incomplete, nil
+}
 
-		// Try to resolve and traverse the destination cluster; on failure just log
-		// and fall back to status-based resources.
-		if qs, err := b.getQueryServerForApp(ctx, argoCDClient, argoApp, opts.KubeConfigPath, trackingMethod, appLogger); err != nil {
-			appLogger.WithError(err).Error("Error getting query server for destination cluster")
-		} else {
-			appChildren, err := argoCDClient.GetApplicationChildManifests(ctx, argoApp, opts.KubeConfigPath, "")
+// analyzeApps analyzes the given applications one after the other, along with the applications
+// found in their manifests. incomplete is true if the traversal budget was spent.
+func (b *Backend) analyzeApps(
+	ctx context.Context,
+	traversalCtx context.Context,
+	argoCDClient argocd.ArgoCD,
+	argoApps []*v1alpha1.Application,
+	opts analyzer.Options,
+	trackingMethod string,
+	logger *log.Entry,
+) (results []appAnalysis, incomplete bool) {
+	// The tracked objects of each destination cluster are listed once per run
+	trackedResources := make(map[*graph.QueryServer]*kube.TrackedResources)
+	for _, argoApp := range argoApps {
+		tree := []argocd.ApplicationManifests{{Application: argoApp}}
+		if !b.statusOnly {
+			// The applications found in the manifests of an app-of-apps are analyzed along with it
+			var err error
+			tree, err = analyzer.ApplicationTreeManifests(ctx, argoCDClient, argoApp, opts.KubeConfigPath, opts.AppOfAppsDepth, argoApps)
 			if err != nil {
-				appLogger.WithError(err).Error("Error getting application children")
-			} else {
-				appLogger.Debugf("Children of Argo CD application %q: %v", argoApp.Name, appChildren)
-				for _, appChild := range appChildren {
-					childResources, err := qs.GetNestedChildResources(appChild)
-					if err != nil {
-						appLogger.WithError(err).Error("Error getting nested child resources")
-						continue
-					}
-					for childResource := range childResources {
-						allAppChildren = append(allAppChildren, &childResource)
-					}
-				}
+				logger.WithField("applicationName", argoApp.Name).WithError(err).Error("Error getting application children")
+				tree = []argocd.ApplicationManifests{{Application: argoApp}}
 			}
 		}
-
-		// Always try to augment with resources inferred from Application.status,
-		// even if graph traversal failed.
-		resources, err := argoCDClient.GetResourcesFromApplicationStatus(ctx, argoApp)
-		if err != nil {
-			appLogger.WithError(err).Error("Error getting resources from application status")
-		} else {
-			allAppChildren = append(allAppChildren, resources...)
+		for _, node := range tree {
+			appResult, appIncomplete := b.analyzeApp(ctx, traversalCtx, argoCDClient, node.Application, node.Manifests, opts, trackingMethod, trackedResources, logger)
+			results = append(results, appResult)
+			incomplete = incomplete || appIncomplete
 		}
-	} else {
-		argoApps, err := argoCDClient.ListApplications()
-		if err != nil {
-			return nil, err
-		}
-		logger.Infof("Found %d applications", len(argoApps))
-		for _, argoApp := range argoApps {
-			appLogger := logger.WithField("applicationName", argoApp.Name)
-			appLogger.Info("Processing application")
-			appLogger.Debugf("Querying Argo CD application %q", argoApp.Name)
-			appChildren, err := argoCDClient.GetApplicationChildManifests(ctx, &argoApp, opts.KubeConfigPath, "")
-			if err != nil {
-				appLogger.WithError(err).Error("Error getting application children")
-			}
-			// Try to resolve and traverse the destination cluster; on failure just
-			// log and fall back to status-based resources.
-			if qs, err := b.getQueryServerForApp(ctx, argoCDClient, &argoApp, opts.KubeConfigPath, trackingMethod, logger); err != nil {
-				appLogger.WithError(err).Error("Error getting query server for application")
-			} else {
-				for _, appChild := range appChildren {
-					childResources, err := qs.GetNestedChildResources(appChild)
-					if err != nil {
-						appLogger.WithError(err).Error("Error getting nested child resources")
-						continue
-					}
-					for childResource := range childResources {
-						allAppChildren = append(allAppChildren, &childResource)
-					}
-					appLogger.Debugf("Children of Argo CD application %q: %v", argoApp.Name, childResources)
-				}
+	}
+	return results, incomplete
+}
+
+// analyzeApp traverses the children of the given manifests of an application on its destination
+// cluster, and adds the resources inferred from its status. The tracked objects listed from a
+// destination cluster are kept in trackedResources. incomplete is true if the traversal budget was
+// spent.
+func (b *Backend) analyzeApp(
+	ctx context.Context,
+	traversalCtx context.Context,
+	argoCDClient argocd.ArgoCD,
+	argoApp *v1alpha1.Application,
+	appChildren []*common.ResourceInfo,
+	opts analyzer.Options,
+	trackingMethod string,
+	trackedResources map[*graph.QueryServer]*kube.TrackedResources,
+	logger *log.Entry,
+) (appResult appAnalysis, incomplete bool) {
+	appResult.app = argoApp
+	appLogger := logger.WithField("applicationName", argoApp.Name)
+	appLogger.Info("Processing application")
+	// Try to resolve and traverse the destination cluster; on failure just
+	// log and fall back to status-based resources.
+	qs, err := b.getQueryServerForApp(ctx, argoCDClient, argoApp, opts, trackingMethod, appLogger)
+	if err != nil {
+		appLogger.WithError(err).Error("Error getting query server for destination cluster")
+	}
+	if b.statusOnly {
+		var tracked *kube.TrackedResources
+		if qs != nil {
+			if tracked = trackedResources[qs]; tracked == nil {
+				tracked = kube.NewTrackedResources(qs.RestConfig, annotationTracking(trackingMethod))
+				trackedResources[qs] = tracked
 			}
-			// Always try to augment with resources inferred from Application.status,
-			// even if graph traversal failed.
-			resources, err := argoCDClient.GetResourcesFromApplicationStatus(ctx, &argoApp)
-			if err != nil {
-				appLogger.WithError(err).Error("Error getting resources from application status")
+		}
+		appChildren, appResult.tracked = statusManifests(ctx, argoApp, tracked, opts.ArgoCDNamespace, appLogger)
+	}
+	appResult.manifests = appChildren
+	appLogger.Debugf("Children of Argo CD application %q: %v", argoApp.Name, appChildren)
+	if qs != nil {
+		appResult.qs = qs
+		// Kinds visited for the previous applications of the cluster are visited again for this one
+		qs.VisitedKinds = make(map[common.ResourceInfo]bool)
+		for _, appChild := range appChildren {
+			childResources, err := qs.GetNestedChildResources(traversalCtx, appChild)
+			if errors.Is(err, graph.ErrTraversalIncomplete) {
+				appLogger.WithError(err).Warn("Nested child resources are incomplete")
+				incomplete = true
+			} else if err != nil {
+				appLogger.WithError(err).Error("Error getting nested child resources")
 				continue
 			}
-			allAppChildren = append(allAppChildren, resources...)
+			for childResource := range childResources {
+				appResult.children = append(appResult.children, &childResource)
+			}
 		}
 	}
-	groupedKinds := make(common.GroupedResourceKinds)
-	groupedKinds.MergeResourceInfos(allAppChildren)
-	return &groupedKinds, nil
+	// Always try to augment with resources inferred from Application.status,
+	// even if graph traversal failed.
+	resources, err := argoCDClient.GetResourcesFromApplicationStatus(ctx, argoApp)
+	if err != nil {
+		appLogger.WithError(err).Error("Error getting resources from application status")
+	} else {
+		appResult.children = append(appResult.children, resources...)
+	}
+	return appResult, incomplete
+}
+
+// annotationTracking returns true if the given tracking method tracks the resources by annotation.
+// Argo CD v3 tracks by annotation unless configured otherwise.
+func annotationTracking(trackingMethod string) bool {
+	switch v1alpha1.TrackingMethod(trackingMethod) {
+	case "", argo.TrackingMethodAnnotation, argo.TrackingMethodAnnotationAndLabel:
+		return true
+	}
+	return false
+}
+
+// statusManifests returns the resources listed in the status of the given application, along with
+// the live objects of its destination cluster carrying its tracking label or annotation, which are
+// returned as tracked too. Only the status is used if trackedResources is nil.
+func statusManifests(
+	ctx context.Context,
+	argoApp *v1alpha1.Application,
+	trackedResources *kube.TrackedResources,
+	controllerNamespace string,
+	logger *log.Entry,
+) ([]*common.ResourceInfo, map[common.ResourceInfo]bool) {
+	if !argocd.HasSynced(argoApp) {
+		if len(argoApp.Status.Resources) == 0 {
+			logger.Warn("Application has never synced and lists no resources in its status, none of its kinds can be found")
+		} else {
+			logger.Warn("Application has never synced, only the kinds listed in its status are found")
+		}
+	}
+	manifests := make([]*common.ResourceInfo, 0, len(argoApp.Status.Resources))
+	listed := make(map[common.ResourceInfo]bool, len(argoApp.Status.Resources))
+	for _, resource := range argoApp.Status.Resources {
+		manifest := &common.ResourceInfo{
+			Group:     resource.Group,
+			Kind:      resource.Kind,
+			Name:      resource.Name,
+			Namespace: resource.Namespace,
+		}
+		manifests = append(manifests, manifest)
+		listed[*manifest] = true
+	}
+	if trackedResources == nil {
+		return manifests, nil
+	}
+	// Objects of kinds Argo CD does not watch are missing from the status but still carry the
+	// tracking label or annotation.
+	resources, err := trackedResources.Get(ctx, argocd.ApplicationInstanceName(argoApp, controllerNamespace))
+	if err != nil {
+		logger.WithError(err).Error("Error listing the objects tracked to the application")
+		return manifests, nil
+	}
+	tracked := make(map[common.ResourceInfo]bool)
+	for _, resource := range resources {
+		if listed[*resource] {
+			continue
+		}
+		manifests = append(manifests, resource)
+		tracked[*resource] = true
+	}
+	return manifests, tracked
 }
 
 // getQueryServerForApp resolves the destination cluster for the given Argo CD
@@ -152,7 +452,7 @@ func (b *Backend) getQueryServerForApp(
 	ctx context.Context,
 	argoCDClient argocd.ArgoCD,
 	app *v1alpha1.Application,
-	kubeConfigPath string,
+	opts analyzer.Options,
 	trackingMethod string,
 	logger *log.Entry,
 ) (*graph.QueryServer, error) {
@@ -183,7 +483,7 @@ func (b *Backend) getQueryServerForApp(
 	if err != nil {
 		return nil, fmt.Errorf("failed to get destination cluster %q: %w", server, err)
 	}
-	restCfg, err := kube.RestConfigFromCluster(cluster, kubeConfigPath)
+	restCfg, err := kube.RestConfigFromCluster(cluster, opts.KubeConfigPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build rest.Config for cluster %q: %w", server, err)
 	}
@@ -192,6 +492,9 @@ func (b *Backend) getQueryServerForApp(
 	if err != nil {
 		return nil, fmt.Errorf("failed to create query server for cluster %q: %w", server, err)
 	}
+	qs.QueryTimeout = opts.QueryTimeout
+	qs.ControlPlaneNamespace = opts.ArgoCDNamespace
+	qs.Cluster = server
 
 	b.mu.Lock()
 	b.queryServers[server] = qs
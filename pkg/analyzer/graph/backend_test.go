@@ -0,0 +1,225 @@
+package graphbackend
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/anandf/resource-tracker/pkg/analyzer"
+	"github.com/anandf/resource-tracker/pkg/argocd"
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/anandf/resource-tracker/pkg/graph"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/avitaltamir/cyphernetes/pkg/provider"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const testServer = "https://kubernetes.default.svc"
+
+// objectsProvider serves the objects of its map, by resource.
+type objectsProvider struct {
+	provider.Provider
+	gvrs    map[string]schema.GroupVersionResource
+	objects map[string][]map[string]interface{}
+}
+
+func (p *objectsProvider) FindGVR(kind string) (schema.GroupVersionResource, error) {
+	for name, gvr := range p.gvrs {
+		if strings.EqualFold(name, kind) || strings.EqualFold(gvr.Resource, kind) {
+			return gvr, nil
+		}
+	}
+	return schema.GroupVersionResource{}, fmt.Errorf("kind %s not found", kind)
+}
+
+func (p *objectsProvider) GetK8sResources(kind, fieldSelector, labelSelector, namespace string) (interface{}, error) {
+	gvr, err := p.FindGVR(kind)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]map[string]interface{}, 0)
+	for _, object := range p.objects[gvr.Resource] {
+		name := object["metadata"].(map[string]interface{})["name"].(string)
+		if fieldSelector == "" || fieldSelector == "metadata.name="+name {
+			objects = append(objects, object)
+		}
+	}
+	return objects, nil
+}
+
+// manifestsArgoCD renders the manifests of its map, by application name.
+type manifestsArgoCD struct {
+	argocd.ArgoCD
+	manifests map[string][]*common.ResourceInfo
+}
+
+func (a *manifestsArgoCD) GetApplicationChildManifests(ctx context.Context, app *v1alpha1.Application, kubeconfig, server string) ([]*common.ResourceInfo, error) {
+	return a.manifests[app.Name], nil
+}
+
+func (a *manifestsArgoCD) GetResourcesFromApplicationStatus(ctx context.Context, app *v1alpha1.Application) ([]*common.ResourceInfo, error) {
+	return nil, nil
+}
+
+func testObject(apiVersion, kind, name string, fields map[string]interface{}) map[string]interface{} {
+	object := map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+	}
+	for field, value := range fields {
+		object[field] = value
+	}
+	return object
+}
+
+func testApplication(name string) *v1alpha1.Application {
+	return &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "argocd"},
+		Spec: v1alpha1.ApplicationSpec{
+			Project:     name,
+			Destination: v1alpha1.ApplicationDestination{Server: testServer, Namespace: "default"},
+		},
+	}
+}
+
+// analyzeTestApps analyzes two applications deploying a Deployment each, on a cluster where both
+// Deployments own a ReplicaSet and the Deployment of payments is scaled by a
+// HorizontalPodAutoscaler.
+func analyzeTestApps(t *testing.T) []appAnalysis {
+	t.Helper()
+	p := &objectsProvider{
+		gvrs: map[string]schema.GroupVersionResource{
+			"Deployment":              {Group: "apps", Version: "v1", Resource: "deployments"},
+			"ReplicaSet":              {Group: "apps", Version: "v1", Resource: "replicasets"},
+			"HorizontalPodAutoscaler": {Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"},
+		},
+		objects: map[string][]map[string]interface{}{
+			"deployments": {
+				testObject("apps/v1", "Deployment", "guestbook-ui", nil),
+				testObject("apps/v1", "Deployment", "payments-api", nil),
+			},
+			"replicasets": {
+				testObject("apps/v1", "ReplicaSet", "guestbook-ui-6b7f", map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"name":            "guestbook-ui-6b7f",
+						"namespace":       "default",
+						"ownerReferences": []interface{}{map[string]interface{}{"name": "guestbook-ui"}},
+					},
+				}),
+				testObject("apps/v1", "ReplicaSet", "payments-api-5d9c", map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"name":            "payments-api-5d9c",
+						"namespace":       "default",
+						"ownerReferences": []interface{}{map[string]interface{}{"name": "payments-api"}},
+					},
+				}),
+			},
+			"horizontalpodautoscalers": {
+				testObject("autoscaling/v2", "HorizontalPodAutoscaler", "payments-api", map[string]interface{}{
+					"spec": map[string]interface{}{"scaleTargetRef": map[string]interface{}{"name": "payments-api"}},
+				}),
+			},
+		},
+	}
+	qs, err := graph.NewProviderQueryServer(p, graph.TrackingMethodAnnotation)
+	require.NoError(t, err)
+	qs.Cluster = testServer
+	b := NewBackend()
+	b.queryServers[testServer] = qs
+	ac := &manifestsArgoCD{manifests: map[string][]*common.ResourceInfo{
+		"guestbook": {{Group: "apps", Kind: "Deployment", Name: "guestbook-ui", Namespace: "default"}},
+		"payments":  {{Group: "apps", Kind: "Deployment", Name: "payments-api", Namespace: "default"}},
+	}}
+	apps := []*v1alpha1.Application{testApplication("guestbook"), testApplication("payments")}
+
+	results, incomplete := b.analyzeApps(context.Background(), context.Background(), ac, apps, analyzer.Options{}, graph.TrackingMethodAnnotation, log.NewEntry(log.StandardLogger()))
+	require.False(t, incomplete)
+	require.Len(t, results, 2)
+	return results
+}
+
+func Test_analyzeApps(t *testing.T) {
+	results := analyzeTestApps(t)
+	kinds := func(appResult appAnalysis) common.GroupedResourceKinds {
+		groupedKinds := make(common.GroupedResourceKinds)
+		groupedKinds.MergeResourceInfos(appResult.children)
+		return groupedKinds
+	}
+
+	// The Deployment kind visited for guestbook is visited again for payments
+	assert.Contains(t, kinds(results[0])["apps"], "ReplicaSet")
+	assert.NotContains(t, kinds(results[0]), "autoscaling")
+	assert.Contains(t, kinds(results[1])["autoscaling"], "HorizontalPodAutoscaler")
+
+	report := analyzer.NewAppSetReport("argocd/team", map[string]common.GroupedResourceKinds{
+		"argocd/guestbook": kinds(results[0]),
+		"argocd/payments":  kinds(results[1]),
+	})
+	assert.Equal(t, common.GroupedResourceKinds{"autoscaling": {"HorizontalPodAutoscaler": {}}}, report.Unique["argocd/payments"])
+}
+
+// projectsArgoCD holds the AppProjects by name, its clusters are unknown.
+type projectsArgoCD struct {
+	argocd.ArgoCD
+	projects map[string]*v1alpha1.AppProject
+}
+
+func (p *projectsArgoCD) GetAppProject(app *v1alpha1.Application) (*v1alpha1.AppProject, error) {
+	return p.projects[app.Spec.GetProject()], nil
+}
+
+func (p *projectsArgoCD) GetAppCluster(ctx context.Context, server string) (*v1alpha1.Cluster, error) {
+	return nil, fmt.Errorf("cluster %q not found", server)
+}
+
+func Test_appKinds(t *testing.T) {
+	results := analyzeTestApps(t)
+	ac := &projectsArgoCD{projects: map[string]*v1alpha1.AppProject{
+		"guestbook": {ObjectMeta: metav1.ObjectMeta{Name: "guestbook"}, Spec: v1alpha1.AppProjectSpec{
+			NamespaceResourceWhitelist: []metav1.GroupKind{{Group: "*", Kind: "*"}},
+		}},
+		"payments": {ObjectMeta: metav1.ObjectMeta{Name: "payments"}, Spec: v1alpha1.AppProjectSpec{
+			NamespaceResourceWhitelist: []metav1.GroupKind{{Group: "*", Kind: "*"}},
+			NamespaceResourceBlacklist: []metav1.GroupKind{{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}},
+		}},
+	}}
+
+	reports, err := analyzer.CheckProjects(context.Background(), ac, "", appKinds(results))
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	assert.Equal(t, "payments", reports[1].Project)
+	// The HorizontalPodAutoscaler is only reached from a Deployment, whose kind was visited for
+	// guestbook first. It is required by payments, but not synced by Argo CD so not forbidden.
+	assert.Contains(t, reports[1].Kinds["autoscaling"], "HorizontalPodAutoscaler")
+	assert.Empty(t, reports[1].Forbidden)
+	assert.NotContains(t, reports[0].Kinds, "autoscaling")
+}
+
+func Test_chains(t *testing.T) {
+	results := analyzeTestApps(t)
+
+	// The ReplicaSet of payments is reached through the Deployment kind visited for guestbook
+	chains := NewBackend().chains(results, "apps", "ReplicaSet")
+	require.Len(t, chains, 2)
+	assert.Equal(t, "argocd/guestbook", chains[0].Application)
+	require.Len(t, chains[0].Links, 2)
+	assert.Equal(t, "guestbook-ui-6b7f", chains[0].Links[1].Child.Name)
+	assert.Equal(t, "argocd/payments", chains[1].Application)
+	require.Len(t, chains[1].Links, 2)
+	assert.Equal(t, common.ResourceInfo{Group: "apps", Kind: "Deployment", Name: "payments-api", Namespace: "default"}, chains[1].Links[1].Parent)
+	assert.Equal(t, "payments-api-5d9c", chains[1].Links[1].Child.Name)
+	assert.Equal(t, common.SourceOwnerReference, chains[1].Links[1].Source)
+}
+
+func Test_annotationTracking(t *testing.T) {
+	assert.True(t, annotationTracking(""))
+	assert.True(t, annotationTracking("annotation"))
+	assert.True(t, annotationTracking("annotation+label"))
+	assert.False(t, annotationTracking("label"))
+}
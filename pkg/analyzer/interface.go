@@ -2,7 +2,12 @@ package analyzer
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/anandf/resource-tracker/pkg/argocd"
 	"github.com/anandf/resource-tracker/pkg/common"
 	"k8s.io/client-go/rest"
 )
@@ -23,9 +28,26 @@ type Options struct {
 	// TargetApp is the specific app to analyze. If empty, analyze all applications.
 	TargetApp string
 
-	// TargetAppNamespace is the namespace of the target application.
+	// TargetAppNamespace is the namespace of the target application, ArgoCDNamespace if empty.
+	// When analyzing all applications and AppSelection has no namespaces, an empty namespace
+	// selects every namespace enabled by application.namespaces in argocd-cmd-params-cm.
 	TargetAppNamespace string
 
+	// AppSelection selects the applications to analyze when TargetApp is empty.
+	AppSelection argocd.ApplicationSelection
+
+	// AppSet is the ApplicationSet whose applications are analyzed, as name or namespace/name.
+	// It takes precedence over TargetApp and AppSelection.
+	AppSet string
+
+	// AppSetPreview makes the applications of AppSet rendered from its template instead of
+	// listed, so that they are analyzed before they exist.
+	AppSetPreview bool
+
+	// AppOfAppsDepth is the number of levels of Applications, found in the manifests of the
+	// analyzed applications, whose own manifests are analyzed along with them. Zero disables it.
+	AppOfAppsDepth int
+
 	// RepoServerAddress is the address of the Argo CD repo-server (host:port).
 	RepoServerAddress string
 
@@ -39,9 +61,91 @@ type Options struct {
 
 	// RepoServerTimeoutSeconds is the timeout for repo-server RPC calls.
 	RepoServerTimeoutSeconds int
+
+	// ManifestCacheDir is the directory where the manifests generated by the repo-server are
+	// cached by resolved revision across runs. Empty caches them in memory only.
+	ManifestCacheDir string
+
+	// LocalCheckoutDir is the directory where the repositories of the applications are checked
+	// out to render their manifests locally when the repo-server cannot be used. Empty disables
+	// local rendering.
+	LocalCheckoutDir string
+
+	// QueryTimeout is the time allowed for a single graph query. Zero means no timeout.
+	QueryTimeout time.Duration
+
+	// TraversalTimeout is the total time budget for traversing the children of the analyzed
+	// applications. Zero means no budget.
+	TraversalTimeout time.Duration
+
+	// WatchRelations makes the dynamic backend keep its relation cache current using metadata
+	// watches on the destination clusters, instead of scanning a cluster when a kind is missing.
+	// The watches outlive an execution, so it is only useful to a backend executed repeatedly.
+	WatchRelations bool
+
+	// ClusterQPS and ClusterBurst limit the requests made to each destination cluster.
+	// Zero selects the defaults of the dynamic backend.
+	ClusterQPS   float32
+	ClusterBurst int
+
+	// ScanWorkers is the number of resources listed in parallel while scanning a cluster.
+	ScanWorkers int
+
+	// AppConcurrency is the number of applications analyzed in parallel. Zero selects the default.
+	AppConcurrency int
+
+	// RelationTTL is the time after which a relation found by the dynamic backend is evicted if
+	// it was not seen again. Zero keeps relations forever. The relations outlive an execution, so
+	// it is only useful to a backend executed repeatedly.
+	RelationTTL time.Duration
 }
 
+// ErrIncompleteResult is returned by Backend.Execute along with the partial result, when the
+// analysis could not be completed within its time budget.
+var ErrIncompleteResult = errors.New("analysis result is incomplete")
+
 // Backend is the common interface that both CLI and Operator code can use.
+// If the analysis is stopped early, Execute returns the partial result along with an error
+// wrapping ErrIncompleteResult.
 type Backend interface {
 	Execute(ctx context.Context, opts Options) (*common.GroupedResourceKinds, error)
 }
+
+// Chain is a chain of relations linking an Application to a resource it requires to be watched,
+// the relation from the Application coming first.
+type Chain struct {
+	// Application is the namespace/name of the Application.
+	Application string
+	Links       []common.Provenance
+}
+
+func (c *Chain) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Application %s", c.Application)
+	for i := range c.Links {
+		fmt.Fprintf(&sb, "\n  %s", c.Links[i].String())
+	}
+	return sb.String()
+}
+
+// Explainer is implemented by the backends which can tell why a resource kind is part of the
+// analysis result.
+type Explainer interface {
+	// Explain returns the chains of relations through which the analyzed applications lead to
+	// resources of the given group and kind, the empty group standing for the core group.
+	Explain(ctx context.Context, opts Options, group, kind string) ([]Chain, error)
+}
+
+// KindsDiscoverer is implemented by the backends which can tell the kinds served by the
+// destination clusters of the analyzed applications, to compact the resource inclusions.
+type KindsDiscoverer interface {
+	// ServedKinds returns the kinds served by the destination clusters met by the previous
+	// analyses which can be listed and watched, by group.
+	ServedKinds() (common.GroupedResourceKinds, error)
+}
+
+// Stopper is implemented by the backends which run background work, such as informers on the
+// destination clusters, which has to be stopped once the backend is no longer used.
+type Stopper interface {
+	Stop()
+}
@@ -0,0 +1,235 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/anandf/resource-tracker/pkg/argocd"
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/anandf/resource-tracker/pkg/kube"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/argo-cd/v3/util/glob"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+// AppKinds holds the resources found for an application by a backend.
+type AppKinds struct {
+	Application *v1alpha1.Application
+	// Manifests are the resources Argo CD syncs for the application.
+	Manifests []*common.ResourceInfo
+	// Resources are all the resources whose kinds the application requires.
+	Resources []*common.ResourceInfo
+}
+
+// ProjectReport is the result of the check of the applications of an AppProject against its
+// resource lists.
+type ProjectReport struct {
+	Project      string
+	Applications []string
+	// Kinds holds the kinds the applications of the project require, including OrphanedKinds.
+	Kinds common.GroupedResourceKinds
+	// Forbidden holds, for each application, the kinds of its manifests the project does not
+	// permit, as group/kind or kind for the core group. Syncing the application fails on them.
+	Forbidden map[string][]string
+	// OrphanedKinds holds the kinds of the objects in the destination namespaces of the
+	// applications, which are needed to monitor their orphaned resources.
+	OrphanedKinds common.GroupedResourceKinds
+	// Errors holds, for each application which could not be checked against the project, the
+	// error met. The kinds the application requires are part of Kinds anyway.
+	Errors map[string]string
+}
+
+func (r *ProjectReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "AppProject %s: %d applications\nresource.inclusions: |\n%s", r.Project, len(r.Applications), r.Kinds.String())
+	if len(r.OrphanedKinds) > 0 {
+		fmt.Fprintf(&sb, "Kinds added to monitor orphaned resources:\n%s", r.OrphanedKinds.String())
+	}
+	for _, app := range slices.Sorted(maps.Keys(r.Forbidden)) {
+		fmt.Fprintf(&sb, "Application %s has kinds forbidden by the project: %s\n", app, strings.Join(r.Forbidden[app], ", "))
+	}
+	for _, app := range slices.Sorted(maps.Keys(r.Errors)) {
+		fmt.Fprintf(&sb, "Application %s could not be checked: %s\n", app, r.Errors[app])
+	}
+	return sb.String()
+}
+
+// ProjectChecker is implemented by the backends which can check the kinds the analyzed
+// applications require against their AppProjects.
+type ProjectChecker interface {
+	// CheckProjects analyzes the applications selected by the given options, and returns the
+	// report of each of their AppProjects, sorted by project name.
+	CheckProjects(ctx context.Context, opts Options) ([]ProjectReport, error)
+}
+
+// CheckProjects checks the kinds of the manifests of the given applications against the resource
+// lists of their AppProjects, and adds the kinds needed to monitor orphaned resources for the
+// projects which enable it. The destination clusters are queried for the scope of the kinds and
+// the objects of the destination namespaces. An application whose AppProject cannot be fetched is
+// reported with its error under the project it names.
+func CheckProjects(ctx context.Context, ac argocd.ArgoCD, kubeconfigPath string, apps []AppKinds) ([]ProjectReport, error) {
+	clusters := make(map[string]*clusterInfo)
+	reports := make(map[string]*ProjectReport)
+	for _, app := range apps {
+		appName := ApplicationName(app.Application)
+		appLogger := log.WithField("application", appName)
+		proj, err := ac.GetAppProject(app.Application)
+		if err != nil {
+			appLogger.WithError(err).Warn("Error getting the AppProject, the application is not checked against it")
+			report := projectReport(reports, app.Application.Spec.GetProject())
+			report.Applications = append(report.Applications, appName)
+			report.Kinds.MergeResourceInfos(app.Resources)
+			report.Errors[appName] = err.Error()
+			continue
+		}
+		report := projectReport(reports, proj.Name)
+		report.Applications = append(report.Applications, appName)
+		report.Kinds.MergeResourceInfos(app.Resources)
+
+		cluster, err := getClusterInfo(ctx, ac, kubeconfigPath, app.Application, clusters)
+		if err != nil {
+			appLogger.WithError(err).Warn("Error getting destination cluster, the scope of the kinds is guessed from the manifests")
+			cluster = &clusterInfo{}
+		}
+		if forbidden := ForbiddenKinds(proj, app.Manifests, cluster.scopes); len(forbidden) > 0 {
+			report.Forbidden[appName] = forbidden
+		}
+		namespace := app.Application.Spec.Destination.Namespace
+		if proj.Spec.OrphanedResources == nil || namespace == "" || cluster.restConfig == nil {
+			continue
+		}
+		kinds, err := cluster.namespaceKinds(ctx, namespace)
+		if err != nil {
+			appLogger.WithError(err).Warn("Error listing the kinds of the destination namespace")
+			continue
+		}
+		orphaned := OrphanedKinds(proj.Spec.OrphanedResources, kinds)
+		report.OrphanedKinds.MergeResourceInfos(orphaned)
+		report.Kinds.MergeResourceInfos(orphaned)
+	}
+	result := make([]ProjectReport, 0, len(reports))
+	for _, name := range slices.Sorted(maps.Keys(reports)) {
+		slices.Sort(reports[name].Applications)
+		result = append(result, *reports[name])
+	}
+	return result, nil
+}
+
+// projectReport returns the report of the given project, creating it if needed.
+func projectReport(reports map[string]*ProjectReport, project string) *ProjectReport {
+	report, ok := reports[project]
+	if !ok {
+		report = &ProjectReport{
+			Project:       project,
+			Kinds:         make(common.GroupedResourceKinds),
+			Forbidden:     make(map[string][]string),
+			OrphanedKinds: make(common.GroupedResourceKinds),
+			Errors:        make(map[string]string),
+		}
+		reports[project] = report
+	}
+	return report
+}
+
+// ForbiddenKinds returns, sorted and as group/kind or kind for the core group, the kinds of the given manifests the project
+// does not permit. The scope of a kind missing from scopes is guessed from the namespace of the
+// manifest.
+func ForbiddenKinds(proj *v1alpha1.AppProject, manifests []*common.ResourceInfo, scopes map[schema.GroupKind]bool) []string {
+	var forbidden []string
+	for _, manifest := range manifests {
+		group := manifest.Group
+		if group == "core" {
+			group = ""
+		}
+		gk := schema.GroupKind{Group: group, Kind: manifest.Kind}
+		namespaced, ok := scopes[gk]
+		if !ok {
+			namespaced = manifest.Namespace != ""
+		}
+		if proj.IsGroupKindPermitted(gk, namespaced) {
+			continue
+		}
+		if gk.Group == "" {
+			forbidden = append(forbidden, gk.Kind)
+		} else {
+			forbidden = append(forbidden, fmt.Sprintf("%s/%s", gk.Group, gk.Kind))
+		}
+	}
+	return slices.Compact(slices.Sorted(slices.Values(forbidden)))
+}
+
+// OrphanedKinds returns the given kinds, except those ignored for every name by the given
+// orphaned resources monitoring settings.
+func OrphanedKinds(settings *v1alpha1.OrphanedResourcesMonitorSettings, kinds []schema.GroupKind) []*common.ResourceInfo {
+	var result []*common.ResourceInfo
+	for _, gk := range kinds {
+		ignored := slices.ContainsFunc(settings.Ignore, func(key v1alpha1.OrphanedResourceKey) bool {
+			return (key.Name == "" || key.Name == "*") && glob.Match(key.Group, gk.Group) && glob.Match(key.Kind, gk.Kind)
+		})
+		if !ignored {
+			result = append(result, &common.ResourceInfo{Group: gk.Group, Kind: gk.Kind})
+		}
+	}
+	return result
+}
+
+// clusterInfo holds what is known about a destination cluster.
+type clusterInfo struct {
+	restConfig *rest.Config
+	// scopes tells whether the resources of each kind are namespaced
+	scopes map[schema.GroupKind]bool
+	// kinds holds the kinds of the objects of each namespace
+	kinds map[string][]schema.GroupKind
+}
+
+// getClusterInfo returns the destination cluster of the given application, from the cache if it
+// was already queried.
+func getClusterInfo(ctx context.Context, ac argocd.ArgoCD, kubeconfigPath string, app *v1alpha1.Application, cache map[string]*clusterInfo) (*clusterInfo, error) {
+	server := app.Spec.Destination.Server
+	if server == "" {
+		if app.Spec.Destination.Name == "" {
+			return nil, fmt.Errorf("both destination server and name are empty for application %q", app.Name)
+		}
+		var err error
+		if server, err = ac.GetApplicationClusterServerByName(ctx, app.Spec.Destination.Name); err != nil {
+			return nil, err
+		}
+	}
+	if info, ok := cache[server]; ok {
+		return info, nil
+	}
+	cluster, err := ac.GetAppCluster(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	restConfig, err := kube.RestConfigFromCluster(cluster, kubeconfigPath)
+	if err != nil {
+		return nil, err
+	}
+	scopes, err := kube.KindScopes(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	info := &clusterInfo{restConfig: restConfig, scopes: scopes, kinds: make(map[string][]schema.GroupKind)}
+	cache[server] = info
+	return info, nil
+}
+
+// namespaceKinds returns the kinds of the objects of the given namespace, from the cache if it
+// was already listed.
+func (c *clusterInfo) namespaceKinds(ctx context.Context, namespace string) ([]schema.GroupKind, error) {
+	if kinds, ok := c.kinds[namespace]; ok {
+		return kinds, nil
+	}
+	kinds, err := kube.NamespaceKinds(ctx, c.restConfig, namespace)
+	if err != nil {
+		return nil, err
+	}
+	c.kinds[namespace] = kinds
+	return kinds, nil
+}
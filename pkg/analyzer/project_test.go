@@ -0,0 +1,109 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/anandf/resource-tracker/pkg/argocd"
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_ForbiddenKinds(t *testing.T) {
+	proj := &v1alpha1.AppProject{
+		ObjectMeta: metav1.ObjectMeta{Name: "payments"},
+		Spec: v1alpha1.AppProjectSpec{
+			ClusterResourceWhitelist:   []metav1.GroupKind{{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}},
+			NamespaceResourceBlacklist: []metav1.GroupKind{{Group: "", Kind: "ResourceQuota"}},
+		},
+	}
+	manifests := []*common.ResourceInfo{
+		{Group: "apps", Kind: "Deployment", Name: "guestbook-ui", Namespace: "guestbook"},
+		{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "guestbook"},
+		{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding", Name: "guestbook"},
+		{Group: "core", Kind: "ResourceQuota", Name: "guestbook"},
+		{Group: "core", Kind: "ResourceQuota", Name: "guestbook-pods"},
+		{Group: "example.com", Kind: "Widget", Name: "guestbook"},
+	}
+	scopes := map[schema.GroupKind]bool{
+		{Group: "apps", Kind: "Deployment"}:                              true,
+		{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:        false,
+		{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}: false,
+		{Group: "", Kind: "ResourceQuota"}:                               true,
+	}
+
+	// The scope of the kinds unknown to the cluster is guessed from the manifests
+	assert.Equal(t, []string{"ResourceQuota", "example.com/Widget", "rbac.authorization.k8s.io/ClusterRoleBinding"}, ForbiddenKinds(proj, manifests, scopes))
+}
+
+func Test_OrphanedKinds(t *testing.T) {
+	settings := &v1alpha1.OrphanedResourcesMonitorSettings{Ignore: []v1alpha1.OrphanedResourceKey{
+		{Group: "", Kind: "ConfigMap", Name: "kube-root-ca.crt"},
+		{Group: "", Kind: "ServiceAccount"},
+		{Group: "*.example.com", Kind: "*"},
+	}}
+	kinds := []schema.GroupKind{
+		{Group: "", Kind: "ConfigMap"},
+		{Group: "", Kind: "ServiceAccount"},
+		{Group: "widgets.example.com", Kind: "Widget"},
+		{Group: "apps", Kind: "Deployment"},
+	}
+	assert.Equal(t, []*common.ResourceInfo{
+		{Group: "", Kind: "ConfigMap"},
+		{Group: "apps", Kind: "Deployment"},
+	}, OrphanedKinds(settings, kinds))
+}
+
+// projectsArgoCD holds the AppProjects by name.
+type projectsArgoCD struct {
+	argocd.ArgoCD
+	projects map[string]*v1alpha1.AppProject
+}
+
+func (p *projectsArgoCD) GetAppProject(app *v1alpha1.Application) (*v1alpha1.AppProject, error) {
+	proj, ok := p.projects[app.Spec.GetProject()]
+	if !ok {
+		return nil, fmt.Errorf("appprojects.argoproj.io %q not found", app.Spec.GetProject())
+	}
+	return proj, nil
+}
+
+func Test_CheckProjects(t *testing.T) {
+	ac := &projectsArgoCD{projects: map[string]*v1alpha1.AppProject{
+		"default": {ObjectMeta: metav1.ObjectMeta{Name: "default"}, Spec: v1alpha1.AppProjectSpec{
+			NamespaceResourceWhitelist: []metav1.GroupKind{{Group: "*", Kind: "*"}},
+		}},
+	}}
+	deployment := &common.ResourceInfo{Group: "apps", Kind: "Deployment", Name: "guestbook-ui", Namespace: "guestbook"}
+	widget := &common.ResourceInfo{Group: "example.com", Kind: "Widget", Name: "payments", Namespace: "payments"}
+	apps := []AppKinds{
+		{
+			Application: &v1alpha1.Application{ObjectMeta: metav1.ObjectMeta{Name: "guestbook", Namespace: "argocd"}, Spec: v1alpha1.ApplicationSpec{Project: "default"}},
+			Manifests:   []*common.ResourceInfo{deployment},
+			Resources:   []*common.ResourceInfo{deployment},
+		},
+		{
+			Application: &v1alpha1.Application{ObjectMeta: metav1.ObjectMeta{Name: "payments", Namespace: "argocd"}, Spec: v1alpha1.ApplicationSpec{Project: "payments"}},
+			Manifests:   []*common.ResourceInfo{widget},
+			Resources:   []*common.ResourceInfo{widget},
+		},
+	}
+
+	// The application whose project is missing is reported without aborting the check
+	reports, err := CheckProjects(context.Background(), ac, "", apps)
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	assert.Equal(t, "default", reports[0].Project)
+	assert.Equal(t, []string{"argocd/guestbook"}, reports[0].Applications)
+	assert.Empty(t, reports[0].Errors)
+	assert.Equal(t, "payments", reports[1].Project)
+	assert.Equal(t, []string{"argocd/payments"}, reports[1].Applications)
+	assert.Equal(t, common.GroupedResourceKinds{"example.com": {"Widget": {}}}, reports[1].Kinds)
+	assert.Contains(t, reports[1].Errors["argocd/payments"], "not found")
+	assert.Contains(t, reports[1].String(), "Application argocd/payments could not be checked")
+}
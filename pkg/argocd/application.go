@@ -32,16 +32,22 @@ const (
 // ArgoCD is the interface for accessing Argo CD functions we need
 type ArgoCD interface {
 	ListApplications() ([]v1alpha1.Application, error)
+	SelectApplications(ctx context.Context, selection ApplicationSelection) ([]v1alpha1.Application, error)
 	GetApplication(name string) (*v1alpha1.Application, error)
+	GetApplicationSet(ctx context.Context, namespace, name string) (*v1alpha1.ApplicationSet, error)
+	GetApplicationSetApplications(ctx context.Context, appSet *v1alpha1.ApplicationSet) ([]v1alpha1.Application, error)
+	PreviewApplicationSetApplications(ctx context.Context, appSet *v1alpha1.ApplicationSet) ([]v1alpha1.Application, error)
 	GetAppProject(app *v1alpha1.Application) (*v1alpha1.AppProject, error)
 	GetApplicationClusterServerByName(ctx context.Context, clusterName string) (string, error)
 	GetResourcesFromApplicationStatus(ctx context.Context, application *v1alpha1.Application) ([]*common.ResourceInfo, error)
-	GetAllMissingResources() ([]*common.ResourceInfo, error)
+	GetAllMissingResources(selection ApplicationSelection) ([]*common.ResourceInfo, error)
 	GetApplicationChildManifests(ctx context.Context, application *v1alpha1.Application, kubeconfig string, server string) ([]*common.ResourceInfo, error)
+	GetApplicationTreeManifests(ctx context.Context, application *v1alpha1.Application, kubeconfig string, maxDepth int, skip func(*v1alpha1.Application) bool) ([]ApplicationManifests, error)
 	GetTrackingMethod() (string, error)
 	GetAppCluster(ctx context.Context, server string) (*v1alpha1.Cluster, error)
 	GetCurrentResourceInclusions(gvr *schema.GroupVersionResource, resourceName, resourceNamespace string) (string, error)
 	UpdateResourceInclusions(gvr *schema.GroupVersionResource, resourceName, resourceNamespace, resourceInclusionYaml string) error
+	RecordRemediation(gvr *schema.GroupVersionResource, resourceName, resourceNamespace string, remediation common.Remediation) error
 }
 
 // Kubernetes based client
@@ -58,8 +64,10 @@ type argocd struct {
 }
 
 // NewArgoCD creates a new kube client to interact with kube api-server and
-// configures access to the Argo CD repo-server.
-func NewArgoCD(config *rest.Config, argocdNS string, applicationNS string, repoServerAddress string, repoServerTimeoutSeconds int, repoServerPlaintext, repoServerStrictTLS bool) (ArgoCD, error) {
+// configures access to the Argo CD repo-server. Unless localCheckoutDir is
+// empty, manifests are rendered from local git checkouts when the repo-server
+// cannot be used.
+func NewArgoCD(config *rest.Config, argocdNS string, applicationNS string, repoServerAddress string, repoServerTimeoutSeconds int, repoServerPlaintext, repoServerStrictTLS bool, manifestCacheDir, localCheckoutDir string) (ArgoCD, error) {
 	resourceTrackerConfig, err := kube.NewKubernetesClientFromConfig(context.Background(), argocdNS, config)
 	if err != nil {
 		return nil, fmt.Errorf("could not create K8s client: %w", err)
@@ -76,7 +84,7 @@ func NewArgoCD(config *rest.Config, argocdNS string, applicationNS string, repoS
 		return nil, fmt.Errorf("could not create dynamic client: %w", err)
 	}
 	dbInstance := db.NewDB(argocdNS, settingsMgr, resourceTrackerConfig.KubeClient.Clientset)
-	repoServerManager, err := repo.NewRepoServerManager(config, argocdNS, repoServerAddress, repoServerTimeoutSeconds, repoServerPlaintext, repoServerStrictTLS)
+	repoServerManager, err := repo.NewRepoServerManager(config, argocdNS, repoServerAddress, repoServerTimeoutSeconds, repoServerPlaintext, repoServerStrictTLS, manifestCacheDir, localCheckoutDir)
 	if err != nil {
 		return nil, fmt.Errorf("could not create repo server manager: %w", err)
 	}
@@ -95,17 +103,16 @@ func NewArgoCD(config *rest.Config, argocdNS string, applicationNS string, repoS
 
 // ListApplications lists all applications across all namespaces.
 func (a *argocd) ListApplications() ([]v1alpha1.Application, error) {
-	ns := a.applicationNamespace
-	list, err := a.applicationClientSet.ArgoprojV1alpha1().Applications(ns).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("error listing applications: %w", err)
-	}
-	return list.Items, nil
+	return a.SelectApplications(context.TODO(), ApplicationSelection{})
 }
 
-// GetApplication lists all applications across all namespaces.
+// GetApplication returns the application of the given name in the application namespace of the
+// client, or in the control-plane namespace if it has none.
 func (a *argocd) GetApplication(name string) (*v1alpha1.Application, error) {
 	ns := a.applicationNamespace
+	if ns == "" {
+		ns = a.kubeClient.Namespace
+	}
 	application, err := a.applicationClientSet.ArgoprojV1alpha1().Applications(ns).Get(context.TODO(), name, metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("error getting application %s: %w", name, err)
@@ -113,21 +120,26 @@ func (a *argocd) GetApplication(name string) (*v1alpha1.Application, error) {
 	return application, nil
 }
 
-// GetAppProject get the associated AppProject for a given Argo CD Application.
+// GetAppProject get the associated AppProject for a given Argo CD Application. AppProjects live in
+// the control-plane namespace, whatever the namespace of the application, which must be one of
+// the source namespaces of the project if it is not the control-plane namespace.
 func (a *argocd) GetAppProject(app *v1alpha1.Application) (*v1alpha1.AppProject, error) {
 	// Fetch AppProject
-	appProject, err := a.applicationClientSet.ArgoprojV1alpha1().AppProjects(a.kubeClient.Namespace).Get(context.Background(), app.Spec.Project, metav1.GetOptions{})
+	appProject, err := a.applicationClientSet.ArgoprojV1alpha1().AppProjects(a.kubeClient.Namespace).Get(context.Background(), app.Spec.GetProject(), metav1.GetOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch AppProject %s for application %s: %w", app.Spec.Project, app.Name, err)
+		return nil, fmt.Errorf("failed to fetch AppProject %s for application %s: %w", app.Spec.GetProject(), app.Name, err)
+	}
+	if !appProject.IsAppNamespacePermitted(app, a.kubeClient.Namespace) {
+		return nil, fmt.Errorf("application %s/%s is not permitted in the namespace of AppProject %s", app.Namespace, app.Name, appProject.Name)
 	}
 	log.Infof("Fetched AppProject: %s for application: %s", app.Spec.Project, app.Name)
 	return appProject, err
 }
 
-// GetAllMissingResources returns the missing resources across all applications
-func (a *argocd) GetAllMissingResources() ([]*common.ResourceInfo, error) {
+// GetAllMissingResources returns the missing resources across the selected applications
+func (a *argocd) GetAllMissingResources(selection ApplicationSelection) ([]*common.ResourceInfo, error) {
 	allMissingResources := make([]*common.ResourceInfo, 0)
-	appList, err := a.ListApplications()
+	appList, err := a.SelectApplications(context.TODO(), selection)
 	if err != nil {
 		return nil, err
 	}
@@ -147,7 +159,8 @@ func (a *argocd) GetTrackingMethod() (string, error) {
 	return a.settingsManager.GetTrackingMethod()
 }
 
-// UpdateResourceInclusions updates the resource.inclusions and resource.exclusions settings either in argocd-cm configmap or ArgoCD Custom Resource
+// UpdateResourceInclusions updates the resource.inclusions and resource.exclusions settings either in argocd-cm configmap or ArgoCD Custom Resource.
+// The hash of the resource inclusions is recorded in an annotation, and the update is skipped if they are unchanged.
 func (a *argocd) UpdateResourceInclusions(gvr *schema.GroupVersionResource, resourceName, resourceNamespace, resourceInclusionYaml string) error {
 	ctx := context.Background()
 	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
@@ -155,6 +168,21 @@ func (a *argocd) UpdateResourceInclusions(gvr *schema.GroupVersionResource, reso
 		if err != nil {
 			return fmt.Errorf("error fetching ConfigMap: %v", err)
 		}
+		// skip the write if the resource inclusions were last written with the same content and
+		// were not modified since.
+		hash := common.ContentHash(resourceInclusionYaml)
+		currentInclusions, _, _ := unstructured.NestedString(resource.Object, getResourceInclusionsHierarchy(gvr)...)
+		currentExclusions, _, _ := unstructured.NestedString(resource.Object, getResourceExclusionsHierarchy(gvr)...)
+		if resource.GetAnnotations()[common.ResourceInclusionsHashAnnotation] == hash && common.ContentHash(currentInclusions) == hash && currentExclusions == "" {
+			log.Infof("Resource inclusions in %s/%s are unchanged (hash %s), skipping the update.", resourceName, resourceNamespace, hash)
+			return nil
+		}
+		annotations := resource.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[common.ResourceInclusionsHashAnnotation] = hash
+		resource.SetAnnotations(annotations)
 
 		if err := unstructured.SetNestedField(resource.Object, resourceInclusionYaml, getResourceInclusionsHierarchy(gvr)...); err != nil {
 			return fmt.Errorf("failed to set resource.inclusions value: %v", err)
@@ -176,6 +204,30 @@ func (a *argocd) UpdateResourceInclusions(gvr *schema.GroupVersionResource, reso
 	})
 }
 
+// RecordRemediation records the given remediation in the remediated kinds annotation of the
+// argocd-cm configmap or ArgoCD Custom Resource holding the resource inclusions.
+func (a *argocd) RecordRemediation(gvr *schema.GroupVersionResource, resourceName, resourceNamespace string, remediation common.Remediation) error {
+	ctx := context.Background()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		resource, err := a.dynamicClient.Resource(*gvr).Namespace(resourceNamespace).Get(ctx, resourceName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("error fetching %s/%s: %v", resourceNamespace, resourceName, err)
+		}
+		annotations := resource.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		remediations, err := common.AppendRemediation(annotations[common.RemediatedKindsAnnotation], remediation)
+		if err != nil {
+			return err
+		}
+		annotations[common.RemediatedKindsAnnotation] = remediations
+		resource.SetAnnotations(annotations)
+		_, err = a.dynamicClient.Resource(*gvr).Namespace(resourceNamespace).Update(ctx, resource, metav1.UpdateOptions{})
+		return err
+	})
+}
+
 // GetCurrentResourceInclusions returns the resource.inclusions from argocd-cm configmap or ArgoCD Custom Resource.
 func (a *argocd) GetCurrentResourceInclusions(gvr *schema.GroupVersionResource, resourceName, resourceNamespace string) (string, error) {
 	argocdCM, err := a.dynamicClient.Resource(*gvr).Namespace(resourceNamespace).Get(context.Background(), resourceName, metav1.GetOptions{})
@@ -206,6 +258,30 @@ func getMissingResources(obj *v1alpha1.Application) ([]*common.ResourceInfo, err
 	return missingResources, nil
 }
 
+// NewExcludedResources returns the resources reported by the ExcludedResourceWarning conditions of
+// the updated application which were not reported by the previous version of the application.
+func NewExcludedResources(oldApp, newApp *v1alpha1.Application) ([]*common.ResourceInfo, error) {
+	oldResources, err := getMissingResources(oldApp)
+	if err != nil {
+		return nil, err
+	}
+	newResources, err := getMissingResources(newApp)
+	if err != nil {
+		return nil, err
+	}
+	reported := make(map[common.ResourceInfo]bool, len(oldResources))
+	for _, resource := range oldResources {
+		reported[*resource] = true
+	}
+	var results []*common.ResourceInfo
+	for _, resource := range newResources {
+		if !reported[*resource] {
+			results = append(results, resource)
+		}
+	}
+	return results, nil
+}
+
 // getExcludedResourceConditions returns the ConditionTypeExcludedResourceWarning from status.conditions of an Argo Application object
 func getExcludedResourceConditions(statusConditions []v1alpha1.ApplicationCondition) ([]metav1.Condition, error) {
 	resultConditions := make([]metav1.Condition, 0, len(statusConditions))
@@ -280,6 +356,61 @@ func (a *argocd) GetResourcesFromApplicationStatus(ctx context.Context, applicat
 	return results, nil
 }
 
+// GetApplicationStatusProvenance returns how each resource reported in the status of the given
+// application is related to it: through an ExcludedResourceWarning condition, or through the list
+// of resources of its status.
+func GetApplicationStatusProvenance(application *v1alpha1.Application) ([]common.Provenance, error) {
+	missingResources, err := getMissingResources(application)
+	if err != nil {
+		return nil, fmt.Errorf("error getting missing resources: %w", err)
+	}
+	parent := ApplicationResourceInfo(application)
+	results := make([]common.Provenance, 0, len(application.Status.Resources)+len(missingResources))
+	for _, mr := range missingResources {
+		results = append(results, common.Provenance{
+			Source: common.SourceExcludedResourceWarning,
+			Parent: parent,
+			Child:  *mr,
+		})
+	}
+	for _, resource := range application.Status.Resources {
+		results = append(results, common.Provenance{
+			Source: common.SourceApplicationStatus,
+			Parent: parent,
+			Child: common.ResourceInfo{
+				Group:     resource.Group,
+				Kind:      resource.Kind,
+				Name:      resource.Name,
+				Namespace: resource.Namespace,
+			},
+		})
+	}
+	return results, nil
+}
+
+// ApplicationResourceInfo returns the ResourceInfo of the given application.
+func ApplicationResourceInfo(application *v1alpha1.Application) common.ResourceInfo {
+	return common.ResourceInfo{
+		Group:     v1alpha1.ApplicationSchemaGroupVersionKind.Group,
+		Kind:      v1alpha1.ApplicationSchemaGroupVersionKind.Kind,
+		Name:      application.GetName(),
+		Namespace: application.GetNamespace(),
+	}
+}
+
+// ApplicationInstanceName returns the value of the tracking label of the resources of the given
+// application, which is prefixed by the namespace of the application outside the control-plane
+// namespace.
+func ApplicationInstanceName(application *v1alpha1.Application, controllerNamespace string) string {
+	return argo.AppInstanceName(application.GetName(), application.GetNamespace(), controllerNamespace)
+}
+
+// HasSynced returns true if the given application was synced at least once, so that its
+// resources can exist on its destination cluster.
+func HasSynced(application *v1alpha1.Application) bool {
+	return application.Status.OperationState != nil || len(application.Status.History) > 0
+}
+
 // getResourceInclusionsHierarchy returns the hierarchy path for getting or updating resource.inclusions for a given GVR
 func getResourceInclusionsHierarchy(gvr *schema.GroupVersionResource) []string {
 	if gvr.Resource == graph.ArgoCDGVR.Resource {
@@ -324,13 +455,5 @@ func (a *argocd) GetApplicationChildManifests(ctx context.Context, application *
 	if err != nil {
 		return nil, fmt.Errorf("error getting child manifests: %w", err)
 	}
-	results := make([]*common.ResourceInfo, 0, len(childManifests))
-	for _, manifest := range childManifests {
-		results = append(results, &common.ResourceInfo{
-			Group: manifest.GroupVersionKind().Group,
-			Kind:  manifest.GroupVersionKind().Kind,
-			Name:  manifest.GetName(),
-		})
-	}
-	return results, nil
+	return toResourceInfos(childManifests), nil
 }
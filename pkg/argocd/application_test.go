@@ -0,0 +1,30 @@
+package argocd
+
+import (
+	"testing"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewExcludedResources(t *testing.T) {
+	oldApp := &v1alpha1.Application{Status: v1alpha1.ApplicationStatus{Conditions: []v1alpha1.ApplicationCondition{
+		{Type: ConditionTypeExcludedResourceWarning, Message: "Resource /ConfigMap guestbook is excluded in the settings"},
+	}}}
+	newApp := oldApp.DeepCopy()
+	newApp.Status.Conditions = append(newApp.Status.Conditions,
+		v1alpha1.ApplicationCondition{Type: ConditionTypeExcludedResourceWarning, Message: "Resource monitoring.coreos.com/ServiceMonitor guestbook is excluded in the settings"},
+		v1alpha1.ApplicationCondition{Type: v1alpha1.ApplicationConditionSyncError, Message: "Failed sync attempt"},
+	)
+
+	resources, err := NewExcludedResources(oldApp, newApp)
+	require.NoError(t, err)
+	assert.Equal(t, []*common.ResourceInfo{{Group: "monitoring.coreos.com", Kind: "ServiceMonitor", Name: "guestbook"}}, resources)
+
+	// A condition which disappears is not reported
+	resources, err = NewExcludedResources(newApp, oldApp)
+	require.NoError(t, err)
+	assert.Empty(t, resources)
+}
@@ -0,0 +1,250 @@
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/argoproj/argo-cd/v3/applicationset/utils"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// GetApplicationSet returns the ApplicationSet of the given namespace and name.
+func (a *argocd) GetApplicationSet(ctx context.Context, namespace, name string) (*v1alpha1.ApplicationSet, error) {
+	appSet, err := a.applicationClientSet.ArgoprojV1alpha1().ApplicationSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting applicationset %s/%s: %w", namespace, name, err)
+	}
+	return appSet, nil
+}
+
+// GetApplicationSetApplications returns the applications owned by the given ApplicationSet. The
+// ApplicationSet controller creates them in the namespace of the ApplicationSet.
+func (a *argocd) GetApplicationSetApplications(ctx context.Context, appSet *v1alpha1.ApplicationSet) ([]v1alpha1.Application, error) {
+	list, err := a.applicationClientSet.ArgoprojV1alpha1().Applications(appSet.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing applications in namespace %q: %w", appSet.Namespace, err)
+	}
+	var apps []v1alpha1.Application
+	for _, app := range list.Items {
+		if IsOwnedByApplicationSet(&app, appSet) {
+			apps = append(apps, app)
+		}
+	}
+	return apps, nil
+}
+
+// PreviewApplicationSetApplications renders the applications the given ApplicationSet would
+// generate, without them having to exist. Only list and cluster generators are supported.
+func (a *argocd) PreviewApplicationSetApplications(ctx context.Context, appSet *v1alpha1.ApplicationSet) ([]v1alpha1.Application, error) {
+	var clusters []v1alpha1.Cluster
+	for _, generator := range appSet.Spec.Generators {
+		if generator.Clusters != nil {
+			clusterList, err := a.db.ListClusters(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("error listing clusters: %w", err)
+			}
+			clusters = clusterList.Items
+			break
+		}
+	}
+	return RenderApplicationSet(appSet, clusters)
+}
+
+// IsOwnedByApplicationSet returns true if the given application has an owner reference to the
+// given ApplicationSet.
+func IsOwnedByApplicationSet(app *v1alpha1.Application, appSet *v1alpha1.ApplicationSet) bool {
+	for _, ref := range app.GetOwnerReferences() {
+		if ref.Kind == v1alpha1.ApplicationSetSchemaGroupVersionKind.Kind && ref.UID == appSet.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderApplicationSet renders the template of the given ApplicationSet for each element of its
+// generators, the cluster generators matching the given clusters. Only list and cluster
+// generators are supported, as the others need access to external systems.
+func RenderApplicationSet(appSet *v1alpha1.ApplicationSet, clusters []v1alpha1.Cluster) ([]v1alpha1.Application, error) {
+	renderer := &utils.Render{}
+	var apps []v1alpha1.Application
+	for i, generator := range appSet.Spec.Generators {
+		var (
+			params []map[string]any
+			err    error
+		)
+		switch {
+		case generator.List != nil:
+			params, err = listGeneratorParams(generator.List, appSet.Spec.GoTemplate)
+		case generator.Clusters != nil:
+			params, err = clusterGeneratorParams(renderer, generator.Clusters, clusters, appSet.Spec.GoTemplate, appSet.Spec.GoTemplateOptions)
+		default:
+			return nil, fmt.Errorf("generator %d of applicationset %s/%s cannot be previewed, only list and cluster generators are supported", i, appSet.Namespace, appSet.Name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error generating parameters of generator %d of applicationset %s/%s: %w", i, appSet.Namespace, appSet.Name, err)
+		}
+		if generatorTemplate(&generator) != nil {
+			return nil, fmt.Errorf("generator %d of applicationset %s/%s overrides the template, which cannot be previewed", i, appSet.Namespace, appSet.Name)
+		}
+		for _, p := range params {
+			app, err := renderer.RenderTemplateParams(templateApplication(appSet.Spec.Template), appSet.Spec.SyncPolicy, p, appSet.Spec.GoTemplate, appSet.Spec.GoTemplateOptions)
+			if err != nil {
+				return nil, fmt.Errorf("error rendering template of applicationset %s/%s: %w", appSet.Namespace, appSet.Name, err)
+			}
+			// As the ApplicationSet controller does, the applications are created in the namespace of the ApplicationSet
+			app.Namespace = appSet.Namespace
+			apps = append(apps, *app)
+		}
+	}
+	log.Debugf("Rendered %d applications of applicationset %s/%s", len(apps), appSet.Namespace, appSet.Name)
+	return apps, nil
+}
+
+// generatorTemplate returns the template of the given generator, nil if it uses the template of
+// the ApplicationSet.
+func generatorTemplate(generator *v1alpha1.ApplicationSetGenerator) *v1alpha1.ApplicationSetTemplate {
+	var tmpl v1alpha1.ApplicationSetTemplate
+	switch {
+	case generator.List != nil:
+		tmpl = generator.List.Template
+	case generator.Clusters != nil:
+		tmpl = generator.Clusters.Template
+	}
+	if reflect.DeepEqual(tmpl, v1alpha1.ApplicationSetTemplate{}) {
+		return nil
+	}
+	return &tmpl
+}
+
+// templateApplication returns the application of the given template, before its parameters
+// are replaced.
+func templateApplication(tmpl v1alpha1.ApplicationSetTemplate) *v1alpha1.Application {
+	var app v1alpha1.Application
+	app.Annotations = tmpl.Annotations
+	app.Labels = tmpl.Labels
+	app.Namespace = tmpl.Namespace
+	app.Name = tmpl.Name
+	app.Spec = tmpl.Spec
+	app.Finalizers = tmpl.Finalizers
+	return &app
+}
+
+// listGeneratorParams returns the parameters of each element of the given list generator. Unless
+// Go templates are used, the values of an element are flattened into "values.<key>" parameters.
+func listGeneratorParams(generator *v1alpha1.ListGenerator, goTemplate bool) ([]map[string]any, error) {
+	var res []map[string]any
+	for _, item := range generator.Elements {
+		var element map[string]any
+		if err := json.Unmarshal(item.Raw, &element); err != nil {
+			return nil, fmt.Errorf("error unmarshaling list element: %w", err)
+		}
+		if goTemplate {
+			res = append(res, element)
+			continue
+		}
+		params := make(map[string]any, len(element))
+		for key, value := range element {
+			if key != "values" {
+				v, ok := value.(string)
+				if !ok {
+					return nil, fmt.Errorf("list element parameter %q is not a string", key)
+				}
+				params[key] = v
+				continue
+			}
+			values, ok := value.(map[string]any)
+			if !ok {
+				return nil, errors.New("list element values are not a map")
+			}
+			for k, v := range values {
+				s, ok := v.(string)
+				if !ok {
+					return nil, fmt.Errorf("list element value %q is not a string", k)
+				}
+				params["values."+k] = s
+			}
+		}
+		res = append(res, params)
+	}
+	if generator.ElementsYaml != "" {
+		var elements []map[string]any
+		if err := yaml.Unmarshal([]byte(generator.ElementsYaml), &elements); err != nil {
+			return nil, fmt.Errorf("error unmarshaling list elementsYaml: %w", err)
+		}
+		res = append(res, elements...)
+	}
+	return res, nil
+}
+
+// clusterGeneratorParams returns the parameters of each of the given clusters matching the
+// selector of the given cluster generator. As with Argo CD, the local cluster, which has no
+// cluster secret, is only matched by an empty selector.
+func clusterGeneratorParams(renderer *utils.Render, generator *v1alpha1.ClusterGenerator, clusters []v1alpha1.Cluster, goTemplate bool, goTemplateOptions []string) ([]map[string]any, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&generator.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("error converting label selector: %w", err)
+	}
+	if generator.FlatList {
+		return nil, errors.New("flat list cluster generators cannot be previewed")
+	}
+	ignoreLocalCluster := len(generator.Selector.MatchLabels) > 0 || len(generator.Selector.MatchExpressions) > 0
+	var res []map[string]any
+	for _, cluster := range clusters {
+		isLocal := cluster.ID == ""
+		if isLocal && ignoreLocalCluster || !isLocal && !selector.Matches(labels.Set(cluster.Labels)) {
+			continue
+		}
+		params := map[string]any{
+			"name":           cluster.Name,
+			"nameNormalized": cluster.Name,
+			"server":         cluster.Server,
+			"project":        cluster.Project,
+		}
+		if !isLocal {
+			params["nameNormalized"] = utils.SanitizeName(cluster.Name)
+			if goTemplate {
+				meta := map[string]any{}
+				if len(cluster.Annotations) > 0 {
+					meta["annotations"] = cluster.Annotations
+				}
+				if len(cluster.Labels) > 0 {
+					meta["labels"] = cluster.Labels
+				}
+				params["metadata"] = meta
+			} else {
+				for key, value := range cluster.Annotations {
+					params["metadata.annotations."+key] = value
+				}
+				for key, value := range cluster.Labels {
+					params["metadata.labels."+key] = value
+				}
+			}
+		}
+		// The values may reference the parameters of the cluster, they are replaced first
+		values := make(map[string]string, len(generator.Values))
+		for key, value := range generator.Values {
+			values[key], err = renderer.Replace(value, params, goTemplate, goTemplateOptions)
+			if err != nil {
+				return nil, fmt.Errorf("error replacing value %q of cluster %s: %w", key, cluster.Name, err)
+			}
+		}
+		if goTemplate {
+			if len(values) > 0 {
+				params["values"] = values
+			}
+		} else {
+			for key, value := range values {
+				params["values."+key] = value
+			}
+		}
+		res = append(res, params)
+	}
+	return res, nil
+}
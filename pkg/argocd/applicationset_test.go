@@ -0,0 +1,98 @@
+package argocd
+
+import (
+	"testing"
+
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestApplicationSet(goTemplate bool, generators ...v1alpha1.ApplicationSetGenerator) *v1alpha1.ApplicationSet {
+	name, server, path := "{{name}}-guestbook", "{{server}}", "guestbook/{{values.env}}"
+	if goTemplate {
+		name, server, path = "{{.name}}-guestbook", "{{.server}}", "guestbook/{{.values.env}}"
+	}
+	return &v1alpha1.ApplicationSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "guestbook", Namespace: "argocd", UID: "appset-uid"},
+		Spec: v1alpha1.ApplicationSetSpec{
+			GoTemplate: goTemplate,
+			Generators: generators,
+			Template: v1alpha1.ApplicationSetTemplate{
+				ApplicationSetTemplateMeta: v1alpha1.ApplicationSetTemplateMeta{Name: name},
+				Spec: v1alpha1.ApplicationSpec{
+					Project:     "default",
+					Source:      &v1alpha1.ApplicationSource{RepoURL: "https://github.com/argoproj/argocd-example-apps", Path: path},
+					Destination: v1alpha1.ApplicationDestination{Server: server, Namespace: "guestbook"},
+				},
+			},
+		},
+	}
+}
+
+func Test_RenderApplicationSet(t *testing.T) {
+	clusters := []v1alpha1.Cluster{
+		{Name: "in-cluster", Server: "https://kubernetes.default.svc"},
+		{ID: "1", Name: "staging", Server: "https://staging.example.com", Labels: map[string]string{"env": "staging"}},
+		{ID: "2", Name: "production", Server: "https://production.example.com", Labels: map[string]string{"env": "production"}},
+	}
+
+	t.Run("list generator elements are rendered", func(t *testing.T) {
+		appSet := newTestApplicationSet(false, v1alpha1.ApplicationSetGenerator{
+			List: &v1alpha1.ListGenerator{Elements: []apiextensionsv1.JSON{
+				{Raw: []byte(`{"name": "staging", "server": "https://staging.example.com", "values": {"env": "stage"}}`)},
+			}},
+		})
+		apps, err := RenderApplicationSet(appSet, nil)
+		require.NoError(t, err)
+		require.Len(t, apps, 1)
+		assert.Equal(t, "staging-guestbook", apps[0].Name)
+		assert.Equal(t, "argocd", apps[0].Namespace)
+		assert.Equal(t, "https://staging.example.com", apps[0].Spec.Destination.Server)
+		assert.Equal(t, "guestbook/stage", apps[0].Spec.Source.Path)
+	})
+
+	t.Run("cluster generator renders the matching clusters", func(t *testing.T) {
+		appSet := newTestApplicationSet(true, v1alpha1.ApplicationSetGenerator{
+			Clusters: &v1alpha1.ClusterGenerator{
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"env": "production"}},
+				Values:   map[string]string{"env": "{{.name}}"},
+			},
+		})
+		apps, err := RenderApplicationSet(appSet, clusters)
+		require.NoError(t, err)
+		require.Len(t, apps, 1)
+		assert.Equal(t, "production-guestbook", apps[0].Name)
+		assert.Equal(t, "guestbook/production", apps[0].Spec.Source.Path)
+	})
+
+	t.Run("the local cluster only matches an empty selector", func(t *testing.T) {
+		appSet := newTestApplicationSet(false, v1alpha1.ApplicationSetGenerator{
+			Clusters: &v1alpha1.ClusterGenerator{Values: map[string]string{"env": "{{name}}"}},
+		})
+		apps, err := RenderApplicationSet(appSet, clusters)
+		require.NoError(t, err)
+		assert.Len(t, apps, 3)
+	})
+
+	t.Run("other generators cannot be previewed", func(t *testing.T) {
+		appSet := newTestApplicationSet(false, v1alpha1.ApplicationSetGenerator{Git: &v1alpha1.GitGenerator{}})
+		_, err := RenderApplicationSet(appSet, clusters)
+		assert.Error(t, err)
+	})
+}
+
+func Test_IsOwnedByApplicationSet(t *testing.T) {
+	appSet := newTestApplicationSet(false)
+	owned := &v1alpha1.Application{ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{
+		{APIVersion: "argoproj.io/v1alpha1", Kind: "ApplicationSet", Name: "guestbook", UID: "appset-uid"},
+	}}}
+	other := &v1alpha1.Application{ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{
+		{APIVersion: "argoproj.io/v1alpha1", Kind: "ApplicationSet", Name: "guestbook", UID: "other-uid"},
+	}}}
+	assert.True(t, IsOwnedByApplicationSet(owned, appSet))
+	assert.False(t, IsOwnedByApplicationSet(other, appSet))
+	assert.False(t, IsOwnedByApplicationSet(&v1alpha1.Application{}, appSet))
+}
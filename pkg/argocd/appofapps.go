@@ -0,0 +1,128 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ApplicationManifests holds the manifests generated for an application of an app-of-apps tree.
+type ApplicationManifests struct {
+	Application *v1alpha1.Application
+	// Parent is the namespace/name of the application whose manifests hold this one, empty for
+	// the root of the tree.
+	Parent string
+	// Depth is the number of applications between the root of the tree and this one.
+	Depth     int
+	Manifests []*common.ResourceInfo
+}
+
+// GetApplicationTreeManifests returns the manifests of the given application and, down to
+// maxDepth levels, the manifests of the applications found in them, so that the children of an
+// app-of-apps are analyzed before they exist. The manifests of a child application are generated
+// with the AppProject of the root application, as its own project may not exist yet. Children
+// for which skip returns true, such as the applications analyzed anyway, are left out along with
+// their own children. An application met twice in the tree is only reported once.
+func (a *argocd) GetApplicationTreeManifests(ctx context.Context, application *v1alpha1.Application, kubeconfig string, maxDepth int, skip func(*v1alpha1.Application) bool) ([]ApplicationManifests, error) {
+	appProject, err := a.GetAppProject(application)
+	if err != nil {
+		return nil, fmt.Errorf("error getting app project: %w", err)
+	}
+	return applicationTreeManifests(application, maxDepth, skip, func(app *v1alpha1.Application) ([]*unstructured.Unstructured, error) {
+		return a.repoServerManager.GetApplicationChildManifests(ctx, app, appProject, kubeconfig)
+	})
+}
+
+// applicationTreeManifests walks the app-of-apps tree of the given application breadth first,
+// generating the manifests of each application with generate.
+func applicationTreeManifests(application *v1alpha1.Application, maxDepth int, skip func(*v1alpha1.Application) bool, generate func(*v1alpha1.Application) ([]*unstructured.Unstructured, error)) ([]ApplicationManifests, error) {
+	objs, err := generate(application)
+	if err != nil {
+		return nil, fmt.Errorf("error getting child manifests: %w", err)
+	}
+	tree := []ApplicationManifests{{Application: application, Manifests: toResourceInfos(objs)}}
+	visited := map[string]bool{applicationKey(application): true}
+	type pending struct {
+		parent *v1alpha1.Application
+		depth  int
+		objs   []*unstructured.Unstructured
+	}
+	queue := []pending{{parent: application, objs: objs}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range childApplications(current.parent, current.objs) {
+			key := applicationKey(child)
+			if visited[key] {
+				log.Debugf("Application %s is met again in the tree of %s, skipping it", key, applicationKey(application))
+				continue
+			}
+			visited[key] = true
+			if skip != nil && skip(child) {
+				continue
+			}
+			if current.depth >= maxDepth {
+				log.Infof("Not analyzing application %s of %s, the app-of-apps depth limit of %d is reached", key, applicationKey(current.parent), maxDepth)
+				continue
+			}
+			childObjs, err := generate(child)
+			if err != nil {
+				log.WithError(err).Errorf("Error getting child manifests of application %s of %s", key, applicationKey(current.parent))
+				continue
+			}
+			tree = append(tree, ApplicationManifests{
+				Application: child,
+				Parent:      applicationKey(current.parent),
+				Depth:       current.depth + 1,
+				Manifests:   toResourceInfos(childObjs),
+			})
+			queue = append(queue, pending{parent: child, depth: current.depth + 1, objs: childObjs})
+		}
+	}
+	return tree, nil
+}
+
+// childApplications decodes the applications found in the manifests of the given parent. An
+// application without namespace is created in the destination namespace of its parent.
+func childApplications(parent *v1alpha1.Application, objs []*unstructured.Unstructured) []*v1alpha1.Application {
+	var children []*v1alpha1.Application
+	for _, obj := range objs {
+		if obj.GroupVersionKind().GroupKind() != v1alpha1.ApplicationSchemaGroupVersionKind.GroupKind() {
+			continue
+		}
+		var child v1alpha1.Application
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &child); err != nil {
+			log.WithError(err).Warnf("Error decoding application %s in the manifests of %s", obj.GetName(), applicationKey(parent))
+			continue
+		}
+		if child.Namespace == "" {
+			child.Namespace = parent.Spec.Destination.Namespace
+		}
+		children = append(children, &child)
+	}
+	return children
+}
+
+// applicationKey returns the namespace/name of the given application.
+func applicationKey(app *v1alpha1.Application) string {
+	return fmt.Sprintf("%s/%s", app.GetNamespace(), app.GetName())
+}
+
+// toResourceInfos returns the ResourceInfo of each of the given manifests.
+func toResourceInfos(manifests []*unstructured.Unstructured) []*common.ResourceInfo {
+	results := make([]*common.ResourceInfo, 0, len(manifests))
+	for _, manifest := range manifests {
+		results = append(results, &common.ResourceInfo{
+			Group:     manifest.GroupVersionKind().Group,
+			Kind:      manifest.GroupVersionKind().Kind,
+			Name:      manifest.GetName(),
+			Namespace: manifest.GetNamespace(),
+		})
+	}
+	return results
+}
@@ -0,0 +1,172 @@
+package argocd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_childApplications(t *testing.T) {
+	parent := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "bootstrap", Namespace: "argocd"},
+		Spec:       v1alpha1.ApplicationSpec{Destination: v1alpha1.ApplicationDestination{Namespace: "argocd"}},
+	}
+	objs := []*unstructured.Unstructured{
+		{Object: map[string]any{
+			"apiVersion": "argoproj.io/v1alpha1",
+			"kind":       "Application",
+			"metadata":   map[string]any{"name": "guestbook"},
+			"spec": map[string]any{
+				"project":     "default",
+				"source":      map[string]any{"repoURL": "https://github.com/argoproj/argocd-example-apps", "path": "guestbook"},
+				"destination": map[string]any{"server": "https://kubernetes.default.svc", "namespace": "guestbook"},
+			},
+		}},
+		{Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]any{"name": "guestbook", "namespace": "argocd"},
+		}},
+		{Object: map[string]any{
+			"apiVersion": "argoproj.io/v1alpha1",
+			"kind":       "AppProject",
+			"metadata":   map[string]any{"name": "guestbook", "namespace": "argocd"},
+		}},
+	}
+
+	children := childApplications(parent, objs)
+	require.Len(t, children, 1)
+	assert.Equal(t, "argocd/guestbook", applicationKey(children[0]))
+	assert.Equal(t, "guestbook", children[0].Spec.GetSource().Path)
+	assert.Equal(t, "https://kubernetes.default.svc", children[0].Spec.Destination.Server)
+}
+
+// applicationManifest returns the manifest of an application in the argocd namespace.
+func applicationManifest(name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Application",
+		"metadata":   map[string]any{"name": name, "namespace": "argocd"},
+		"spec": map[string]any{
+			"project":     "default",
+			"source":      map[string]any{"repoURL": "https://github.com/argoproj/argocd-example-apps", "path": name},
+			"destination": map[string]any{"server": "https://kubernetes.default.svc", "namespace": name},
+		},
+	}}
+}
+
+func Test_applicationTreeManifests(t *testing.T) {
+	configMap := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "settings", "namespace": "guestbook"},
+	}}
+	tests := []struct {
+		name     string
+		maxDepth int
+		// manifests holds the manifests generated for each application, by name
+		manifests map[string][]*unstructured.Unstructured
+		// expected holds the namespace/name, parent and depth of each application of the tree
+		expected []string
+		// generated holds the names of the applications whose manifests are generated
+		generated []string
+	}{
+		{
+			name:     "children below the depth limit are not generated",
+			maxDepth: 2,
+			manifests: map[string][]*unstructured.Unstructured{
+				"root":   {applicationManifest("first")},
+				"first":  {applicationManifest("second")},
+				"second": {applicationManifest("third")},
+				"third":  {configMap},
+			},
+			expected:  []string{"argocd/root  0", "argocd/first argocd/root 1", "argocd/second argocd/first 2"},
+			generated: []string{"root", "first", "second"},
+		},
+		{
+			name:     "a depth limit of zero only generates the root",
+			maxDepth: 0,
+			manifests: map[string][]*unstructured.Unstructured{
+				"root": {applicationManifest("first"), configMap},
+			},
+			expected:  []string{"argocd/root  0"},
+			generated: []string{"root"},
+		},
+		{
+			name:     "a self referencing application is reported once",
+			maxDepth: 5,
+			manifests: map[string][]*unstructured.Unstructured{
+				"root": {applicationManifest("root"), configMap},
+			},
+			expected:  []string{"argocd/root  0"},
+			generated: []string{"root"},
+		},
+		{
+			name:     "a cycle of two applications is walked once",
+			maxDepth: 5,
+			manifests: map[string][]*unstructured.Unstructured{
+				"root":  {applicationManifest("first")},
+				"first": {applicationManifest("root"), configMap},
+			},
+			expected:  []string{"argocd/root  0", "argocd/first argocd/root 1"},
+			generated: []string{"root", "first"},
+		},
+		{
+			name:     "an application met in two branches is reported once",
+			maxDepth: 5,
+			manifests: map[string][]*unstructured.Unstructured{
+				"root":   {applicationManifest("first"), applicationManifest("second")},
+				"first":  {applicationManifest("shared")},
+				"second": {applicationManifest("shared")},
+				"shared": {configMap},
+			},
+			expected:  []string{"argocd/root  0", "argocd/first argocd/root 1", "argocd/second argocd/root 1", "argocd/shared argocd/first 2"},
+			generated: []string{"root", "first", "second", "shared"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := applicationManifest("root")
+			var app v1alpha1.Application
+			require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(root.Object, &app))
+			var generated []string
+			tree, err := applicationTreeManifests(&app, tt.maxDepth, nil, func(app *v1alpha1.Application) ([]*unstructured.Unstructured, error) {
+				generated = append(generated, app.Name)
+				return tt.manifests[app.Name], nil
+			})
+			require.NoError(t, err)
+			actual := make([]string, 0, len(tree))
+			for _, node := range tree {
+				actual = append(actual, fmt.Sprintf("%s %s %d", applicationKey(node.Application), node.Parent, node.Depth))
+			}
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.generated, generated)
+		})
+	}
+}
+
+func Test_applicationTreeManifests_errors(t *testing.T) {
+	root := &v1alpha1.Application{ObjectMeta: metav1.ObjectMeta{Name: "root", Namespace: "argocd"}}
+
+	_, err := applicationTreeManifests(root, 1, nil, func(*v1alpha1.Application) ([]*unstructured.Unstructured, error) {
+		return nil, fmt.Errorf("repository not found")
+	})
+	require.ErrorContains(t, err, "repository not found")
+
+	// A child whose manifests cannot be generated is left out along with its own children
+	tree, err := applicationTreeManifests(root, 1, nil, func(app *v1alpha1.Application) ([]*unstructured.Unstructured, error) {
+		if app.Name == "first" {
+			return nil, fmt.Errorf("repository not found")
+		}
+		return []*unstructured.Unstructured{applicationManifest("first")}, nil
+	})
+	require.NoError(t, err)
+	require.Len(t, tree, 1)
+	assert.Equal(t, "argocd/root", applicationKey(tree[0].Application))
+}
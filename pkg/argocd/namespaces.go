@@ -0,0 +1,72 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	argocdcommon "github.com/argoproj/argo-cd/v3/common"
+	"github.com/argoproj/argo-cd/v3/util/security"
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// applicationNamespacesKey is the key of argocd-cmd-params-cm listing the namespaces, besides the
+// control-plane namespace, Argo CD manages applications in.
+const applicationNamespacesKey = "application.namespaces"
+
+// GetApplicationNamespaces returns the namespaces Argo CD manages applications in: the
+// control-plane namespace, and the existing namespaces matching the patterns of
+// application.namespaces in argocd-cmd-params-cm.
+func (a *argocd) GetApplicationNamespaces(ctx context.Context) ([]string, error) {
+	controlPlane := a.kubeClient.Namespace
+	cm, err := a.kubeClient.Clientset.CoreV1().ConfigMaps(controlPlane).Get(ctx, argocdcommon.ArgoCDCmdParamsConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return []string{controlPlane}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching ConfigMap %s: %w", argocdcommon.ArgoCDCmdParamsConfigMapName, err)
+	}
+	patterns := ParseApplicationNamespaces(cm.Data[applicationNamespacesKey])
+	if len(patterns) == 0 {
+		return []string{controlPlane}, nil
+	}
+	list, err := a.kubeClient.Clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing namespaces: %w", err)
+	}
+	existing := make([]string, 0, len(list.Items))
+	for _, ns := range list.Items {
+		existing = append(existing, ns.Name)
+	}
+	namespaces := MatchApplicationNamespaces(controlPlane, patterns, existing)
+	log.Debugf("Application namespaces %v match %v", namespaces, patterns)
+	return namespaces, nil
+}
+
+// ParseApplicationNamespaces returns the namespace patterns of the given comma separated value of
+// application.namespaces.
+func ParseApplicationNamespaces(value string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// MatchApplicationNamespaces returns, sorted, the control-plane namespace and the given
+// namespaces matching any of the patterns. As with Argo CD, a pattern is a glob, or a regular
+// expression when enclosed in slashes.
+func MatchApplicationNamespaces(controlPlane string, patterns []string, namespaces []string) []string {
+	matches := []string{controlPlane}
+	for _, ns := range namespaces {
+		if security.IsNamespaceEnabled(ns, controlPlane, patterns) {
+			matches = append(matches, ns)
+		}
+	}
+	return slices.Compact(slices.Sorted(slices.Values(matches)))
+}
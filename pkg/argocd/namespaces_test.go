@@ -0,0 +1,16 @@
+package argocd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MatchApplicationNamespaces(t *testing.T) {
+	patterns := ParseApplicationNamespaces(" team-*, /^app-[0-9]+$/ ,,")
+	assert.Equal(t, []string{"team-*", "/^app-[0-9]+$/"}, patterns)
+
+	existing := []string{"argocd", "team-a", "team-b", "app-1", "app-x", "kube-system"}
+	assert.Equal(t, []string{"app-1", "argocd", "team-a", "team-b"}, MatchApplicationNamespaces("argocd", patterns, existing))
+	assert.Equal(t, []string{"argocd"}, MatchApplicationNamespaces("argocd", nil, existing))
+}
@@ -0,0 +1,84 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ApplicationSelection selects a subset of the applications, for example the applications of a
+// team or of an Argo CD shard. The empty selection selects every application.
+type ApplicationSelection struct {
+	// LabelSelector is a label selector the applications must match.
+	LabelSelector string
+	// Projects are the AppProjects the applications must belong to, any project if empty.
+	Projects []string
+	// Namespaces are the namespaces the applications are listed from if set. Otherwise they are
+	// listed from the application namespace of the client, or if it has none from every
+	// namespace Argo CD manages applications in.
+	Namespaces []string
+}
+
+// SelectsAll returns true if the selection selects every application.
+func (s ApplicationSelection) SelectsAll() bool {
+	return s.LabelSelector == "" && len(s.Projects) == 0 && len(s.Namespaces) == 0
+}
+
+// Validate returns an error if the label selector of the selection cannot be parsed.
+func (s ApplicationSelection) Validate() error {
+	if _, err := labels.Parse(s.LabelSelector); err != nil {
+		return fmt.Errorf("invalid application label selector %q: %w", s.LabelSelector, err)
+	}
+	return nil
+}
+
+// Matches returns true if the given application is selected.
+func (s ApplicationSelection) Matches(app *v1alpha1.Application) bool {
+	if len(s.Namespaces) > 0 && !slices.Contains(s.Namespaces, app.GetNamespace()) {
+		return false
+	}
+	if len(s.Projects) > 0 && !slices.Contains(s.Projects, app.Spec.GetProject()) {
+		return false
+	}
+	selector, err := labels.Parse(s.LabelSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(app.GetLabels()))
+}
+
+// SelectApplications lists the applications matching the given selection. The label selector is
+// applied by the API server, the projects are filtered afterwards.
+func (a *argocd) SelectApplications(ctx context.Context, selection ApplicationSelection) ([]v1alpha1.Application, error) {
+	if err := selection.Validate(); err != nil {
+		return nil, err
+	}
+	namespaces := selection.Namespaces
+	if len(namespaces) == 0 && a.applicationNamespace != "" {
+		namespaces = []string{a.applicationNamespace}
+	} else if len(namespaces) == 0 {
+		var err error
+		if namespaces, err = a.GetApplicationNamespaces(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var apps []v1alpha1.Application
+	for _, ns := range slices.Compact(slices.Sorted(slices.Values(namespaces))) {
+		list, err := a.applicationClientSet.ArgoprojV1alpha1().Applications(ns).List(ctx, metav1.ListOptions{
+			LabelSelector: selection.LabelSelector,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing applications in namespace %q: %w", ns, err)
+		}
+		for _, app := range list.Items {
+			if len(selection.Projects) == 0 || slices.Contains(selection.Projects, app.Spec.GetProject()) {
+				apps = append(apps, app)
+			}
+		}
+	}
+	return apps, nil
+}
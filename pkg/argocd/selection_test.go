@@ -0,0 +1,28 @@
+package argocd
+
+import (
+	"testing"
+
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_ApplicationSelection(t *testing.T) {
+	app := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "guestbook", Namespace: "team-a", Labels: map[string]string{"team": "payments"}},
+		Spec:       v1alpha1.ApplicationSpec{Project: "payments"},
+	}
+
+	assert.True(t, ApplicationSelection{}.SelectsAll())
+	assert.True(t, ApplicationSelection{}.Matches(app))
+	assert.True(t, ApplicationSelection{LabelSelector: "team in (payments, billing)", Projects: []string{"payments"}, Namespaces: []string{"team-a", "team-b"}}.Matches(app))
+	assert.False(t, ApplicationSelection{LabelSelector: "team=billing"}.Matches(app))
+	assert.False(t, ApplicationSelection{Projects: []string{"default"}}.Matches(app))
+	assert.False(t, ApplicationSelection{Namespaces: []string{"team-b"}}.Matches(app))
+	// Applications without project belong to the default project
+	assert.True(t, ApplicationSelection{Projects: []string{"default"}}.Matches(&v1alpha1.Application{}))
+
+	assert.NoError(t, ApplicationSelection{LabelSelector: "team=payments"}.Validate())
+	assert.Error(t, ApplicationSelection{LabelSelector: "team in payments"}.Validate())
+}
@@ -1,13 +1,19 @@
 package common
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"reflect"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v2"
 )
 
+// ResourceInclusionsHashAnnotation is the annotation recording, on the resource holding the
+// resource.inclusions settings, the SHA-256 hash of the resource inclusions last written to it.
+const ResourceInclusionsHashAnnotation = "resource-tracker.argoproj.io/inclusions-hash"
+
 type Void struct{}
 type ResourceInfoSet map[ResourceInfo]Void
 type ResourceInfo struct {
@@ -21,9 +27,9 @@ type Kinds map[string]Void
 type GroupedResourceKinds map[string]Kinds
 
 type ResourceInclusionEntry struct {
-	APIGroups []string `json:"apiGroups,omitempty"`
-	Kinds     []string `json:"kinds,omitempty"`
-	Clusters  []string `json:"clusters,omitempty"`
+	APIGroups []string `json:"apiGroups,omitempty" yaml:"apiGroups,omitempty"`
+	Kinds     []string `json:"kinds,omitempty" yaml:"kinds,omitempty"`
+	Clusters  []string `json:"clusters,omitempty" yaml:"clusters,omitempty"`
 }
 
 func (r *ResourceInfo) String() string {
@@ -42,20 +48,14 @@ func (k *Kinds) Equal(other *Kinds) bool {
 	return true
 }
 
+// Equal returns true if both entries have the same sets of API groups, kinds and clusters, in
+// any order.
 func (r *ResourceInclusionEntry) Equal(other *ResourceInclusionEntry) bool {
-	if !reflect.DeepEqual(r.APIGroups, other.APIGroups) || !reflect.DeepEqual(r.Clusters, other.Clusters) || len(r.Kinds) != len(other.Kinds) {
-		return false
-	}
-	currentKindsStr := fmt.Sprintf("%v", r.Kinds)
-	for _, otherKind := range other.Kinds {
-		if !strings.Contains(currentKindsStr, otherKind) {
-			return false
-		}
-	}
-	return true
+	return sameSet(r.APIGroups, other.APIGroups) && sameSet(r.Kinds, other.Kinds) && sameSet(r.Clusters, other.Clusters)
 }
 
-// String is the single, centralized function to print the YAML output.
+// String is the single, centralized function to print the YAML output. The entries are sorted by
+// API group and their kinds by name, so that the same kinds are always printed the same way.
 func (g *GroupedResourceKinds) String() string {
 	includedResources := make([]ResourceInclusionEntry, 0, len(*g))
 	for group, kinds := range *g {
@@ -71,6 +71,9 @@ func (g *GroupedResourceKinds) String() string {
 			Clusters:  []string{"*"},
 		})
 	}
+	sort.Slice(includedResources, func(i, j int) bool {
+		return includedResources[i].APIGroups[0] < includedResources[j].APIGroups[0]
+	})
 	out, err := yaml.Marshal(includedResources)
 	if err != nil {
 		return fmt.Sprintf("error: %v", err.Error())
@@ -78,6 +81,17 @@ func (g *GroupedResourceKinds) String() string {
 	return string(out)
 }
 
+// Hash returns the SHA-256 hash of the YAML output of the resource inclusions.
+func (g *GroupedResourceKinds) Hash() string {
+	return ContentHash(g.String())
+}
+
+// ContentHash returns the SHA-256 hash of the given resource.inclusions settings.
+func ContentHash(resourceInclusionsYaml string) string {
+	sum := sha256.Sum256([]byte(resourceInclusionsYaml))
+	return hex.EncodeToString(sum[:])
+}
+
 // Equal returns true if any of the resource inclusions entries is modified, false otherwise
 func (g *GroupedResourceKinds) Equal(other *GroupedResourceKinds) bool {
 	if len(*other) != len(*g) {
@@ -103,26 +117,30 @@ func (r *ResourceInfoSet) String() string {
 	return "{" + strings.Join(resourceInfos, ", ") + "}"
 }
 
+// FromYaml adds the kinds of the given resource.inclusions setting, parsed like ParseResourceFilter
+// does, by API group. The kinds of an entry without kinds are added as a wildcard. The clusters of
+// the entries are ignored, as are the entries without API groups.
 func (g *GroupedResourceKinds) FromYaml(resourceInclusionsYaml string) error {
-	var existingResourceInclusionsInCM []ResourceInclusionEntry
-	err := yaml.Unmarshal([]byte(resourceInclusionsYaml), &existingResourceInclusionsInCM)
+	existingResourceInclusionsInCM, err := parseResourceEntries(resourceInclusionsYaml)
 	if err != nil {
 		return err
 	}
 	for _, resourceInclusion := range existingResourceInclusionsInCM {
+		kinds := resourceInclusion.Kinds
+		if len(kinds) == 0 {
+			kinds = []string{WildcardKind}
+		}
 		for _, apiGroup := range resourceInclusion.APIGroups {
 			group := apiGroup
 			if group == "" {
 				group = "core"
 			}
-			for _, kind := range resourceInclusion.Kinds {
+			for _, kind := range kinds {
 				if (*g)[group] == nil {
 					(*g)[group] = make(map[string]Void)
 				}
 				(*g)[group][kind] = Void{}
 			}
-			// break after the first item in apiGroup list
-			break
 		}
 	}
 	return nil
@@ -146,11 +164,32 @@ func (g *GroupedResourceKinds) MergeResourceInfos(input []*ResourceInfo) {
 	}
 }
 
-// getUniqueKinds given a set of kinds, it returns unique set of kinds
+// getUniqueKinds given a set of kinds, it returns unique set of kinds sorted by name
 func getUniqueKinds(kinds Kinds) []string {
 	uniqueKinds := make([]string, 0)
 	for kind := range kinds {
 		uniqueKinds = append(uniqueKinds, kind)
 	}
+	sort.Strings(uniqueKinds)
 	return uniqueKinds
 }
+
+// sameSet returns true if both slices hold the same values, ignoring their order and duplicates
+func sameSet(a, b []string) bool {
+	set := make(map[string]bool, len(a))
+	for _, value := range a {
+		set[value] = false
+	}
+	for _, value := range b {
+		if _, ok := set[value]; !ok {
+			return false
+		}
+		set[value] = true
+	}
+	for _, seen := range set {
+		if !seen {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GroupedResourceKinds_String(t *testing.T) {
+	groupedKinds := GroupedResourceKinds{
+		"rbac.authorization.k8s.io": {"RoleBinding": {}, "Role": {}},
+		"apps":                      {"StatefulSet": {}, "Deployment": {}},
+		"core":                      {"Service": {}, "Pod": {}, "ConfigMap": {}},
+	}
+	expected := `- apiGroups:
+  - ""
+  kinds:
+  - ConfigMap
+  - Pod
+  - Service
+  clusters:
+  - '*'
+- apiGroups:
+  - apps
+  kinds:
+  - Deployment
+  - StatefulSet
+  clusters:
+  - '*'
+- apiGroups:
+  - rbac.authorization.k8s.io
+  kinds:
+  - Role
+  - RoleBinding
+  clusters:
+  - '*'
+`
+	for range 10 {
+		assert.Equal(t, expected, groupedKinds.String())
+	}
+	assert.Equal(t, ContentHash(expected), groupedKinds.Hash())
+
+	parsed := make(GroupedResourceKinds)
+	assert.NoError(t, parsed.FromYaml(expected))
+	assert.True(t, parsed.Equal(&groupedKinds))
+	assert.Equal(t, groupedKinds.Hash(), parsed.Hash())
+	delete(parsed["core"], "Pod")
+	assert.NotEqual(t, groupedKinds.Hash(), parsed.Hash())
+}
+
+func Test_ResourceInclusionEntry_Equal(t *testing.T) {
+	entry := ResourceInclusionEntry{APIGroups: []string{""}, Kinds: []string{"Pod", "Service"}, Clusters: []string{"*"}}
+	assert.True(t, entry.Equal(&ResourceInclusionEntry{APIGroups: []string{""}, Kinds: []string{"Service", "Pod"}, Clusters: []string{"*"}}))
+	// A kind is not matched by a kind whose name contains it
+	assert.False(t, entry.Equal(&ResourceInclusionEntry{APIGroups: []string{""}, Kinds: []string{"PodTemplate", "Service"}, Clusters: []string{"*"}}))
+	assert.False(t, entry.Equal(&ResourceInclusionEntry{APIGroups: []string{""}, Kinds: []string{"Pod", "Pod"}, Clusters: []string{"*"}}))
+	assert.False(t, entry.Equal(&ResourceInclusionEntry{APIGroups: []string{"apps"}, Kinds: []string{"Pod", "Service"}, Clusters: []string{"*"}}))
+}
@@ -0,0 +1,101 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// WildcardKind is the kind of a resource inclusion entry which includes every kind of its groups.
+const WildcardKind = "*"
+
+// CompactionPolicy controls how much over-inclusion the compaction of resource inclusions may
+// introduce.
+type CompactionPolicy struct {
+	// MaxUnneededKinds is the number of kinds served in a group which are not needed, but may be
+	// included by replacing the kinds of the group with a wildcard. With zero, a group is replaced
+	// with a wildcard only when every kind it serves is needed. Negative values disable the
+	// wildcards, only the groups needing the same kinds are merged.
+	MaxUnneededKinds int
+}
+
+// Compact returns the resource inclusion entries of the grouped kinds, replacing the kinds of a
+// group with a wildcard when the kinds it serves which are not needed are within the policy, and
+// merging the groups which need the same kinds into one entry. served holds the kinds served by
+// the destination clusters, the groups it does not hold are never replaced with a wildcard.
+func (g *GroupedResourceKinds) Compact(served GroupedResourceKinds, policy CompactionPolicy) []ResourceInclusionEntry {
+	groupsByKinds := make(map[string][]string)
+	kindsByKey := make(map[string][]string)
+	for group, kinds := range *g {
+		apiGroup := group
+		if group == "core" {
+			apiGroup = ""
+		}
+		entryKinds := getUniqueKinds(kinds)
+		if policy.MaxUnneededKinds >= 0 && wildcardAllowed(kinds, served[normalizeGroup(group)], policy.MaxUnneededKinds) {
+			entryKinds = []string{WildcardKind}
+		}
+		key := strings.Join(entryKinds, ",")
+		groupsByKinds[key] = append(groupsByKinds[key], apiGroup)
+		kindsByKey[key] = entryKinds
+	}
+	entries := make([]ResourceInclusionEntry, 0, len(groupsByKinds))
+	for key, groups := range groupsByKinds {
+		sort.Strings(groups)
+		entries = append(entries, ResourceInclusionEntry{
+			APIGroups: groups,
+			Kinds:     kindsByKey[key],
+			Clusters:  []string{"*"},
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].APIGroups[0] < entries[j].APIGroups[0]
+	})
+	return entries
+}
+
+// CompactString returns the YAML output of the compacted resource inclusions.
+func (g *GroupedResourceKinds) CompactString(served GroupedResourceKinds, policy CompactionPolicy) string {
+	out, err := yaml.Marshal(g.Compact(served, policy))
+	if err != nil {
+		return fmt.Sprintf("error: %v", err.Error())
+	}
+	return string(out)
+}
+
+// Includes returns true if the given kind of the given group is included, by name or by a
+// wildcard. The empty group stands for the core group.
+func (g *GroupedResourceKinds) Includes(group, kind string) bool {
+	kinds := (*g)[normalizeGroup(group)]
+	_, found := kinds[kind]
+	_, wildcard := kinds[WildcardKind]
+	return found || wildcard
+}
+
+// wildcardAllowed returns true if the needed kinds include a wildcard already, or if the group is
+// served and serves at most maxUnneeded kinds which are not needed.
+func wildcardAllowed(needed, served Kinds, maxUnneeded int) bool {
+	if _, ok := needed[WildcardKind]; ok {
+		return true
+	}
+	if len(served) == 0 {
+		return false
+	}
+	unneeded := 0
+	for kind := range served {
+		if _, ok := needed[kind]; !ok {
+			unneeded++
+		}
+	}
+	return unneeded <= maxUnneeded
+}
+
+// normalizeGroup returns the key of the given group in GroupedResourceKinds.
+func normalizeGroup(group string) string {
+	if group == "" {
+		return "core"
+	}
+	return group
+}
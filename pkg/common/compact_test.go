@@ -0,0 +1,54 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GroupedResourceKinds_Compact(t *testing.T) {
+	served := GroupedResourceKinds{
+		"core":                      {"ConfigMap": {}, "Pod": {}, "Secret": {}, "Service": {}},
+		"apps":                      {"Deployment": {}, "StatefulSet": {}, "DaemonSet": {}},
+		"monitoring.coreos.com":     {"ServiceMonitor": {}, "PodMonitor": {}},
+		"rbac.authorization.k8s.io": {"Role": {}, "RoleBinding": {}},
+	}
+	needed := GroupedResourceKinds{
+		"core":                      {"ConfigMap": {}, "Pod": {}, "Service": {}},
+		"apps":                      {"Deployment": {}, "StatefulSet": {}},
+		"monitoring.coreos.com":     {"ServiceMonitor": {}, "PodMonitor": {}},
+		"rbac.authorization.k8s.io": {"Role": {}, "RoleBinding": {}},
+		"example.com":               {"Role": {}, "RoleBinding": {}},
+	}
+
+	// Only the groups serving no other kind are replaced with a wildcard, the groups which are
+	// not served are kept as is and merged with the groups needing the same kinds
+	assert.Equal(t, []ResourceInclusionEntry{
+		{APIGroups: []string{""}, Kinds: []string{"ConfigMap", "Pod", "Service"}, Clusters: []string{"*"}},
+		{APIGroups: []string{"apps"}, Kinds: []string{"Deployment", "StatefulSet"}, Clusters: []string{"*"}},
+		{APIGroups: []string{"example.com"}, Kinds: []string{"Role", "RoleBinding"}, Clusters: []string{"*"}},
+		{APIGroups: []string{"monitoring.coreos.com", "rbac.authorization.k8s.io"}, Kinds: []string{WildcardKind}, Clusters: []string{"*"}},
+	}, needed.Compact(served, CompactionPolicy{}))
+
+	// One unneeded kind per group is accepted
+	assert.Equal(t, []ResourceInclusionEntry{
+		{APIGroups: []string{"", "apps", "monitoring.coreos.com", "rbac.authorization.k8s.io"}, Kinds: []string{WildcardKind}, Clusters: []string{"*"}},
+		{APIGroups: []string{"example.com"}, Kinds: []string{"Role", "RoleBinding"}, Clusters: []string{"*"}},
+	}, needed.Compact(served, CompactionPolicy{MaxUnneededKinds: 1}))
+
+	// Without wildcards, the groups needing the same kinds are merged
+	assert.Equal(t, []ResourceInclusionEntry{
+		{APIGroups: []string{""}, Kinds: []string{"ConfigMap", "Pod", "Service"}, Clusters: []string{"*"}},
+		{APIGroups: []string{"apps"}, Kinds: []string{"Deployment", "StatefulSet"}, Clusters: []string{"*"}},
+		{APIGroups: []string{"example.com", "rbac.authorization.k8s.io"}, Kinds: []string{"Role", "RoleBinding"}, Clusters: []string{"*"}},
+		{APIGroups: []string{"monitoring.coreos.com"}, Kinds: []string{"PodMonitor", "ServiceMonitor"}, Clusters: []string{"*"}},
+	}, needed.Compact(served, CompactionPolicy{MaxUnneededKinds: -1}))
+
+	// The compacted inclusions are read back with their wildcards
+	parsed := make(GroupedResourceKinds)
+	assert.NoError(t, parsed.FromYaml(needed.CompactString(served, CompactionPolicy{})))
+	assert.True(t, parsed.Includes("rbac.authorization.k8s.io", "ClusterRole"))
+	assert.True(t, parsed.Includes("example.com", "Role"))
+	assert.True(t, parsed.Includes("", "Pod"))
+	assert.False(t, parsed.Includes("", "Secret"))
+}
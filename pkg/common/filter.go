@@ -0,0 +1,68 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/argoproj/argo-cd/v3/util/settings"
+	"sigs.k8s.io/yaml"
+)
+
+// ResourceFilter is the resource.inclusions and resource.exclusions settings of Argo CD, matched
+// by the filter of Argo CD itself. The entries are kept as written, with all their API groups,
+// kinds and clusters, in their order.
+type ResourceFilter struct {
+	settings.ResourcesFilter
+}
+
+// ParseResourceFilter parses the given resource.inclusions and resource.exclusions settings the
+// way Argo CD does. An empty setting has no entry.
+func ParseResourceFilter(resourceInclusionsYaml, resourceExclusionsYaml string) (*ResourceFilter, error) {
+	inclusions, err := parseResourceEntries(resourceInclusionsYaml)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing resource.inclusions: %w", err)
+	}
+	exclusions, err := parseResourceEntries(resourceExclusionsYaml)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing resource.exclusions: %w", err)
+	}
+	return &ResourceFilter{settings.ResourcesFilter{ResourceInclusions: inclusions, ResourceExclusions: exclusions}}, nil
+}
+
+// Includes returns true if Argo CD watches the given kind of the given group on the given
+// cluster. The exclusions, including the resources Argo CD always excludes, take precedence
+// over the inclusions. Without an inclusion matching the cluster, every kind which is not
+// excluded is watched.
+func (f *ResourceFilter) Includes(group, kind, cluster string) bool {
+	return !f.IsExcludedResource(group, kind, cluster)
+}
+
+// InclusionsYaml returns the resource.inclusions setting of the filter.
+func (f *ResourceFilter) InclusionsYaml() (string, error) {
+	return marshalResourceEntries(f.ResourceInclusions)
+}
+
+// ExclusionsYaml returns the resource.exclusions setting of the filter.
+func (f *ResourceFilter) ExclusionsYaml() (string, error) {
+	return marshalResourceEntries(f.ResourceExclusions)
+}
+
+// parseResourceEntries parses a resource.inclusions or resource.exclusions setting. Like Argo CD,
+// the keys are matched by their JSON name regardless of case.
+func parseResourceEntries(data string) ([]settings.FilteredResource, error) {
+	entries := make([]settings.FilteredResource, 0)
+	if err := yaml.Unmarshal([]byte(data), &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func marshalResourceEntries(entries []settings.FilteredResource) (string, error) {
+	if len(entries) == 0 {
+		return "", nil
+	}
+	out, err := yaml.Marshal(entries)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,89 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/argoproj/argo-cd/v3/util/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ResourceFilter_Includes(t *testing.T) {
+	filter, err := ParseResourceFilter(`
+- apiGroups:
+  - ""
+  - apps
+  kinds:
+  - Deployment
+  - ConfigMap
+  clusters:
+  - https://kubernetes.default.svc
+- apiGroups:
+  - "*.crossplane.io"
+  clusters:
+  - "https://*.example.com"
+`, `
+- apiGroups:
+  - aws.crossplane.io
+  kinds:
+  - Bucket
+`)
+	require.NoError(t, err)
+	inCluster := "https://kubernetes.default.svc"
+	remote := "https://prod.example.com"
+
+	assert.True(t, filter.Includes("apps", "Deployment", inCluster))
+	assert.True(t, filter.Includes("", "ConfigMap", inCluster))
+	assert.False(t, filter.Includes("apps", "StatefulSet", inCluster))
+	// The second entry only applies to the remote clusters
+	assert.False(t, filter.Includes("s3.crossplane.io", "Bucket", inCluster))
+	assert.True(t, filter.Includes("s3.crossplane.io", "Bucket", remote))
+	assert.False(t, filter.Includes("apps", "Deployment", remote))
+	// Exclusions take precedence, including the resources Argo CD always excludes
+	assert.False(t, filter.Includes("aws.crossplane.io", "Bucket", remote))
+	assert.True(t, filter.Includes("aws.crossplane.io", "Queue", remote))
+	assert.False(t, filter.Includes("", "Event", inCluster))
+	// Without an inclusion for the cluster, every kind which is not excluded is watched
+	assert.True(t, filter.Includes("apps", "StatefulSet", "https://other.cluster"))
+	assert.False(t, filter.Includes("aws.crossplane.io", "Bucket", "https://other.cluster"))
+
+	empty, err := ParseResourceFilter("", "")
+	require.NoError(t, err)
+	assert.True(t, empty.Includes("apps", "Deployment", inCluster))
+	assert.False(t, empty.Includes("coordination.k8s.io", "Lease", inCluster))
+}
+
+func Test_ResourceFilter_RoundTrip(t *testing.T) {
+	// The keys are matched regardless of case, like Argo CD does
+	filter, err := ParseResourceFilter(`
+- apigroups:
+  - apps
+  - "*.example.com"
+  kinds:
+  - Deployment
+  clusters:
+  - "*"
+- kinds:
+  - Secret
+`, "")
+	require.NoError(t, err)
+	assert.Equal(t, []settings.FilteredResource{
+		{APIGroups: []string{"apps", "*.example.com"}, Kinds: []string{"Deployment"}, Clusters: []string{"*"}},
+		{Kinds: []string{"Secret"}},
+	}, filter.ResourceInclusions)
+	assert.Empty(t, filter.ResourceExclusions)
+
+	inclusions, err := filter.InclusionsYaml()
+	require.NoError(t, err)
+	exclusions, err := filter.ExclusionsYaml()
+	require.NoError(t, err)
+	assert.Empty(t, exclusions)
+	parsed, err := ParseResourceFilter(inclusions, exclusions)
+	require.NoError(t, err)
+	assert.Equal(t, filter, parsed)
+
+	// FromYaml keeps every API group of an entry
+	groupedKinds := make(GroupedResourceKinds)
+	require.NoError(t, groupedKinds.FromYaml(inclusions))
+	assert.Equal(t, GroupedResourceKinds{"apps": {"Deployment": {}}, "*.example.com": {"Deployment": {}}}, groupedKinds)
+}
@@ -0,0 +1,59 @@
+package common
+
+import "fmt"
+
+// RelationSource tells how a parent -> child relation between two resources was found.
+type RelationSource string
+
+const (
+	// SourceManifest links an Application to a resource of its manifests.
+	SourceManifest RelationSource = "manifest"
+	// SourceOwnerReference links an object to the owner named in its ownerReferences.
+	SourceOwnerReference RelationSource = "ownerReference"
+	// SourceRule links two objects matched by a relationship rule or a relation inferrer.
+	SourceRule RelationSource = "rule"
+	// SourceTracking links an Application to a live object carrying its tracking label or
+	// annotation.
+	SourceTracking RelationSource = "tracking"
+	// SourceApplicationStatus links an Application to a resource listed in its status.
+	SourceApplicationStatus RelationSource = "applicationStatus"
+	// SourceExcludedResourceWarning links an Application to a resource reported in one of its
+	// ExcludedResourceWarning conditions.
+	SourceExcludedResourceWarning RelationSource = "excludedResourceWarning"
+)
+
+// Provenance records how a parent -> child relation was found, along with an example of the
+// objects it links.
+type Provenance struct {
+	Source RelationSource
+	// Rule is the name of the relationship rule or relation inferrer, for SourceRule.
+	Rule string
+	// Cluster is the server of the cluster where the relation was seen.
+	Cluster string
+	Parent  ResourceInfo
+	Child   ResourceInfo
+}
+
+func (p *Provenance) String() string {
+	source := string(p.Source)
+	if p.Rule != "" {
+		source = fmt.Sprintf("%s %s", source, p.Rule)
+	}
+	if p.Cluster != "" {
+		source = fmt.Sprintf("%s on %s", source, p.Cluster)
+	}
+	return fmt.Sprintf("%s -> %s (%s)", objectString(&p.Parent), objectString(&p.Child), source)
+}
+
+// objectString returns the group/kind and namespace/name of the given resource, the parts
+// that are not known being omitted.
+func objectString(r *ResourceInfo) string {
+	out := fmt.Sprintf("%s/%s", r.Group, r.Kind)
+	switch {
+	case r.Namespace != "" && r.Name != "":
+		out = fmt.Sprintf("%s %s/%s", out, r.Namespace, r.Name)
+	case r.Name != "":
+		out = fmt.Sprintf("%s %s", out, r.Name)
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RemediatedKindsAnnotation is the annotation recording, on the resource holding the
+// resource.inclusions settings, the kinds added to the resource inclusions right away for the
+// ExcludedResourceWarning conditions of the applications, as a JSON list of Remediation.
+const RemediatedKindsAnnotation = "resource-tracker.argoproj.io/remediated-kinds"
+
+// MaxRemediations is the number of the latest remediations kept in the RemediatedKindsAnnotation.
+const MaxRemediations = 20
+
+// Remediation records the kinds added to the resource inclusions for the ExcludedResourceWarning
+// conditions of an application.
+type Remediation struct {
+	// Application is the namespace and name of the application, as namespace/name
+	Application string `json:"application"`
+	// Time is the time the kinds were added
+	Time time.Time `json:"time"`
+	// Kinds are the kinds added, by group
+	Kinds map[string][]string `json:"kinds"`
+}
+
+// NewRemediation returns the remediation of the given kinds added for the given application.
+func NewRemediation(application string, added GroupedResourceKinds, addedAt time.Time) Remediation {
+	kinds := make(map[string][]string, len(added))
+	for group, groupKinds := range added {
+		kinds[group] = getUniqueKinds(groupKinds)
+	}
+	return Remediation{Application: application, Time: addedAt.UTC().Truncate(time.Second), Kinds: kinds}
+}
+
+// AppendRemediation appends the given remediation to the remediations of the given annotation
+// value, keeping the latest MaxRemediations of them, and returns the new annotation value.
+func AppendRemediation(annotation string, remediation Remediation) (string, error) {
+	var remediations []Remediation
+	if annotation != "" {
+		if err := json.Unmarshal([]byte(annotation), &remediations); err != nil {
+			return "", fmt.Errorf("invalid %s annotation: %w", RemediatedKindsAnnotation, err)
+		}
+	}
+	remediations = append(remediations, remediation)
+	if len(remediations) > MaxRemediations {
+		remediations = remediations[len(remediations)-MaxRemediations:]
+	}
+	data, err := json.Marshal(remediations)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewRemediation(t *testing.T) {
+	addedAt := time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC)
+	remediation := NewRemediation("argocd/guestbook", GroupedResourceKinds{"apps": {"StatefulSet": {}, "Deployment": {}}}, addedAt)
+	assert.Equal(t, Remediation{
+		Application: "argocd/guestbook",
+		Time:        time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Kinds:       map[string][]string{"apps": {"Deployment", "StatefulSet"}},
+	}, remediation)
+}
+
+func Test_AppendRemediation(t *testing.T) {
+	addedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	annotation, err := AppendRemediation("", NewRemediation("argocd/guestbook", GroupedResourceKinds{"": {"ConfigMap": {}}}, addedAt))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"application":"argocd/guestbook","time":"2025-01-02T03:04:05Z","kinds":{"":["ConfigMap"]}}]`, annotation)
+
+	// Only the latest remediations are kept
+	for i := 0; i < MaxRemediations; i++ {
+		annotation, err = AppendRemediation(annotation, NewRemediation(fmt.Sprintf("argocd/app-%d", i), GroupedResourceKinds{"apps": {"Deployment": {}}}, addedAt))
+		require.NoError(t, err)
+	}
+	var remediations []Remediation
+	require.NoError(t, json.Unmarshal([]byte(annotation), &remediations))
+	require.Len(t, remediations, MaxRemediations)
+	assert.Equal(t, "argocd/app-0", remediations[0].Application)
+	assert.Equal(t, fmt.Sprintf("argocd/app-%d", MaxRemediations-1), remediations[MaxRemediations-1].Application)
+
+	_, err = AppendRemediation("not json", NewRemediation("argocd/guestbook", nil, addedAt))
+	assert.Error(t, err)
+}
@@ -0,0 +1,166 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The set operations of GroupedResourceKinds compare kinds by name, a wildcard kind is a kind
+// like any other. A group without kinds is never part of their result.
+
+// Clone returns a copy of the grouped kinds.
+func (g *GroupedResourceKinds) Clone() GroupedResourceKinds {
+	clone := make(GroupedResourceKinds, len(*g))
+	clone.Merge(*g)
+	return clone
+}
+
+// Merge adds the kinds of other to the grouped kinds.
+func (g *GroupedResourceKinds) Merge(other GroupedResourceKinds) {
+	for group, kinds := range other {
+		if len(kinds) == 0 {
+			continue
+		}
+		if (*g)[group] == nil {
+			(*g)[group] = make(Kinds, len(kinds))
+		}
+		for kind := range kinds {
+			(*g)[group][kind] = Void{}
+		}
+	}
+}
+
+// Union returns the kinds of either the grouped kinds or other.
+func (g *GroupedResourceKinds) Union(other GroupedResourceKinds) GroupedResourceKinds {
+	union := g.Clone()
+	union.Merge(other)
+	return union
+}
+
+// Difference returns the kinds of the grouped kinds which other does not hold.
+func (g *GroupedResourceKinds) Difference(other GroupedResourceKinds) GroupedResourceKinds {
+	return g.filter(func(group, kind string) bool {
+		_, ok := other[group][kind]
+		return !ok
+	})
+}
+
+// Intersect returns the kinds held by both the grouped kinds and other.
+func (g *GroupedResourceKinds) Intersect(other GroupedResourceKinds) GroupedResourceKinds {
+	return g.filter(func(group, kind string) bool {
+		_, ok := other[group][kind]
+		return ok
+	})
+}
+
+// filter returns the kinds of the grouped kinds for which keep returns true.
+func (g *GroupedResourceKinds) filter(keep func(group, kind string) bool) GroupedResourceKinds {
+	result := make(GroupedResourceKinds)
+	for group, kinds := range *g {
+		for kind := range kinds {
+			if !keep(group, kind) {
+				continue
+			}
+			if result[group] == nil {
+				result[group] = make(Kinds)
+			}
+			result[group][kind] = Void{}
+		}
+	}
+	return result
+}
+
+// Diff returns the kinds added and removed from the grouped kinds, the base side, to other.
+func (g *GroupedResourceKinds) Diff(other GroupedResourceKinds) *KindsDiff {
+	return &KindsDiff{
+		Added:   kindChanges(other.Difference(*g), DiffSideOther),
+		Removed: kindChanges(g.Difference(other), DiffSideBase),
+	}
+}
+
+// DiffSide tells which side of a diff a kind is held by.
+type DiffSide string
+
+const (
+	// DiffSideBase is the side the diff is computed from, holding the removed kinds.
+	DiffSideBase DiffSide = "base"
+	// DiffSideOther is the side the diff is computed to, holding the added kinds.
+	DiffSideOther DiffSide = "other"
+)
+
+// KindChange is a kind added or removed by a diff. The empty group stands for the core group.
+type KindChange struct {
+	Group string   `json:"group"`
+	Kind  string   `json:"kind"`
+	Side  DiffSide `json:"side"`
+}
+
+func (c KindChange) String() string {
+	if c.Group == "" {
+		return c.Kind
+	}
+	return fmt.Sprintf("%s/%s", c.Group, c.Kind)
+}
+
+// KindsDiff is the difference between two sets of grouped kinds, sorted by group and kind.
+type KindsDiff struct {
+	// Base and Other name the compared sides, such as the target resource and an analysis.
+	Base    string       `json:"base,omitempty"`
+	Other   string       `json:"other,omitempty"`
+	Added   []KindChange `json:"added"`
+	Removed []KindChange `json:"removed"`
+}
+
+// Empty returns true if both sides hold the same kinds.
+func (d *KindsDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// JSON returns the diff as indented JSON.
+func (d *KindsDiff) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// String returns the diff as text, one kind per line prefixed with + if it was added or - if it
+// was removed, the kinds of the core group without group.
+func (d *KindsDiff) String() string {
+	var sb strings.Builder
+	base, other := d.Base, d.Other
+	if base == "" {
+		base = string(DiffSideBase)
+	}
+	if other == "" {
+		other = string(DiffSideOther)
+	}
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", base, other)
+	for _, change := range d.Removed {
+		fmt.Fprintf(&sb, "- %s\n", change.String())
+	}
+	for _, change := range d.Added {
+		fmt.Fprintf(&sb, "+ %s\n", change.String())
+	}
+	return sb.String()
+}
+
+// kindChanges returns the sorted changes of the given kinds, held by the given side.
+func kindChanges(groupedKinds GroupedResourceKinds, side DiffSide) []KindChange {
+	changes := make([]KindChange, 0)
+	for group, kinds := range groupedKinds {
+		apiGroup := group
+		if group == "core" {
+			apiGroup = ""
+		}
+		for kind := range kinds {
+			changes = append(changes, KindChange{Group: apiGroup, Kind: kind, Side: side})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Group != changes[j].Group {
+			return changes[i].Group < changes[j].Group
+		}
+		return changes[i].Kind < changes[j].Kind
+	})
+	return changes
+}
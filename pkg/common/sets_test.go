@@ -0,0 +1,76 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GroupedResourceKinds_SetOperations(t *testing.T) {
+	base := GroupedResourceKinds{
+		"core": {"ConfigMap": {}, "Secret": {}},
+		"apps": {"Deployment": {}},
+	}
+	other := GroupedResourceKinds{
+		"core":        {"ConfigMap": {}},
+		"example.com": {"Widget": {}},
+		"empty.io":    {},
+	}
+
+	assert.Equal(t, GroupedResourceKinds{
+		"core":        {"ConfigMap": {}, "Secret": {}},
+		"apps":        {"Deployment": {}},
+		"example.com": {"Widget": {}},
+	}, base.Union(other))
+	assert.Equal(t, GroupedResourceKinds{"core": {"Secret": {}}, "apps": {"Deployment": {}}}, base.Difference(other))
+	assert.Equal(t, GroupedResourceKinds{"core": {"ConfigMap": {}}}, base.Intersect(other))
+
+	// The operations leave their operands as they are
+	clone := base.Clone()
+	assert.Equal(t, base, clone)
+	clone["core"]["Pod"] = Void{}
+	assert.NotContains(t, base["core"], "Pod")
+	assert.Len(t, other, 3)
+}
+
+func Test_GroupedResourceKinds_Diff(t *testing.T) {
+	base := GroupedResourceKinds{
+		"core": {"ConfigMap": {}, "Secret": {}},
+		"apps": {"Deployment": {}},
+	}
+	other := GroupedResourceKinds{
+		"core":        {"ConfigMap": {}, "Service": {}},
+		"example.com": {"Widget": {}},
+	}
+
+	diff := base.Diff(other)
+	assert.Equal(t, []KindChange{
+		{Group: "", Kind: "Service", Side: DiffSideOther},
+		{Group: "example.com", Kind: "Widget", Side: DiffSideOther},
+	}, diff.Added)
+	assert.Equal(t, []KindChange{
+		{Group: "", Kind: "Secret", Side: DiffSideBase},
+		{Group: "apps", Kind: "Deployment", Side: DiffSideBase},
+	}, diff.Removed)
+	assert.False(t, diff.Empty())
+	assert.True(t, base.Diff(base.Clone()).Empty())
+
+	diff.Base, diff.Other = "argocd-cm", "analysis"
+	assert.Equal(t, `--- argocd-cm
++++ analysis
+- Secret
+- apps/Deployment
++ Service
++ example.com/Widget
+`, diff.String())
+
+	out, err := diff.JSON()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"side": "other"`)
+	assert.Contains(t, string(out), `"side": "base"`)
+	var decoded KindsDiff
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	assert.Equal(t, *diff, decoded)
+}
@@ -3,33 +3,259 @@ package dynamic
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/anandf/resource-tracker/pkg/common"
 	"github.com/emirpasic/gods/sets/hashset"
 	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/rest"
 )
 
+// RelationChange describes a parent -> child relation that appeared or disappeared while
+// relations are watched.
+type RelationChange struct {
+	// Cluster is the server of the cluster where the change was observed
+	Cluster string
+	Parent  string
+	Child   string
+	// Added is true if the relation appeared, false if it disappeared
+	Added bool
+}
+
+// ClusterRelations holds the relations discovered on one cluster.
+type ClusterRelations struct {
+	// Relations maps parentKey -> set(childKey)
+	Relations map[string]*hashset.Set
+	// Provenance holds how each relation of Relations was first seen
+	Provenance map[Relation]common.Provenance
+	// counts is the number of objects linking parentKey -> childKey, when relations are watched
+	counts map[string]map[string]int
+	// scannedNamespaces is the set of namespaces scanned, v1.NamespaceAll standing for all of them
+	scannedNamespaces map[string]struct{}
+	// lastSeen is the time each relation was last found by a scan
+	lastSeen map[Relation]time.Time
+	// lastRescan is the time of the last scan covering all the scanned namespaces
+	lastRescan time.Time
+}
+
+// dropKind removes the given kind and every relation involving it.
+func (c *ClusterRelations) dropKind(key string) {
+	delete(c.Relations, key)
+	for _, children := range c.Relations {
+		children.Remove(key)
+	}
+	for relation := range c.Provenance {
+		if relation.Parent == key || relation.Child == key {
+			delete(c.Provenance, relation)
+		}
+	}
+	for relation := range c.lastSeen {
+		if relation.Parent == key || relation.Child == key {
+			delete(c.lastSeen, relation)
+		}
+	}
+}
+
+// evictStale removes the relations which were last seen before the given time, and returns them.
+func (c *ClusterRelations) evictStale(before time.Time) []Relation {
+	var evicted []Relation
+	for relation, seen := range c.lastSeen {
+		if !seen.Before(before) {
+			continue
+		}
+		if children, ok := c.Relations[relation.Parent]; ok {
+			children.Remove(relation.Child)
+		}
+		delete(c.Provenance, relation)
+		delete(c.lastSeen, relation)
+		evicted = append(evicted, relation)
+	}
+	slices.SortFunc(evicted, func(a, b Relation) int {
+		if a.Parent != b.Parent {
+			return strings.Compare(a.Parent, b.Parent)
+		}
+		return strings.Compare(a.Child, b.Child)
+	})
+	return evicted
+}
+
+func newClusterRelations() *ClusterRelations {
+	return &ClusterRelations{
+		Relations:         make(map[string]*hashset.Set),
+		Provenance:        make(map[Relation]common.Provenance),
+		counts:            make(map[string]map[string]int),
+		scannedNamespaces: make(map[string]struct{}),
+		lastSeen:          make(map[Relation]time.Time),
+	}
+}
+
 // DynamicTracker handles the analysis of ArgoCD application resources
 type DynamicTracker struct {
+	// ResourceMapperStore holds the resource mapper of each cluster, guarded by CacheMu
 	ResourceMapperStore map[string]*ResourceMapper
-	// shared cache across ALL clusters: parentKey -> set(childKey)
-	SharedRelationsCache map[string]*hashset.Set
-	CacheMu              sync.RWMutex
+	// RelationCaches holds the relations of each cluster, keyed by destination server, so that
+	// the relations seen on one cluster are not applied to the others
+	RelationCaches map[string]*ClusterRelations
+	CacheMu        sync.RWMutex
 	// per-cluster sync locks to avoid concurrent resyncs for the same cluster
 	syncLocks map[string]*sync.Mutex
 	// logger for the tracker
 	logger *log.Entry
+	// watchRelations keeps RelationCaches current from metadata watches instead of full scans
+	watchRelations bool
+	// onRelationChange is called when a watched relation appears or disappears
+	onRelationChange func(RelationChange)
+	// scanOptions is used to create the resource mapper of each cluster
+	scanOptions ScanOptions
+	// relationTTL is the time after which a scanned relation which was not seen again is evicted,
+	// zero keeping relations forever
+	relationTTL time.Duration
+	// now returns the current time, replaced by tests
+	now func() time.Time
 }
 
-// NewDynamicTracker creates a new resource tracker instance
-func NewDynamicTracker(logger *log.Entry) *DynamicTracker {
+// NewDynamicTracker creates a new resource tracker instance, scanning clusters with the given options
+func NewDynamicTracker(logger *log.Entry, scanOptions ScanOptions) *DynamicTracker {
 	return &DynamicTracker{
-		ResourceMapperStore:  make(map[string]*ResourceMapper),
-		SharedRelationsCache: make(map[string]*hashset.Set),
-		syncLocks:            make(map[string]*sync.Mutex),
-		logger:               logger,
+		ResourceMapperStore: make(map[string]*ResourceMapper),
+		RelationCaches:      make(map[string]*ClusterRelations),
+		syncLocks:           make(map[string]*sync.Mutex),
+		logger:              logger,
+		scanOptions:         scanOptions,
+		now:                 time.Now,
+	}
+}
+
+// SetRelationTTL makes the tracker evict the scanned relations which were not seen again within
+// ttl, so that relations of objects which no longer exist stop adding kinds to the results. The
+// namespaces of a cluster are all scanned again once half of ttl has passed since their last
+// scan, for the relations still present to be refreshed in time. Watched relations are removed
+// as soon as no object links them, they are not evicted. Zero disables eviction.
+func (rt *DynamicTracker) SetRelationTTL(ttl time.Duration) {
+	rt.CacheMu.Lock()
+	defer rt.CacheMu.Unlock()
+	rt.relationTTL = ttl
+}
+
+// clusterRelationsLocked returns the relations of the given server, creating them if needed.
+// Callers must hold CacheMu for writing.
+func (rt *DynamicTracker) clusterRelationsLocked(server string) *ClusterRelations {
+	cache, ok := rt.RelationCaches[server]
+	if !ok {
+		cache = newClusterRelations()
+		rt.RelationCaches[server] = cache
+	}
+	return cache
+}
+
+// MissingKeys returns the given resource keys which are not yet in the relation cache of the
+// given server.
+func (rt *DynamicTracker) MissingKeys(server string, keys []string) []string {
+	rt.CacheMu.RLock()
+	defer rt.CacheMu.RUnlock()
+	missing := make([]string, 0)
+	for _, key := range keys {
+		if cache, ok := rt.RelationCaches[server]; ok {
+			if _, exists := cache.Relations[key]; exists {
+				continue
+			}
+		}
+		missing = append(missing, key)
+	}
+	return missing
+}
+
+// AddLeafKeys adds the given resource keys to the relation cache of the given server, without
+// children, unless they are already in it.
+func (rt *DynamicTracker) AddLeafKeys(server string, keys []string) {
+	rt.CacheMu.Lock()
+	defer rt.CacheMu.Unlock()
+	cache := rt.clusterRelationsLocked(server)
+	for _, key := range keys {
+		if _, exists := cache.Relations[key]; !exists {
+			cache.Relations[key] = hashset.New()
+		}
+	}
+}
+
+// GetResourceRelation returns the resource kinds reachable from the given resources through the
+// relations of the given server.
+func (rt *DynamicTracker) GetResourceRelation(server string, directChildren []*common.ResourceInfo) []*common.ResourceInfo {
+	rt.CacheMu.RLock()
+	defer rt.CacheMu.RUnlock()
+	var relations map[string]*hashset.Set
+	if cache, ok := rt.RelationCaches[server]; ok {
+		relations = cache.Relations
+	}
+	return GetResourceRelation(relations, directChildren)
+}
+
+// FindRelationChain returns the chain of relations of the given server leading from the root
+// kind down to the target kind, as FindRelationChain does.
+func (rt *DynamicTracker) FindRelationChain(server, root, target string) []common.Provenance {
+	rt.CacheMu.RLock()
+	defer rt.CacheMu.RUnlock()
+	cache, ok := rt.RelationCaches[server]
+	if !ok {
+		if root == target {
+			return []common.Provenance{}
+		}
+		return nil
 	}
+	return FindRelationChain(cache.Relations, cache.Provenance, root, target)
+}
+
+// MergedRelations returns the relations of all the clusters merged together, along with the
+// provenance of each relation, for the results which are not specific to a cluster. The
+// returned maps are copies.
+func (rt *DynamicTracker) MergedRelations() (map[string]*hashset.Set, map[Relation]common.Provenance) {
+	rt.CacheMu.RLock()
+	defer rt.CacheMu.RUnlock()
+	relations := make(map[string]*hashset.Set)
+	provenance := make(map[Relation]common.Provenance)
+	// Servers are sorted for the provenance of a relation seen on several clusters to be stable
+	servers := make([]string, 0, len(rt.RelationCaches))
+	for server := range rt.RelationCaches {
+		servers = append(servers, server)
+	}
+	slices.Sort(servers)
+	for _, server := range servers {
+		cache := rt.RelationCaches[server]
+		mergeInto(relations, cache.Relations)
+		for relation, example := range cache.Provenance {
+			if _, exists := provenance[relation]; !exists {
+				provenance[relation] = example
+			}
+		}
+	}
+	return relations, provenance
+}
+
+// InvalidateCluster drops the relations discovered on the given server, so that the next
+// analysis scans it again. Relations maintained by a relation watch are kept, as the watch
+// keeps them current.
+func (rt *DynamicTracker) InvalidateCluster(server string) {
+	rt.CacheMu.Lock()
+	defer rt.CacheMu.Unlock()
+	if rt.watchRelations {
+		return
+	}
+	delete(rt.RelationCaches, server)
+}
+
+// EnableRelationWatch makes the tracker maintain the relation caches from metadata watches on
+// every cluster, instead of scanning a cluster when a kind is missing from the cache. Relations
+// are removed once no object links them any more. onChange, if not nil, is called for every
+// relation that appears or disappears. It must be called before any resource mapper is synced.
+func (rt *DynamicTracker) EnableRelationWatch(onChange func(RelationChange)) {
+	rt.CacheMu.Lock()
+	defer rt.CacheMu.Unlock()
+	rt.watchRelations = true
+	rt.onRelationChange = onChange
 }
 
 // GetClusterSyncLock returns a per-cluster mutex, creating it if needed.
@@ -49,39 +275,248 @@ func (rt *DynamicTracker) GetClusterSyncLock(server string) *sync.Mutex {
 	return mu
 }
 
-// EnsureSyncedSharedCacheOnHost ensures the shared cache is synced on the given server.
-func (rt *DynamicTracker) EnsureSyncedSharedCacheOnHost(ctx context.Context, server string) {
+// NamespacesToScan returns the namespaces of the given server that have to be scanned for the
+// given namespaces to be covered by its relation cache. If resync is true, the namespaces scanned
+// before are returned too, so that relations of newly added kinds are found in all of them.
+func (rt *DynamicTracker) NamespacesToScan(server string, namespaces []string, resync bool) []string {
+	rt.CacheMu.RLock()
+	defer rt.CacheMu.RUnlock()
+	var scanned map[string]struct{}
+	if cache, ok := rt.RelationCaches[server]; ok {
+		scanned = cache.scannedNamespaces
+		// The relations are refreshed before the oldest of them is evicted
+		if rt.relationTTL > 0 && !rt.watchRelations && len(scanned) > 0 && rt.now().Sub(cache.lastRescan) >= rt.relationTTL/2 {
+			resync = true
+		}
+	}
+	if resync {
+		if _, ok := scanned[v1.NamespaceAll]; ok || slices.Contains(namespaces, v1.NamespaceAll) {
+			return []string{v1.NamespaceAll}
+		}
+		out := slices.Clone(namespaces)
+		for namespace := range scanned {
+			if !slices.Contains(out, namespace) {
+				out = append(out, namespace)
+			}
+		}
+		slices.Sort(out)
+		return out
+	}
+	if _, ok := scanned[v1.NamespaceAll]; ok {
+		return nil
+	}
+	out := make([]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		if _, ok := scanned[namespace]; !ok {
+			out = append(out, namespace)
+		}
+	}
+	return out
+}
+
+// markNamespacesScanned records that the given namespaces of the server are covered by the
+// relation cache of the server. Callers must hold CacheMu for writing.
+func (rt *DynamicTracker) markNamespacesScanned(server string, namespaces []string) {
+	cache := rt.clusterRelationsLocked(server)
+	if slices.Contains(namespaces, v1.NamespaceAll) {
+		cache.scannedNamespaces = map[string]struct{}{v1.NamespaceAll: {}}
+		return
+	}
+	for _, namespace := range namespaces {
+		cache.scannedNamespaces[namespace] = struct{}{}
+	}
+}
 
+// EnsureSyncedSharedCacheOnHost ensures the relation cache of the given server is synced, for the
+// objects in the given namespaces. Watched relations always cover all the namespaces.
+func (rt *DynamicTracker) EnsureSyncedSharedCacheOnHost(ctx context.Context, server string, namespaces []string) {
+	rt.CacheMu.RLock()
 	mapper, ok := rt.ResourceMapperStore[server]
+	rt.CacheMu.RUnlock()
 	if !ok || mapper == nil {
 		rt.logger.Warningf("No mapper for host %s", server)
 		return
 	}
-	rt.logger.Infof("Querying relations host=%s", server)
-	rel, err := mapper.GetClusterResourcesRelation(ctx)
+	if rt.watchRelations {
+		// The cache is maintained by the relation watch, it is current once the watch has synced
+		if !mapper.WaitForRelationsSynced(ctx) {
+			rt.logger.Warningf("Relation watch on %s did not sync", server)
+			return
+		}
+		rt.CacheMu.Lock()
+		rt.markNamespacesScanned(server, []string{v1.NamespaceAll})
+		rt.CacheMu.Unlock()
+		return
+	}
+	rt.logger.Infof("Querying relations host=%s namespaces=%q", server, namespaces)
+	rel, provenance, err := mapper.GetClusterResourcesRelation(ctx, namespaces)
 	if err != nil {
 		rt.logger.Warningf("Dynamic scan on %s failed: %v", server, err)
 		return
 	}
 	rt.CacheMu.Lock()
-	mergeInto(rt.SharedRelationsCache, rel)
-	rt.CacheMu.Unlock()
+	defer rt.CacheMu.Unlock()
+	now := rt.now()
+	cache := rt.clusterRelationsLocked(server)
+	mergeInto(cache.Relations, rel)
+	for parent, children := range rel {
+		for _, v := range children.Values() {
+			if child, ok := v.(string); ok {
+				cache.lastSeen[Relation{Parent: parent, Child: child}] = now
+			}
+		}
+	}
+	for relation, example := range provenance {
+		if _, exists := cache.Provenance[relation]; !exists {
+			example.Cluster = server
+			cache.Provenance[relation] = example
+		}
+	}
+	if coversNamespaces(namespaces, cache.scannedNamespaces) {
+		cache.lastRescan = now
+	}
+	rt.markNamespacesScanned(server, namespaces)
+	if rt.relationTTL <= 0 {
+		return
+	}
+	for _, relation := range cache.evictStale(now.Add(-rt.relationTTL)) {
+		rt.logger.WithFields(log.Fields{
+			"cluster": server,
+			"parent":  relation.Parent,
+			"child":   relation.Child,
+			"ttl":     rt.relationTTL,
+		}).Info("Evicted relation not seen within its time to live")
+	}
+}
+
+// coversNamespaces returns true if the given namespaces include all the scanned namespaces.
+func coversNamespaces(namespaces []string, scanned map[string]struct{}) bool {
+	if slices.Contains(namespaces, v1.NamespaceAll) {
+		return true
+	}
+	for namespace := range scanned {
+		if !slices.Contains(namespaces, namespace) {
+			return false
+		}
+	}
+	return true
 }
 
 // SyncResourceMapper syncs the resource mapper for the given server.
 func (rt *DynamicTracker) SyncResourceMapper(server string, restCfg *rest.Config) error {
-	if _, exists := rt.ResourceMapperStore[server]; !exists {
-		mapper, err := NewResourceMapper(restCfg)
-		if err != nil {
-			return fmt.Errorf("failed to create ResourceMapper: %w", err)
+	// The cluster lock prevents two goroutines from creating a mapper for the same cluster
+	clusterLock := rt.GetClusterSyncLock(server)
+	clusterLock.Lock()
+	defer clusterLock.Unlock()
+	rt.CacheMu.RLock()
+	_, exists := rt.ResourceMapperStore[server]
+	rt.CacheMu.RUnlock()
+	if exists {
+		return nil
+	}
+	mapper, err := NewResourceMapper(restCfg, rt.scanOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create ResourceMapper: %w", err)
+	}
+	mapper.OnResourceChange(rt.resourceChangeHandler(server))
+	// Start CRD informer so add/update/delete events update the resources of the mapper
+	mapper.StartInformer()
+	if rt.watchRelations {
+		if err := mapper.WatchRelations(rt.relationHandler(server)); err != nil {
+			mapper.Stop()
+			return fmt.Errorf("failed to watch relations: %w", err)
 		}
-		// Start CRD informer so add/update events invoke addToResourceList
-		go mapper.StartInformer()
-		rt.ResourceMapperStore[server] = mapper
 	}
+	rt.CacheMu.Lock()
+	rt.ResourceMapperStore[server] = mapper
+	rt.CacheMu.Unlock()
 	return nil
 }
 
+// Stop stops the resource mappers of all the clusters. The tracker must not be used afterwards.
+func (rt *DynamicTracker) Stop() {
+	rt.CacheMu.Lock()
+	mappers := rt.ResourceMapperStore
+	rt.ResourceMapperStore = make(map[string]*ResourceMapper)
+	rt.CacheMu.Unlock()
+	for _, mapper := range mappers {
+		mapper.Stop()
+	}
+}
+
+// resourceChangeHandler returns the function which drops the relations of a kind from the
+// relation cache of the given server, when the CRD informer adds or removes resources of the
+// kind. Relations of the kind are found again by the next scan needing them. Watched relations
+// are kept current by the relation watch instead.
+func (rt *DynamicTracker) resourceChangeHandler(server string) func(key string) {
+	return func(key string) {
+		rt.CacheMu.Lock()
+		defer rt.CacheMu.Unlock()
+		cache, ok := rt.RelationCaches[server]
+		if rt.watchRelations || !ok {
+			return
+		}
+		rt.logger.WithFields(log.Fields{
+			"cluster": server,
+			"kind":    key,
+		}).Info("Resources of kind changed, dropping its relations")
+		cache.dropKind(key)
+	}
+}
+
+// relationHandler returns the RelationHandler which counts the links reported by the relation
+// watch of the given server, and updates its relation cache when a relation appears or disappears.
+func (rt *DynamicTracker) relationHandler(server string) RelationHandler {
+	return func(relation Relation, provenance common.Provenance, delta int) {
+		parent, child := relation.Parent, relation.Child
+		rt.CacheMu.Lock()
+		cache := rt.clusterRelationsLocked(server)
+		if _, ok := cache.counts[parent]; !ok {
+			cache.counts[parent] = make(map[string]int)
+		}
+		before := cache.counts[parent][child]
+		after := before + delta
+		if after > 0 {
+			cache.counts[parent][child] = after
+		} else {
+			delete(cache.counts[parent], child)
+		}
+		var change *RelationChange
+		switch {
+		case before <= 0 && after > 0:
+			if _, ok := cache.Relations[parent]; !ok {
+				cache.Relations[parent] = hashset.New()
+			}
+			cache.Relations[parent].Add(child)
+			provenance.Cluster = server
+			cache.Provenance[relation] = provenance
+			change = &RelationChange{Cluster: server, Parent: parent, Child: child, Added: true}
+		case before > 0 && after <= 0:
+			// Keep the parent entry, so that it is not reported as missing from the cache
+			if children, ok := cache.Relations[parent]; ok {
+				children.Remove(child)
+			}
+			delete(cache.Provenance, relation)
+			change = &RelationChange{Cluster: server, Parent: parent, Child: child, Added: false}
+		}
+		onChange := rt.onRelationChange
+		rt.CacheMu.Unlock()
+
+		if change == nil {
+			return
+		}
+		rt.logger.WithFields(log.Fields{
+			"cluster": server,
+			"parent":  parent,
+			"child":   child,
+			"added":   change.Added,
+		}).Debug("Watched relation changed")
+		if onChange != nil {
+			onChange(*change)
+		}
+	}
+}
+
 // mergeInto adds rel (parent -> children) into dst
 func mergeInto(dst, rel map[string]*hashset.Set) {
 	for p, set := range rel {
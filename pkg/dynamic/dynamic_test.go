@@ -0,0 +1,161 @@
+package dynamic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_relationHandler(t *testing.T) {
+	const server = "https://kubernetes.default.svc"
+
+	t.Run("relations are removed once no object links them", func(t *testing.T) {
+		var changes []RelationChange
+		rt := NewDynamicTracker(log.NewEntry(log.New()), DefaultScanOptions)
+		rt.EnableRelationWatch(func(change RelationChange) {
+			changes = append(changes, change)
+		})
+		handler := rt.relationHandler(server)
+		relation := Relation{Parent: "apps_Deployment", Child: "apps_ReplicaSet"}
+		provenance := common.Provenance{
+			Source: common.SourceOwnerReference,
+			Parent: common.ResourceInfo{Group: "apps", Kind: "Deployment", Namespace: "guestbook", Name: "guestbook-ui"},
+			Child:  common.ResourceInfo{Group: "apps", Kind: "ReplicaSet", Namespace: "guestbook", Name: "guestbook-ui-5d8f"},
+		}
+
+		handler(relation, provenance, 1)
+		handler(relation, provenance, 1)
+		assert.True(t, rt.RelationCaches[server].Relations["apps_Deployment"].Contains("apps_ReplicaSet"))
+		assert.Equal(t, "https://kubernetes.default.svc", rt.RelationCaches[server].Provenance[relation].Cluster)
+		handler(relation, provenance, -1)
+		assert.True(t, rt.RelationCaches[server].Relations["apps_Deployment"].Contains("apps_ReplicaSet"))
+		handler(relation, provenance, -1)
+		assert.False(t, rt.RelationCaches[server].Relations["apps_Deployment"].Contains("apps_ReplicaSet"))
+		assert.NotContains(t, rt.RelationCaches[server].Provenance, relation)
+
+		assert.Equal(t, []RelationChange{
+			{Cluster: "https://kubernetes.default.svc", Parent: "apps_Deployment", Child: "apps_ReplicaSet", Added: true},
+			{Cluster: "https://kubernetes.default.svc", Parent: "apps_Deployment", Child: "apps_ReplicaSet", Added: false},
+		}, changes)
+	})
+
+	t.Run("unmatched removals do not leave negative counts", func(t *testing.T) {
+		rt := NewDynamicTracker(log.NewEntry(log.New()), DefaultScanOptions)
+		rt.EnableRelationWatch(nil)
+		handler := rt.relationHandler(server)
+
+		relation := Relation{Parent: "core_ServiceAccount", Child: "core_Secret"}
+		handler(relation, common.Provenance{}, -1)
+		handler(relation, common.Provenance{}, 1)
+		assert.True(t, rt.RelationCaches[server].Relations["core_ServiceAccount"].Contains("core_Secret"))
+	})
+}
+
+func Test_NamespacesToScan(t *testing.T) {
+	const server = "https://kubernetes.default.svc"
+
+	t.Run("only namespaces not scanned yet are returned", func(t *testing.T) {
+		rt := NewDynamicTracker(log.NewEntry(log.New()), DefaultScanOptions)
+		assert.Equal(t, []string{"guestbook"}, rt.NamespacesToScan(server, []string{"guestbook"}, false))
+		rt.markNamespacesScanned(server, []string{"guestbook"})
+		assert.Empty(t, rt.NamespacesToScan(server, []string{"guestbook"}, false))
+		assert.Equal(t, []string{"shared"}, rt.NamespacesToScan(server, []string{"guestbook", "shared"}, false))
+		assert.Equal(t, []string{"guestbook"}, rt.NamespacesToScan("https://other.cluster", []string{"guestbook"}, false))
+	})
+
+	t.Run("a resync includes the namespaces scanned before", func(t *testing.T) {
+		rt := NewDynamicTracker(log.NewEntry(log.New()), DefaultScanOptions)
+		rt.markNamespacesScanned(server, []string{"guestbook"})
+		assert.Equal(t, []string{"guestbook", "shared"}, rt.NamespacesToScan(server, []string{"shared"}, true))
+	})
+
+	t.Run("a cluster wide scan covers every namespace", func(t *testing.T) {
+		rt := NewDynamicTracker(log.NewEntry(log.New()), DefaultScanOptions)
+		rt.markNamespacesScanned(server, []string{v1.NamespaceAll})
+		assert.Empty(t, rt.NamespacesToScan(server, []string{"guestbook"}, false))
+		assert.Equal(t, []string{v1.NamespaceAll}, rt.NamespacesToScan(server, []string{"guestbook"}, true))
+	})
+}
+
+func Test_RelationCaches(t *testing.T) {
+	const (
+		kubernetes = "https://kubernetes.default.svc"
+		openshift  = "https://api.openshift.example.com:6443"
+	)
+	rt := NewDynamicTracker(log.NewEntry(log.New()), DefaultScanOptions)
+	rt.relationHandler(openshift)(Relation{Parent: "apps.openshift.io_DeploymentConfig", Child: "core_ReplicationController"}, common.Provenance{}, 1)
+	rt.relationHandler(kubernetes)(Relation{Parent: "apps_Deployment", Child: "apps_ReplicaSet"}, common.Provenance{}, 1)
+	rt.AddLeafKeys(kubernetes, []string{"core_ConfigMap"})
+
+	t.Run("relations only apply to the cluster they were seen on", func(t *testing.T) {
+		manifests := []*common.ResourceInfo{{Group: "apps.openshift.io", Kind: "DeploymentConfig", Name: "guestbook"}}
+		assert.Len(t, rt.GetResourceRelation(openshift, manifests), 2)
+		assert.Len(t, rt.GetResourceRelation(kubernetes, manifests), 1)
+		assert.Equal(t, []string{"apps.openshift.io_DeploymentConfig"}, rt.MissingKeys(kubernetes, []string{"apps.openshift.io_DeploymentConfig", "core_ConfigMap"}))
+		assert.Nil(t, rt.FindRelationChain(kubernetes, "apps.openshift.io_DeploymentConfig", "core_ReplicationController"))
+		assert.Len(t, rt.FindRelationChain(openshift, "apps.openshift.io_DeploymentConfig", "core_ReplicationController"), 1)
+	})
+
+	t.Run("the merged view holds the relations of every cluster", func(t *testing.T) {
+		relations, provenance := rt.MergedRelations()
+		assert.True(t, relations["apps.openshift.io_DeploymentConfig"].Contains("core_ReplicationController"))
+		assert.True(t, relations["apps_Deployment"].Contains("apps_ReplicaSet"))
+		assert.Contains(t, relations, "core_ConfigMap")
+		assert.Equal(t, kubernetes, provenance[Relation{Parent: "apps_Deployment", Child: "apps_ReplicaSet"}].Cluster)
+	})
+
+	t.Run("invalidating a cluster keeps the others", func(t *testing.T) {
+		rt := NewDynamicTracker(log.NewEntry(log.New()), DefaultScanOptions)
+		rt.AddLeafKeys(kubernetes, []string{"core_ConfigMap"})
+		rt.AddLeafKeys(openshift, []string{"core_ConfigMap"})
+		rt.CacheMu.Lock()
+		rt.markNamespacesScanned(kubernetes, []string{"guestbook"})
+		rt.CacheMu.Unlock()
+
+		rt.InvalidateCluster(kubernetes)
+		assert.Equal(t, []string{"core_ConfigMap"}, rt.MissingKeys(kubernetes, []string{"core_ConfigMap"}))
+		assert.Empty(t, rt.MissingKeys(openshift, []string{"core_ConfigMap"}))
+		assert.Equal(t, []string{"guestbook"}, rt.NamespacesToScan(kubernetes, []string{"guestbook"}, false))
+	})
+}
+
+func Test_RelationTTL(t *testing.T) {
+	const server = "https://kubernetes.default.svc"
+	ctx := context.Background()
+	rm := newTestResourceMapper(t,
+		map[schema.GroupVersionResource]string{replicaSetsGVR: "ReplicaSet", podsGVR: "Pod"},
+		newPartialObject("apps/v1", "ReplicaSet", "guestbook-ui-5d8f",
+			v1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "guestbook-ui"}),
+		newPartialObject("v1", "Pod", "guestbook-ui-5d8f-x2k4",
+			v1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "guestbook-ui-5d8f"}),
+	)
+	rt := NewDynamicTracker(log.NewEntry(log.New()), DefaultScanOptions)
+	rt.ResourceMapperStore[server] = rm
+	rt.SetRelationTTL(time.Hour)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rt.now = func() time.Time { return now }
+
+	rt.EnsureSyncedSharedCacheOnHost(ctx, server, []string{"guestbook"})
+	assert.True(t, rt.RelationCaches[server].Relations["apps_Deployment"].Contains("apps_ReplicaSet"))
+	assert.Empty(t, rt.NamespacesToScan(server, []string{"guestbook"}, false))
+
+	// The pod is gone, its relation is evicted once it was not seen again within the TTL
+	require.NoError(t, rm.MetadataClient.Resource(podsGVR).Namespace("guestbook").Delete(ctx, "guestbook-ui-5d8f-x2k4", v1.DeleteOptions{}))
+	now = now.Add(40 * time.Minute)
+	require.Equal(t, []string{"guestbook"}, rt.NamespacesToScan(server, []string{"guestbook"}, false))
+	rt.EnsureSyncedSharedCacheOnHost(ctx, server, []string{"guestbook"})
+	assert.True(t, rt.RelationCaches[server].Relations["apps_ReplicaSet"].Contains("core_Pod"))
+
+	now = now.Add(40 * time.Minute)
+	rt.EnsureSyncedSharedCacheOnHost(ctx, server, []string{"guestbook"})
+	assert.False(t, rt.RelationCaches[server].Relations["apps_ReplicaSet"].Contains("core_Pod"))
+	assert.NotContains(t, rt.RelationCaches[server].Provenance, Relation{Parent: "apps_ReplicaSet", Child: "core_Pod"})
+	assert.True(t, rt.RelationCaches[server].Relations["apps_Deployment"].Contains("apps_ReplicaSet"))
+}
@@ -0,0 +1,108 @@
+package dynamic
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Relation is a parent -> child relation between two kinds, identified by their resource keys.
+type Relation struct {
+	Parent string
+	Child  string
+}
+
+// ObjectRelation is a relation between two objects, which relates their kinds.
+type ObjectRelation struct {
+	Parent common.ResourceInfo
+	Child  common.ResourceInfo
+}
+
+// Relation returns the relation between the kinds of the objects.
+func (r ObjectRelation) Relation() Relation {
+	return Relation{
+		Parent: ResourceInfoKey(r.Parent.Group, r.Parent.Kind),
+		Child:  ResourceInfoKey(r.Child.Group, r.Child.Kind),
+	}
+}
+
+// objectInfo returns the ResourceInfo of the object of the given type, namespace and name.
+func objectInfo(apiVersion, kind, namespace, name string) common.ResourceInfo {
+	group := ""
+	if gv, err := schema.ParseGroupVersion(apiVersion); err == nil {
+		group = gv.Group
+	}
+	return common.ResourceInfo{Group: group, Kind: kind, Namespace: namespace, Name: name}
+}
+
+// ResourceType describes a resource served by a cluster.
+type ResourceType struct {
+	GVR        schema.GroupVersionResource
+	Kind       string
+	Categories []string
+}
+
+// Key returns the resource key of the kind of the resource.
+func (t ResourceType) Key() string {
+	return GetResourceKey(t.GVR.GroupVersion().String(), t.Kind)
+}
+
+// RelationInferrer infers relations which are not expressed by ownerReferences, from the
+// content of the objects of the resources it matches. Inferred relations are discovered
+// alongside the ownerReferences, during scans as well as by relation watches.
+type RelationInferrer interface {
+	// Name identifies the inferrer.
+	Name() string
+	// Matches returns true if the objects of the given resource have to be passed to Infer. The
+	// cluster-scoped resources are only scanned if an inferrer matches them.
+	Matches(resource ResourceType) bool
+	// MetadataOnly returns true if Infer only reads the metadata of the objects, which can then
+	// be listed without their content.
+	MetadataOnly() bool
+	// Infer returns the relations of the given object to other objects. The object only holds
+	// its type and metadata when MetadataOnly is true.
+	Infer(obj *unstructured.Unstructured) []ObjectRelation
+}
+
+var (
+	inferrersMu sync.RWMutex
+	// relationInferrers are the inferrers used by every ResourceMapper
+	relationInferrers = []RelationInferrer{
+		statefulSetClaimsInferrer{},
+		scaleTargetInferrer{},
+		serviceAccountTokenInferrer{},
+		operatorGroupInferrer{},
+		crossplaneInferrer{},
+	}
+)
+
+// RegisterRelationInferrer adds an inferrer to the ones used by every ResourceMapper. It has
+// to be called before the resource mappers are created to apply to all the relation watches.
+func RegisterRelationInferrer(inferrer RelationInferrer) {
+	inferrersMu.Lock()
+	defer inferrersMu.Unlock()
+	relationInferrers = append(relationInferrers, inferrer)
+}
+
+// inferrersFor returns the registered inferrers that match the given resource.
+func inferrersFor(resource ResourceType) []RelationInferrer {
+	inferrersMu.RLock()
+	defer inferrersMu.RUnlock()
+	var out []RelationInferrer
+	for _, inferrer := range relationInferrers {
+		if inferrer.Matches(resource) {
+			out = append(out, inferrer)
+		}
+	}
+	return out
+}
+
+// needObjects returns true if one of the given inferrers reads more than the object metadata.
+func needObjects(inferrers []RelationInferrer) bool {
+	return slices.ContainsFunc(inferrers, func(inferrer RelationInferrer) bool {
+		return !inferrer.MetadataOnly()
+	})
+}
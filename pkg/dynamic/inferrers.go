@@ -0,0 +1,168 @@
+package dynamic
+
+import (
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// statefulSetClaimsInferrer links StatefulSets to the PersistentVolumeClaims created from their
+// volumeClaimTemplates, which are only owned by the StatefulSet with some retention policies.
+type statefulSetClaimsInferrer struct{}
+
+func (statefulSetClaimsInferrer) Name() string { return "statefulset-volume-claims" }
+
+func (statefulSetClaimsInferrer) Matches(resource ResourceType) bool {
+	return resource.GVR.Group == "apps" && resource.Kind == "StatefulSet"
+}
+
+func (statefulSetClaimsInferrer) MetadataOnly() bool { return false }
+
+func (statefulSetClaimsInferrer) Infer(obj *unstructured.Unstructured) []ObjectRelation {
+	templates, _, _ := unstructured.NestedSlice(obj.Object, "spec", "volumeClaimTemplates")
+	if len(templates) == 0 {
+		return nil
+	}
+	// The claim of the first replica is given as example
+	template, _ := templates[0].(map[string]interface{})
+	templateName, _, _ := unstructured.NestedString(template, "metadata", "name")
+	return []ObjectRelation{{
+		Parent: objectInfo(obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName()),
+		Child:  objectInfo("v1", "PersistentVolumeClaim", obj.GetNamespace(), fmt.Sprintf("%s-%s-0", templateName, obj.GetName())),
+	}}
+}
+
+// scaleTargetInferrer links the targets of HorizontalPodAutoscalers and VerticalPodAutoscalers
+// to the autoscalers.
+type scaleTargetInferrer struct{}
+
+func (scaleTargetInferrer) Name() string { return "autoscaler-scale-target" }
+
+func (scaleTargetInferrer) Matches(resource ResourceType) bool {
+	return (resource.GVR.Group == "autoscaling" && resource.Kind == "HorizontalPodAutoscaler") ||
+		(resource.GVR.Group == "autoscaling.k8s.io" && resource.Kind == "VerticalPodAutoscaler")
+}
+
+func (scaleTargetInferrer) MetadataOnly() bool { return false }
+
+func (scaleTargetInferrer) Infer(obj *unstructured.Unstructured) []ObjectRelation {
+	field := "scaleTargetRef"
+	if obj.GetKind() == "VerticalPodAutoscaler" {
+		field = "targetRef"
+	}
+	target, _, _ := unstructured.NestedStringMap(obj.Object, "spec", field)
+	if target["kind"] == "" {
+		return nil
+	}
+	return []ObjectRelation{{
+		Parent: objectInfo(target["apiVersion"], target["kind"], obj.GetNamespace(), target["name"]),
+		Child:  objectInfo(obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName()),
+	}}
+}
+
+// serviceAccountTokenInferrer links ServiceAccounts to their token Secrets.
+type serviceAccountTokenInferrer struct{}
+
+// serviceAccountNameAnnotation is set on the token Secrets of a ServiceAccount.
+const serviceAccountNameAnnotation = "kubernetes.io/service-account.name"
+
+func (serviceAccountTokenInferrer) Name() string { return "serviceaccount-token" }
+
+func (serviceAccountTokenInferrer) Matches(resource ResourceType) bool {
+	return resource.GVR.Group == "" && resource.Kind == "Secret"
+}
+
+func (serviceAccountTokenInferrer) MetadataOnly() bool { return true }
+
+func (serviceAccountTokenInferrer) Infer(obj *unstructured.Unstructured) []ObjectRelation {
+	serviceAccount := obj.GetAnnotations()[serviceAccountNameAnnotation]
+	if serviceAccount == "" {
+		return nil
+	}
+	return []ObjectRelation{{
+		Parent: objectInfo("v1", "ServiceAccount", obj.GetNamespace(), serviceAccount),
+		Child:  objectInfo("v1", "Secret", obj.GetNamespace(), obj.GetName()),
+	}}
+}
+
+// operatorGroupInferrer links OLM OperatorGroups to the ClusterServiceVersions they select.
+type operatorGroupInferrer struct{}
+
+const (
+	// operatorGroupAnnotation is set by OLM on the ClusterServiceVersions of an OperatorGroup.
+	operatorGroupAnnotation = "olm.operatorGroup"
+	// operatorNamespaceAnnotation is set by OLM to the namespace of the OperatorGroup.
+	operatorNamespaceAnnotation = "olm.operatorNamespace"
+)
+
+func (operatorGroupInferrer) Name() string { return "olm-operatorgroup" }
+
+func (operatorGroupInferrer) Matches(resource ResourceType) bool {
+	return resource.GVR.Group == "operators.coreos.com" && resource.Kind == "ClusterServiceVersion"
+}
+
+func (operatorGroupInferrer) MetadataOnly() bool { return true }
+
+func (operatorGroupInferrer) Infer(obj *unstructured.Unstructured) []ObjectRelation {
+	annotations := obj.GetAnnotations()
+	operatorGroup := annotations[operatorGroupAnnotation]
+	if operatorGroup == "" {
+		return nil
+	}
+	namespace := annotations[operatorNamespaceAnnotation]
+	if namespace == "" {
+		namespace = obj.GetNamespace()
+	}
+	return []ObjectRelation{{
+		Parent: objectInfo("operators.coreos.com/v1", "OperatorGroup", namespace, operatorGroup),
+		Child:  objectInfo(obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName()),
+	}}
+}
+
+// crossplaneInferrer links Crossplane composite resources to the resources they are composed of,
+// and claims to their composite resource. The composite resources are cluster-scoped before
+// Crossplane v2, they are scanned as the inferrer matches them.
+type crossplaneInferrer struct{}
+
+func (crossplaneInferrer) Name() string { return "crossplane-resource-refs" }
+
+func (crossplaneInferrer) Matches(resource ResourceType) bool {
+	return slices.Contains(resource.Categories, "composite") || slices.Contains(resource.Categories, "claim")
+}
+
+func (crossplaneInferrer) MetadataOnly() bool { return false }
+
+func (crossplaneInferrer) Infer(obj *unstructured.Unstructured) []ObjectRelation {
+	parent := objectInfo(obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName())
+	var refs []interface{}
+	// Composite resources list their composed resources in spec.resourceRefs, or in
+	// spec.crossplane.resourceRefs from Crossplane v2.
+	for _, path := range [][]string{{"spec", "resourceRefs"}, {"spec", "crossplane", "resourceRefs"}} {
+		found, _, _ := unstructured.NestedSlice(obj.Object, path...)
+		refs = append(refs, found...)
+	}
+	// Claims reference their composite resource in spec.resourceRef
+	if ref, ok, _ := unstructured.NestedMap(obj.Object, "spec", "resourceRef"); ok {
+		refs = append(refs, ref)
+	}
+	var relations []ObjectRelation
+	for _, ref := range refs {
+		ref, ok := ref.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		apiVersion, _, _ := unstructured.NestedString(ref, "apiVersion")
+		kind, _, _ := unstructured.NestedString(ref, "kind")
+		if kind == "" {
+			continue
+		}
+		namespace, _, _ := unstructured.NestedString(ref, "namespace")
+		name, _, _ := unstructured.NestedString(ref, "name")
+		relations = append(relations, ObjectRelation{
+			Parent: parent,
+			Child:  objectInfo(apiVersion, kind, namespace, name),
+		})
+	}
+	return relations
+}
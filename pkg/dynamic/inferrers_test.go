@@ -0,0 +1,198 @@
+package dynamic
+
+import (
+	"testing"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_RelationInferrers(t *testing.T) {
+	tests := []struct {
+		name     string
+		inferrer RelationInferrer
+		resource ResourceType
+		object   map[string]interface{}
+		expected []Relation
+	}{
+		{
+			name:     "StatefulSet with volumeClaimTemplates",
+			inferrer: statefulSetClaimsInferrer{},
+			resource: ResourceType{GVR: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}, Kind: "StatefulSet"},
+			object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "StatefulSet",
+				"spec": map[string]interface{}{
+					"volumeClaimTemplates": []interface{}{map[string]interface{}{}},
+				},
+			},
+			expected: []Relation{{Parent: "apps_StatefulSet", Child: "core_PersistentVolumeClaim"}},
+		},
+		{
+			name:     "StatefulSet without volumeClaimTemplates",
+			inferrer: statefulSetClaimsInferrer{},
+			resource: ResourceType{GVR: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}, Kind: "StatefulSet"},
+			object:   map[string]interface{}{"apiVersion": "apps/v1", "kind": "StatefulSet"},
+		},
+		{
+			name:     "HorizontalPodAutoscaler scale target",
+			inferrer: scaleTargetInferrer{},
+			resource: ResourceType{GVR: schema.GroupVersionResource{Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"}, Kind: "HorizontalPodAutoscaler"},
+			object: map[string]interface{}{
+				"apiVersion": "autoscaling/v2",
+				"kind":       "HorizontalPodAutoscaler",
+				"spec": map[string]interface{}{
+					"scaleTargetRef": map[string]interface{}{"apiVersion": "argoproj.io/v1alpha1", "kind": "Rollout", "name": "guestbook"},
+				},
+			},
+			expected: []Relation{{Parent: "argoproj.io_Rollout", Child: "autoscaling_HorizontalPodAutoscaler"}},
+		},
+		{
+			name:     "VerticalPodAutoscaler target",
+			inferrer: scaleTargetInferrer{},
+			resource: ResourceType{GVR: schema.GroupVersionResource{Group: "autoscaling.k8s.io", Version: "v1", Resource: "verticalpodautoscalers"}, Kind: "VerticalPodAutoscaler"},
+			object: map[string]interface{}{
+				"apiVersion": "autoscaling.k8s.io/v1",
+				"kind":       "VerticalPodAutoscaler",
+				"spec": map[string]interface{}{
+					"targetRef": map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": "guestbook"},
+				},
+			},
+			expected: []Relation{{Parent: "apps_Deployment", Child: "autoscaling.k8s.io_VerticalPodAutoscaler"}},
+		},
+		{
+			name:     "ServiceAccount token Secret",
+			inferrer: serviceAccountTokenInferrer{},
+			resource: ResourceType{GVR: schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, Kind: "Secret"},
+			object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{serviceAccountNameAnnotation: "builder"},
+				},
+			},
+			expected: []Relation{{Parent: "core_ServiceAccount", Child: "core_Secret"}},
+		},
+		{
+			name:     "ClusterServiceVersion of an OperatorGroup",
+			inferrer: operatorGroupInferrer{},
+			resource: ResourceType{GVR: schema.GroupVersionResource{Group: "operators.coreos.com", Version: "v1alpha1", Resource: "clusterserviceversions"}, Kind: "ClusterServiceVersion"},
+			object: map[string]interface{}{
+				"apiVersion": "operators.coreos.com/v1alpha1",
+				"kind":       "ClusterServiceVersion",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{operatorGroupAnnotation: "global-operators"},
+				},
+			},
+			expected: []Relation{{Parent: "operators.coreos.com_OperatorGroup", Child: "operators.coreos.com_ClusterServiceVersion"}},
+		},
+		{
+			name:     "Crossplane composite resource",
+			inferrer: crossplaneInferrer{},
+			resource: ResourceType{GVR: schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "xdatabases"}, Kind: "XDatabase", Categories: []string{"crossplane", "composite"}},
+			object: map[string]interface{}{
+				"apiVersion": "example.org/v1",
+				"kind":       "XDatabase",
+				"spec": map[string]interface{}{
+					"resourceRefs": []interface{}{
+						map[string]interface{}{"apiVersion": "rds.aws.upbound.io/v1beta1", "kind": "Instance", "name": "db"},
+						map[string]interface{}{"apiVersion": "v1", "kind": "Secret", "name": "db-conn"},
+					},
+				},
+			},
+			expected: []Relation{
+				{Parent: "example.org_XDatabase", Child: "rds.aws.upbound.io_Instance"},
+				{Parent: "example.org_XDatabase", Child: "core_Secret"},
+			},
+		},
+		{
+			name:     "Crossplane claim",
+			inferrer: crossplaneInferrer{},
+			resource: ResourceType{GVR: schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "databases"}, Kind: "Database", Categories: []string{"crossplane", "claim"}},
+			object: map[string]interface{}{
+				"apiVersion": "example.org/v1",
+				"kind":       "Database",
+				"spec": map[string]interface{}{
+					"resourceRef": map[string]interface{}{"apiVersion": "example.org/v1", "kind": "XDatabase", "name": "db-x7k2"},
+				},
+			},
+			expected: []Relation{{Parent: "example.org_Database", Child: "example.org_XDatabase"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.True(t, tt.inferrer.Matches(tt.resource))
+			var relations []Relation
+			for _, relation := range tt.inferrer.Infer(&unstructured.Unstructured{Object: tt.object}) {
+				relations = append(relations, relation.Relation())
+			}
+			assert.ElementsMatch(t, tt.expected, relations)
+		})
+	}
+}
+
+func Test_RelationInferrers_examples(t *testing.T) {
+	statefulSet := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "StatefulSet",
+		"metadata":   map[string]interface{}{"name": "redis", "namespace": "guestbook"},
+		"spec": map[string]interface{}{
+			"volumeClaimTemplates": []interface{}{
+				map[string]interface{}{"metadata": map[string]interface{}{"name": "data"}},
+			},
+		},
+	}}
+	assert.Equal(t, []ObjectRelation{{
+		Parent: common.ResourceInfo{Group: "apps", Kind: "StatefulSet", Namespace: "guestbook", Name: "redis"},
+		Child:  common.ResourceInfo{Kind: "PersistentVolumeClaim", Namespace: "guestbook", Name: "data-redis-0"},
+	}}, statefulSetClaimsInferrer{}.Infer(statefulSet))
+
+	token := &unstructured.Unstructured{}
+	token.SetAPIVersion("v1")
+	token.SetKind("Secret")
+	token.SetNamespace("guestbook")
+	token.SetName("builder-token")
+	token.SetAnnotations(map[string]string{serviceAccountNameAnnotation: "builder"})
+	assert.Equal(t, []ObjectRelation{{
+		Parent: common.ResourceInfo{Kind: "ServiceAccount", Namespace: "guestbook", Name: "builder"},
+		Child:  common.ResourceInfo{Kind: "Secret", Namespace: "guestbook", Name: "builder-token"},
+	}}, serviceAccountTokenInferrer{}.Infer(token))
+}
+
+func Test_inferrersFor(t *testing.T) {
+	deployments := ResourceType{GVR: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, Kind: "Deployment"}
+	assert.Empty(t, inferrersFor(deployments))
+
+	secrets := ResourceType{GVR: schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, Kind: "Secret"}
+	inferrers := inferrersFor(secrets)
+	assert.Equal(t, []RelationInferrer{serviceAccountTokenInferrer{}}, inferrers)
+	assert.False(t, needObjects(inferrers), "Secrets must only be listed by their metadata")
+}
+
+// configMapInferrer links every ConfigMap to Deployments.
+type configMapInferrer struct{}
+
+func (configMapInferrer) Name() string { return "test-configmap" }
+
+func (configMapInferrer) Matches(resource ResourceType) bool { return resource.Kind == "ConfigMap" }
+
+func (configMapInferrer) MetadataOnly() bool { return true }
+
+func (configMapInferrer) Infer(obj *unstructured.Unstructured) []ObjectRelation {
+	return []ObjectRelation{{
+		Parent: common.ResourceInfo{Group: "apps", Kind: "Deployment"},
+		Child:  common.ResourceInfo{Kind: "ConfigMap"},
+	}}
+}
+
+func Test_RegisterRelationInferrer(t *testing.T) {
+	registered := relationInferrers
+	t.Cleanup(func() { relationInferrers = registered })
+
+	configMaps := ResourceType{GVR: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, Kind: "ConfigMap"}
+	assert.Empty(t, inferrersFor(configMaps))
+	RegisterRelationInferrer(configMapInferrer{})
+	assert.Equal(t, []RelationInferrer{configMapInferrer{}}, inferrersFor(configMaps))
+}
@@ -0,0 +1,158 @@
+package dynamic
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	// minListPageSize is the number of objects fetched by the first request of a paginated list.
+	minListPageSize = 250
+	// maxListPageSize is the largest number of objects fetched by a single request.
+	maxListPageSize = 5000
+)
+
+// supportsStreamingLists returns true if the cluster version is recent enough to serve
+// streaming lists. Whether the feature is enabled on the server is only known on first use.
+func (r *ResourceMapper) supportsStreamingLists() bool {
+	info, err := r.DiscoveryClient.ServerVersion()
+	if err != nil {
+		log.Debugf("Unable to get server version of %s, streaming lists disabled: %v", r.ClusterHostname, err)
+		return false
+	}
+	serverVersion, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		log.Debugf("Unable to parse server version %q of %s, streaming lists disabled: %v", info.GitVersion, r.ClusterHostname, err)
+		return false
+	}
+	return serverVersion.AtLeast(minStreamingListVersion)
+}
+
+// listMetadata calls fn with the metadata of every object of the given resource in the given
+// namespace, v1.NamespaceAll listing all namespaces. A streaming list is used when the cluster
+// serves them, falling back to a paginated list otherwise.
+func (r *ResourceMapper) listMetadata(ctx context.Context, gvr schema.GroupVersionResource, namespace string, fn func(*v1.PartialObjectMetadata)) error {
+	if r.streamingLists.Load() {
+		err := r.streamMetadata(ctx, gvr, namespace, fn)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if k8sErrors.IsBadRequest(err) || k8sErrors.IsInvalid(err) {
+			log.Infof("Streaming lists are not enabled on %s, using paginated lists: %v", r.ClusterHostname, err)
+			r.streamingLists.Store(false)
+		} else {
+			log.Debugf("Streaming list of %s failed, retrying with a paginated list: %v", gvr, err)
+		}
+	}
+	return r.pageMetadata(ctx, gvr, namespace, fn)
+}
+
+// streamMetadata lists the metadata of the given resource using a watch which sends the
+// existing objects as initial events, and stops once the server marks their end.
+func (r *ResourceMapper) streamMetadata(ctx context.Context, gvr schema.GroupVersionResource, namespace string, fn func(*v1.PartialObjectMetadata)) error {
+	sendInitialEvents := true
+	watcher, err := r.MetadataClient.Resource(gvr).Namespace(namespace).Watch(ctx, v1.ListOptions{
+		SendInitialEvents:    &sendInitialEvents,
+		ResourceVersionMatch: v1.ResourceVersionMatchNotOlderThan,
+		AllowWatchBookmarks:  true,
+	})
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+	for event := range watcher.ResultChan() {
+		switch event.Type {
+		case watch.Added, watch.Modified:
+			if item, ok := event.Object.(*v1.PartialObjectMetadata); ok {
+				fn(item)
+			}
+		case watch.Bookmark:
+			accessor, err := meta.Accessor(event.Object)
+			if err != nil {
+				return err
+			}
+			if accessor.GetAnnotations()[v1.InitialEventsAnnotationKey] == "true" {
+				return nil
+			}
+		case watch.Error:
+			return k8sErrors.FromObject(event.Object)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("watch on %s closed before all the initial events were received", gvr)
+}
+
+// pageMetadata lists the metadata of the given resource page by page. The first page is small,
+// so that resources with few objects are listed cheaply, and the following pages grow with the
+// number of remaining objects.
+func (r *ResourceMapper) pageMetadata(ctx context.Context, gvr schema.GroupVersionResource, namespace string, fn func(*v1.PartialObjectMetadata)) error {
+	var continueToken string
+	pageSize := int64(minListPageSize)
+	for {
+		list, err := r.MetadataClient.Resource(gvr).Namespace(namespace).List(ctx, v1.ListOptions{
+			Limit:    pageSize,
+			Continue: continueToken,
+		})
+		if err != nil {
+			return err
+		}
+		for i := range list.Items {
+			fn(&list.Items[i])
+		}
+		// Check if there are more results to fetch
+		continueToken = list.GetContinue()
+		if continueToken == "" {
+			return nil
+		}
+		pageSize = nextPageSize(pageSize, list.GetRemainingItemCount())
+	}
+}
+
+// nextPageSize returns the size of the page following a page of the given size. The size doubles
+// up to maxListPageSize, and is trimmed to the number of remaining objects when the server reports it.
+func nextPageSize(pageSize int64, remaining *int64) int64 {
+	next := min(pageSize*2, maxListPageSize)
+	if remaining != nil && *remaining > 0 && *remaining < next {
+		next = *remaining
+	}
+	return next
+}
+
+// listObjects lists the objects of the given resource in the given namespace page by page, for
+// the resources whose relations are inferred from more than the object metadata.
+func (r *ResourceMapper) listObjects(ctx context.Context, gvr schema.GroupVersionResource, namespace string, fn func(*unstructured.Unstructured)) error {
+	var continueToken string
+	pageSize := int64(minListPageSize)
+	for {
+		list, err := r.DynamicClient.Resource(gvr).Namespace(namespace).List(ctx, v1.ListOptions{
+			Limit:    pageSize,
+			Continue: continueToken,
+		})
+		if err != nil {
+			return err
+		}
+		for i := range list.Items {
+			fn(&list.Items[i])
+		}
+		// Check if there are more results to fetch
+		continueToken = list.GetContinue()
+		if continueToken == "" {
+			return nil
+		}
+		pageSize = nextPageSize(pageSize, list.GetRemainingItemCount())
+	}
+}
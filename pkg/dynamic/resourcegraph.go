@@ -3,36 +3,103 @@ package dynamic
 import (
 	"context"
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/anandf/resource-tracker/pkg/kube"
 	"github.com/emirpasic/gods/sets/hashset"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	apiextensionsinformer "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/flowcontrol"
 )
 
 //TODO: Rename this pkg
 
 type ResourceMapper struct {
-	DiscoveryClient        discovery.DiscoveryInterface
-	InformerFactory        apiextensionsinformer.SharedInformerFactory
-	ResourceList           hashset.Set
-	CRDInformer            cache.SharedInformer
+	DiscoveryClient discovery.DiscoveryInterface
+	InformerFactory apiextensionsinformer.SharedInformerFactory
+	ResourceList    hashset.Set
+	// ResourceKinds maps each GVR in ResourceList to its kind, as metadata only lists
+	// do not carry the kind of the listed objects.
+	ResourceKinds  map[schema.GroupVersionResource]string
+	CRDInformer    cache.SharedInformer
+	MetadataClient metadata.Interface
+	// DynamicClient lists the objects of the resources whose relations are inferred from their content
 	DynamicClient          dynamic.Interface
 	ClusterScopedResources hashset.Set
 	ClusterHostname        string
+	// resourceCategories holds the categories of the resources in ResourceList
+	resourceCategories map[schema.GroupVersionResource][]string
+	// resourcesMu guards ResourceList, ResourceKinds and resourceCategories, which are updated by the CRD informer
+	resourcesMu sync.RWMutex
+	// streamingLists is true while the cluster is expected to serve streaming lists
+	streamingLists atomic.Bool
+	// watch is the relation watch of the cluster, nil unless WatchRelations was called
+	watch *relationWatch
+	// scanWorkers is the number of resources listed in parallel by GetClusterResourcesRelation
+	scanWorkers int
+	// onResourceChange, if not nil, is called with the key of a kind whose resources were added
+	// or removed by the CRD informer
+	onResourceChange func(key string)
+	// stopCh is closed by Stop to stop the informers of the mapper
+	stopCh   chan struct{}
+	stopOnce sync.Once
 }
 
+// ScanOptions controls the load that a ResourceMapper puts on its cluster.
+type ScanOptions struct {
+	// QPS and Burst configure the rate limiter shared by all the clients of a cluster.
+	QPS   float32
+	Burst int
+	// Workers is the number of resources listed in parallel during a relation scan.
+	Workers int
+}
+
+// DefaultScanOptions are the ScanOptions used for the fields left empty.
+var DefaultScanOptions = ScanOptions{
+	QPS:     50,
+	Burst:   100,
+	Workers: 4,
+}
+
+// withDefaults returns a copy of the options, with DefaultScanOptions for the fields left empty.
+func (o ScanOptions) withDefaults() ScanOptions {
+	if o.QPS <= 0 {
+		o.QPS = DefaultScanOptions.QPS
+	}
+	if o.Burst <= 0 {
+		o.Burst = DefaultScanOptions.Burst
+	}
+	if o.Workers <= 0 {
+		o.Workers = DefaultScanOptions.Workers
+	}
+	return o
+}
+
+// minStreamingListVersion is the first Kubernetes version which understands the
+// sendInitialEvents parameter used for streaming lists.
+var minStreamingListVersion = version.MustParseGeneric("v1.27.0")
+
 // excludedGroupsKinds mirrors Argo CD's default resource.exclusions for
 // internal / noisy resources. We skip these when building the relation cache
 // to reduce API traffic and cache size.
@@ -93,9 +160,14 @@ func isExcludedKind(group, kind string) bool {
 	return false
 }
 
-func NewResourceMapper(destinationConfig *rest.Config) (*ResourceMapper, error) {
-	destinationConfig.QPS = 50
-	destinationConfig.Burst = 100
+// NewResourceMapper creates a ResourceMapper for the cluster of destinationConfig. All its
+// clients share a single rate limiter configured from opts.
+func NewResourceMapper(destinationConfig *rest.Config, opts ScanOptions) (*ResourceMapper, error) {
+	opts = opts.withDefaults()
+	destinationConfig = rest.CopyConfig(destinationConfig)
+	destinationConfig.QPS = opts.QPS
+	destinationConfig.Burst = opts.Burst
+	destinationConfig.RateLimiter = flowcontrol.NewTokenBucketRateLimiter(opts.QPS, opts.Burst)
 	apiextensionsClient, err := apiextensionsclient.NewForConfig(destinationConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create apiextensions client: %w", err)
@@ -107,11 +179,18 @@ func NewResourceMapper(destinationConfig *rest.Config) (*ResourceMapper, error)
 	// Get CRD informer
 	crdInformer := informerFactory.Apiextensions().V1().CustomResourceDefinitions().Informer()
 
+	// The resources are discovered with kube.PreferredResources, in two requests from the
+	// servers supporting aggregated discovery
 	discoveryClient, err := discovery.NewDiscoveryClientForConfig(destinationConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create discovery client: %w", err)
 	}
-	// Create dynamic client
+	// Create metadata client, the relation scan only needs the object metadata
+	metadataClient, err := metadata.NewForConfig(destinationConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metadata client: %w", err)
+	}
+	// Create dynamic client, for the few resources whose relations are inferred from their content
 	dynamicClient, err := dynamic.NewForConfig(destinationConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
@@ -119,12 +198,17 @@ func NewResourceMapper(destinationConfig *rest.Config) (*ResourceMapper, error)
 
 	rm := &ResourceMapper{
 		DiscoveryClient:        discoveryClient,
+		MetadataClient:         metadataClient,
 		DynamicClient:          dynamicClient,
 		InformerFactory:        informerFactory,
 		CRDInformer:            crdInformer,
 		ResourceList:           *hashset.New(),
+		ResourceKinds:          make(map[schema.GroupVersionResource]string),
+		resourceCategories:     make(map[schema.GroupVersionResource][]string),
 		ClusterScopedResources: *hashset.New(),
 		ClusterHostname:        destinationConfig.Host,
+		scanWorkers:            opts.Workers,
+		stopCh:                 make(chan struct{}),
 	}
 
 	if err := rm.Init(); err != nil {
@@ -148,52 +232,184 @@ func NewResourceMapper(destinationConfig *rest.Config) (*ResourceMapper, error)
 			}
 			rm.addToResourceList(newObj)
 		},
+		DeleteFunc: rm.removeFromResourceList,
 	})
 	return rm, nil
 }
 
+// OnResourceChange registers fn to be called with the key of a kind whose resources are added
+// or removed by the CRD informer. It must be registered before StartInformer is called.
+func (r *ResourceMapper) OnResourceChange(fn func(key string)) {
+	r.onResourceChange = fn
+}
+
 func (r *ResourceMapper) addToResourceList(obj interface{}) {
 	crd, ok := obj.(*apiextensionsv1.CustomResourceDefinition)
 	if !ok {
 		log.Errorf("Failed to convert object to CRD")
 		return
 	}
+	key := ResourceInfoKey(crd.Spec.Group, crd.Spec.Names.Kind)
+	served := make(map[string]bool)
+	for _, version := range crd.Spec.Versions {
+		if version.Served {
+			served[version.Name] = true
+		}
+	}
+	// Versions which are no longer served are not listed any more
+	changed := r.removeResources(func(gvr schema.GroupVersionResource) bool {
+		return gvr.Group == crd.Spec.Group && gvr.Resource == crd.Spec.Names.Plural && !served[gvr.Version]
+	})
+	if len(served) == 0 {
+		r.removeClusterScopedResource(key)
+	}
 
 	for _, version := range crd.Spec.Versions {
 		if !version.Served {
 			continue // Skip versions that are not served
 		}
 
-		// Check if the CRD is namespaced
-		if !(crd.Spec.Scope == apiextensionsv1.NamespaceScoped) {
-			gv := fmt.Sprintf("%s/%s", crd.Spec.Group, version.Name)
-			key := GetResourceKey(gv, crd.Spec.Names.Kind)
-			//log.Infof("Adding cluster scoped resource: %s", key)
-			r.ClusterScopedResources.Add(key)
-			continue
-		}
-
 		gvr := schema.GroupVersionResource{
 			Group:    crd.Spec.Group,
 			Version:  version.Name,
 			Resource: crd.Spec.Names.Plural, // CRD resources are named using the `plural` field
 		}
+		// Check if the CRD is namespaced, the cluster-scoped kinds are only scanned if their
+		// relations are inferred
+		if !(crd.Spec.Scope == apiextensionsv1.NamespaceScoped) {
+			r.addClusterScopedResource(key)
+			if len(inferrersFor(ResourceType{GVR: gvr, Kind: crd.Spec.Names.Kind, Categories: crd.Spec.Names.Categories})) == 0 {
+				continue
+			}
+		}
+		if r.hasResource(gvr) {
+			continue
+		}
+		log.Debugf("Adding CRD version %s/%s (%s) of cluster %s", gvr.Group, gvr.Version, gvr.Resource, r.ClusterHostname)
+		// Add the served version of CRD to ResourceList
+		r.addResource(gvr, crd.Spec.Names.Kind, crd.Spec.Names.Categories)
+		changed = true
+	}
+	if changed && r.onResourceChange != nil {
+		r.onResourceChange(key)
+	}
+}
 
-		// Log whether we're updating or adding a new CRD version
-		if r.ResourceList.Contains(gvr) {
-			//	log.Debugf("Updating existing CRD version: %s/%s (%s)", gvr.Group, gvr.Version, gvr.Resource)
-		} else {
-			//log.Infof("Adding new CRD version: %s/%s (%s)", gvr.Group, gvr.Version, gvr.Resource)
+// removeFromResourceList removes every version of a deleted CRD from the resources to scan.
+func (r *ResourceMapper) removeFromResourceList(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	crd, ok := obj.(*apiextensionsv1.CustomResourceDefinition)
+	if !ok {
+		log.Errorf("Failed to convert object to CRD")
+		return
+	}
+	log.Infof("CRD %s was deleted from cluster %s", crd.Name, r.ClusterHostname)
+	key := ResourceInfoKey(crd.Spec.Group, crd.Spec.Names.Kind)
+	changed := r.removeResources(func(gvr schema.GroupVersionResource) bool {
+		return gvr.Group == crd.Spec.Group && gvr.Resource == crd.Spec.Names.Plural
+	})
+	r.removeClusterScopedResource(key)
+	if changed && r.onResourceChange != nil {
+		r.onResourceChange(key)
+	}
+}
+
+// addResource adds the given GVR, its kind and categories to the list of resources to scan.
+func (r *ResourceMapper) addResource(gvr schema.GroupVersionResource, kind string, categories []string) {
+	r.resourcesMu.Lock()
+	defer r.resourcesMu.Unlock()
+	r.ResourceList.Add(gvr)
+	r.ResourceKinds[gvr] = kind
+	if r.resourceCategories == nil {
+		r.resourceCategories = make(map[schema.GroupVersionResource][]string)
+	}
+	r.resourceCategories[gvr] = categories
+	// Watch the new resource too if the relations of the cluster are watched
+	if r.watch != nil {
+		if err := r.watchResourceLocked(ResourceType{GVR: gvr, Kind: kind, Categories: categories}); err != nil {
+			log.Errorf("Failed to watch relations of %s: %v", gvr, err)
+			return
 		}
+		r.watch.start()
+	}
+}
 
-		r.ResourceList.Add(gvr)
-		// Add the served version of CRD to ResourceList
+// hasResource returns true if the given GVR is in the list of resources to scan.
+func (r *ResourceMapper) hasResource(gvr schema.GroupVersionResource) bool {
+	r.resourcesMu.RLock()
+	defer r.resourcesMu.RUnlock()
+	return r.ResourceList.Contains(gvr)
+}
+
+// removeResources removes the resources matching the given function from the list of resources
+// to scan, stopping their watch. It returns true if any resource was removed.
+func (r *ResourceMapper) removeResources(matches func(schema.GroupVersionResource) bool) bool {
+	r.resourcesMu.Lock()
+	defer r.resourcesMu.Unlock()
+	removed := false
+	for _, resource := range r.resourcesLocked() {
+		if !matches(resource.GVR) {
+			continue
+		}
+		log.Debugf("Removing resource %s of cluster %s", resource.GVR, r.ClusterHostname)
+		r.ResourceList.Remove(resource.GVR)
+		delete(r.ResourceKinds, resource.GVR)
+		delete(r.resourceCategories, resource.GVR)
+		if r.watch != nil {
+			r.unwatchResourceLocked(resource.GVR)
+		}
+		removed = true
 	}
+	return removed
+}
+
+// addClusterScopedResource records the key of a cluster scoped kind.
+func (r *ResourceMapper) addClusterScopedResource(key string) {
+	r.resourcesMu.Lock()
+	defer r.resourcesMu.Unlock()
+	r.ClusterScopedResources.Add(key)
+}
+
+// removeClusterScopedResource forgets the key of a cluster scoped kind which was removed.
+func (r *ResourceMapper) removeClusterScopedResource(key string) {
+	r.resourcesMu.Lock()
+	defer r.resourcesMu.Unlock()
+	r.ClusterScopedResources.Remove(key)
+}
+
+// IsClusterScoped returns true if the kind of the given resource key is cluster scoped.
+func (r *ResourceMapper) IsClusterScoped(key string) bool {
+	r.resourcesMu.RLock()
+	defer r.resourcesMu.RUnlock()
+	return r.ClusterScopedResources.Contains(key)
+}
+
+// resources returns a snapshot of the resources to scan.
+func (r *ResourceMapper) resources() []ResourceType {
+	r.resourcesMu.RLock()
+	defer r.resourcesMu.RUnlock()
+	return r.resourcesLocked()
+}
+
+// resourcesLocked returns the resources to scan. Callers must hold resourcesMu.
+func (r *ResourceMapper) resourcesLocked() []ResourceType {
+	out := make([]ResourceType, 0, r.ResourceList.Size())
+	for _, value := range r.ResourceList.Values() {
+		gvr, ok := value.(schema.GroupVersionResource)
+		if !ok {
+			log.Errorf("Failed to assert type for gvr: %v", value)
+			continue
+		}
+		out = append(out, ResourceType{GVR: gvr, Kind: r.ResourceKinds[gvr], Categories: r.resourceCategories[gvr]})
+	}
+	return out
 }
 
 func (r *ResourceMapper) Init() error {
 	// Get API resource list
-	resourceList, err := r.DiscoveryClient.ServerPreferredResources()
+	resourceList, err := kube.PreferredResources(r.DiscoveryClient)
 	if err != nil {
 		if len(resourceList) == 0 {
 			return err
@@ -206,6 +422,11 @@ func (r *ResourceMapper) Init() error {
 			if err != nil {
 				continue
 			}
+			gvr := schema.GroupVersionResource{
+				Group:    gv.Group,
+				Version:  gv.Version,
+				Resource: resource.Name,
+			}
 			// Record cluster-scoped kinds for quick detection
 			if !resource.Namespaced {
 				groupVersion := gv.Version
@@ -213,26 +434,30 @@ func (r *ResourceMapper) Init() error {
 					groupVersion = fmt.Sprintf("%s/%s", gv.Group, gv.Version)
 				}
 				key := GetResourceKey(groupVersion, resource.Kind)
-				r.ClusterScopedResources.Add(key)
-				// Skip adding to ResourceList, since we only scan namespaced kinds
-				continue
+				r.addClusterScopedResource(key)
+				// Only the namespaced kinds are scanned, along with the cluster-scoped kinds
+				// whose relations are inferred
+				if len(inferrersFor(ResourceType{GVR: gvr, Kind: resource.Kind, Categories: resource.Categories})) == 0 {
+					continue
+				}
 			}
 			// Skip noisy / internal resources based on Argo CD's resource.exclusions
 			if isExcludedKind(gv.Group, resource.Kind) {
 				continue
 			}
-
-			gvr := schema.GroupVersionResource{
-				Group:    gv.Group,
-				Version:  gv.Version,
-				Resource: resource.Name,
-			}
-			r.ResourceList.Add(gvr)
+			r.addResource(gvr, resource.Kind, resource.Categories)
 		}
 	}
+	r.streamingLists.Store(r.supportsStreamingLists())
 	return nil
 }
 
+// ServedKinds returns the kinds served by the cluster of the mapper which can be listed and
+// watched, by group, including the cluster-scoped and excluded kinds which are not scanned.
+func (r *ResourceMapper) ServedKinds() (common.GroupedResourceKinds, error) {
+	return kube.ServedKinds(r.DiscoveryClient)
+}
+
 // GetResourceKey returns the key for a given resource.
 func GetResourceKey(groupVersion, kind string) string {
 	group := ""
@@ -244,85 +469,162 @@ func GetResourceKey(groupVersion, kind string) string {
 	}
 	return fmt.Sprintf("%s_%s", group, kind)
 }
+
+// StartInformer starts the CRD informer, which runs until Stop is called.
 func (r *ResourceMapper) StartInformer() {
 	log.Info("Starting informer for cluster ", r.ClusterHostname)
-	r.InformerFactory.Start(context.Background().Done())
+	r.InformerFactory.Start(r.stopCh)
 }
 
-// GetResourcesRelation retrieves a mapping of parent resource kinds to their child resource kinds.
-func (r *ResourceMapper) GetClusterResourcesRelation(ctx context.Context) (map[string]*hashset.Set, error) {
+// Stop stops the CRD informer and the relation watch of the mapper. It is safe to call more than once.
+func (r *ResourceMapper) Stop() {
+	r.stopOnce.Do(func() {
+		log.Info("Stopping informers for cluster ", r.ClusterHostname)
+		if r.stopCh != nil {
+			close(r.stopCh)
+		}
+		r.resourcesMu.Lock()
+		if r.watch != nil {
+			r.watch.stop()
+		}
+		r.resourcesMu.Unlock()
+		if r.InformerFactory != nil {
+			r.InformerFactory.Shutdown()
+		}
+	})
+}
+
+// GetResourcesRelation retrieves a mapping of parent resource kinds to their child resource kinds,
+// along with the provenance of each relation. Only the metadata of the objects is fetched, as the
+// relations are derived from ownerReferences, except for the resources matched by an inferrer
+// which needs their content.
+// Objects are listed in the given namespaces, or across all namespaces if namespaces is empty or
+// contains v1.NamespaceAll. The objects of the cluster-scoped resources are always all listed. Resources are listed in parallel by a bounded number of workers.
+func (r *ResourceMapper) GetClusterResourcesRelation(ctx context.Context, namespaces []string) (map[string]*hashset.Set, map[Relation]common.Provenance, error) {
+	if len(namespaces) == 0 || slices.Contains(namespaces, v1.NamespaceAll) {
+		namespaces = []string{v1.NamespaceAll}
+	}
+	var mu sync.Mutex
 	resourceRelation := make(map[string]*hashset.Set)
+	provenance := make(map[Relation]common.Provenance)
+	addRelations := func(relations map[Relation]common.Provenance) {
+		mu.Lock()
+		defer mu.Unlock()
+		for relation, example := range relations {
+			if _, exists := provenance[relation]; !exists {
+				provenance[relation] = example
+			}
+			// Initialize resourceRelation[parent] if not present
+			if _, exists := resourceRelation[relation.Parent]; !exists {
+				resourceRelation[relation.Parent] = hashset.New()
+			}
+			resourceRelation[relation.Parent].Add(relation.Child)
+		}
+	}
+	workers := r.scanWorkers
+	if workers <= 0 {
+		workers = DefaultScanOptions.Workers
+	}
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
 	// Iterate over all API resources
-	for _, value := range r.ResourceList.Values() {
-		gvr, ok := value.(schema.GroupVersionResource)
-		if !ok {
-			log.Errorf("Failed to assert type for gvr: %v", value)
+	for _, resource := range r.resources() {
+		gvr := resource.GVR
+		// Skip excluded resources entirely
+		if isExcludedKind(gvr.Group, resource.Kind) {
 			continue
 		}
-		var continueToken string
-		for {
-			resourceList, err := r.DynamicClient.Resource(gvr).Namespace(v1.NamespaceAll).List(ctx, v1.ListOptions{
-				Limit:    250,
-				Continue: continueToken,
-			})
-			if err != nil {
-				if !k8sErrors.IsNotFound(err) {
-					log.Errorf("Failed to list resource %s: %v", gvr, err)
-				}
-				break
-			}
-			// Process each resource
-			for _, item := range resourceList.Items {
-				// Skip excluded resources entirely
-				gvk := item.GroupVersionKind()
-				if isExcludedKind(gvk.Group, gvk.Kind) {
-					continue
-				}
-				for _, ownerRef := range item.GetOwnerReferences() {
-					parent := GetResourceKey(ownerRef.APIVersion, ownerRef.Kind)
-					child := GetResourceKey(item.GetAPIVersion(), item.GetKind())
-					// Initialize resourceRelation[parent] if not present
-					if _, exists := resourceRelation[parent]; !exists {
-						resourceRelation[parent] = hashset.New()
+		inferrers := inferrersFor(resource)
+		resourceNamespaces := namespaces
+		if r.IsClusterScoped(resource.Key()) {
+			resourceNamespaces = []string{v1.NamespaceAll}
+		}
+		for _, namespace := range resourceNamespaces {
+			g.Go(func() error {
+				// relations of this resource, merged into the relations once the namespace is listed
+				relations := make(map[Relation]common.Provenance)
+				collect := func(obj interface{}) {
+					for relation, example := range objectRelations(resource, inferrers, obj) {
+						if _, exists := relations[relation]; !exists {
+							relations[relation] = example
+						}
 					}
-					resourceRelation[parent].Add(child)
 				}
-				// Special case
-				if item.GetKind() == "ClusterServiceVersion" {
-					parent := GetResourceKey("operators.coreos.com/v1", "OperatorGroup")
-					child := GetResourceKey(item.GetAPIVersion(), item.GetKind())
-					if _, exists := resourceRelation[parent]; !exists {
-						resourceRelation[parent] = hashset.New()
-					}
-					resourceRelation[parent].Add(child)
+				var err error
+				if needObjects(inferrers) {
+					err = r.listObjects(ctx, gvr, namespace, func(item *unstructured.Unstructured) { collect(item) })
+				} else {
+					err = r.listMetadata(ctx, gvr, namespace, func(item *v1.PartialObjectMetadata) { collect(item) })
 				}
-				if item.GetKind() == "Secret" {
-					parent := GetResourceKey("v1", "ServiceAccount")
-					child := GetResourceKey(item.GetAPIVersion(), item.GetKind())
-					if _, exists := resourceRelation[parent]; !exists {
-						resourceRelation[parent] = hashset.New()
+				if err != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
 					}
-					resourceRelation[parent].Add(child)
-				}
-				if item.GetKind() == "volumeClaimTemplates" {
-					// add sts as parent
-					parent := GetResourceKey("apps/v1", "StatefulSet")
-					child := GetResourceKey(item.GetAPIVersion(), item.GetKind())
-					if _, exists := resourceRelation[parent]; !exists {
-						resourceRelation[parent] = hashset.New()
+					if !k8sErrors.IsNotFound(err) {
+						log.Errorf("Failed to list resource %s in namespace %q: %v", gvr, namespace, err)
 					}
-					resourceRelation[parent].Add(child)
+					return nil
 				}
+				addRelations(relations)
+				return nil
+			})
+		}
+	}
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+	return resourceRelation, provenance, nil
+}
+
+// objectRelations returns the relations of the given object of the given resource, each with the
+// provenance of one of the objects linking it: one relation to the kind of each of its owners,
+// and the relations found by the given inferrers. The cluster of the provenance is left empty.
+// The object is either a *v1.PartialObjectMetadata or an *unstructured.Unstructured.
+func objectRelations(resource ResourceType, inferrers []RelationInferrer, obj interface{}) map[Relation]common.Provenance {
+	var content *unstructured.Unstructured
+	switch item := obj.(type) {
+	case *unstructured.Unstructured:
+		// Shallow copy, the type set below must not change objects shared with informer caches
+		content = &unstructured.Unstructured{Object: maps.Clone(item.Object)}
+	case *v1.PartialObjectMetadata:
+		if len(inferrers) > 0 {
+			converted, err := runtime.DefaultUnstructuredConverter.ToUnstructured(item)
+			if err != nil {
+				log.Errorf("Failed to convert metadata of %s %s/%s: %v", resource.Kind, item.Namespace, item.Name, err)
+				return nil
 			}
-			// Check if there are more results to fetch
-			continueToken = resourceList.GetContinue()
-			if continueToken == "" {
-				break
+			content = &unstructured.Unstructured{Object: converted}
+		}
+	default:
+		return nil
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil
+	}
+	child := objectInfo(resource.GVR.GroupVersion().String(), resource.Kind, accessor.GetNamespace(), accessor.GetName())
+	relations := make(map[Relation]common.Provenance)
+	add := func(relation ObjectRelation, source common.RelationSource, rule string) {
+		if _, exists := relations[relation.Relation()]; !exists {
+			relations[relation.Relation()] = common.Provenance{Source: source, Rule: rule, Parent: relation.Parent, Child: relation.Child}
+		}
+	}
+	for _, ownerRef := range accessor.GetOwnerReferences() {
+		// Owners are in the namespace of the object, or cluster scoped
+		parent := objectInfo(ownerRef.APIVersion, ownerRef.Kind, accessor.GetNamespace(), ownerRef.Name)
+		add(ObjectRelation{Parent: parent, Child: child}, common.SourceOwnerReference, "")
+	}
+	if len(inferrers) > 0 {
+		// The type of listed objects is not always set, inferrers rely on it to name their relations
+		content.SetAPIVersion(resource.GVR.GroupVersion().String())
+		content.SetKind(resource.Kind)
+		for _, inferrer := range inferrers {
+			for _, relation := range inferrer.Infer(content) {
+				add(relation, common.SourceRule, inferrer.Name())
 			}
 		}
 	}
-
-	return resourceRelation, nil
+	return relations
 }
 
 // GetResourceRelation builds a parent->children map of resource keys reachable
@@ -343,24 +645,84 @@ func GetResourceRelation(
 	// Convert visitedKeys -> []common.ResourceInfo
 	out := make([]*common.ResourceInfo, 0, len(visitedKeys))
 	for key := range visitedKeys {
-		parts := strings.SplitN(key, "_", 2)
-		if len(parts) != 2 {
+		info, ok := resourceKeyInfo(key)
+		if !ok {
 			continue
 		}
-		groupSeg, kind := parts[0], parts[1]
-		group := groupSeg
-		if groupSeg == "core" {
-			group = ""
-		}
-		out = append(out, &common.ResourceInfo{
-			Group: group,
-			Kind:  kind,
-			// Name/Namespace can stay empty; grouping ignores them
-		})
+		// Name/Namespace can stay empty; grouping ignores them
+		out = append(out, &info)
 	}
 	return out
 }
 
+// resourceKeyInfo returns the ResourceInfo of the group and kind of the given resource key.
+func resourceKeyInfo(key string) (common.ResourceInfo, bool) {
+	parts := strings.SplitN(key, "_", 2)
+	if len(parts) != 2 {
+		return common.ResourceInfo{}, false
+	}
+	groupSeg, kind := parts[0], parts[1]
+	group := groupSeg
+	if groupSeg == "core" {
+		group = ""
+	}
+	return common.ResourceInfo{Group: group, Kind: kind}, true
+}
+
+// FindRelationChain returns the shortest chain of relations leading from the root kind down to
+// the target kind, with the provenance of each relation, or nil if the target cannot be reached.
+// The chain is empty if the root is the target.
+func FindRelationChain(
+	resourceRelation map[string]*hashset.Set,
+	provenance map[Relation]common.Provenance,
+	root, target string,
+) []common.Provenance {
+	// Breadth first search, recording the kind each kind was reached from
+	reachedFrom := map[string]string{root: ""}
+	queue := []string{root}
+	for len(queue) > 0 && root != target {
+		key := queue[0]
+		queue = queue[1:]
+		childrenSet, ok := resourceRelation[key]
+		if !ok {
+			continue
+		}
+		children := make([]string, 0, childrenSet.Size())
+		for _, v := range childrenSet.Values() {
+			if childKey, ok := v.(string); ok {
+				children = append(children, childKey)
+			}
+		}
+		// Sorted for the chain to be stable across runs
+		slices.Sort(children)
+		for _, childKey := range children {
+			if _, seen := reachedFrom[childKey]; seen {
+				continue
+			}
+			reachedFrom[childKey] = key
+			queue = append(queue, childKey)
+		}
+		if _, found := reachedFrom[target]; found {
+			break
+		}
+	}
+	if _, found := reachedFrom[target]; !found {
+		return nil
+	}
+	chain := make([]common.Provenance, 0)
+	for key := target; key != root; key = reachedFrom[key] {
+		relation := Relation{Parent: reachedFrom[key], Child: key}
+		link, ok := provenance[relation]
+		if !ok {
+			link.Parent, _ = resourceKeyInfo(relation.Parent)
+			link.Child, _ = resourceKeyInfo(relation.Child)
+		}
+		chain = append(chain, link)
+	}
+	slices.Reverse(chain)
+	return chain
+}
+
 func dfs(rel map[string]*hashset.Set, key string, visited map[string]struct{}) {
 	if _, ok := visited[key]; ok {
 		return
@@ -0,0 +1,355 @@
+package dynamic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/emirpasic/gods/sets/hashset"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	deploymentsGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	replicaSetsGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}
+	podsGVR        = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	secretsGVR     = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+)
+
+func newPartialObject(apiVersion, kind, name string, owners ...v1.OwnerReference) *v1.PartialObjectMetadata {
+	return &v1.PartialObjectMetadata{
+		TypeMeta: v1.TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:       "guestbook",
+			Name:            name,
+			OwnerReferences: owners,
+		},
+	}
+}
+
+// newTestResourceMapper returns a ResourceMapper serving the given resources. Metadata objects
+// are served by its metadata client, and unstructured objects by its dynamic client.
+func newTestResourceMapper(t *testing.T, resources map[schema.GroupVersionResource]string, objects ...runtime.Object) *ResourceMapper {
+	t.Helper()
+	scheme := metadatafake.NewTestScheme()
+	require.NoError(t, v1.AddMetaToScheme(scheme))
+	var metadataObjects, unstructuredObjects []runtime.Object
+	for _, obj := range objects {
+		if _, ok := obj.(*unstructured.Unstructured); ok {
+			unstructuredObjects = append(unstructuredObjects, obj)
+		} else {
+			metadataObjects = append(metadataObjects, obj)
+		}
+	}
+	listKinds := make(map[schema.GroupVersionResource]string)
+	for gvr, kind := range resources {
+		listKinds[gvr] = kind + "List"
+	}
+	rm := &ResourceMapper{
+		MetadataClient:         metadatafake.NewSimpleMetadataClient(scheme, metadataObjects...),
+		DynamicClient:          dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, unstructuredObjects...),
+		ResourceList:           *hashset.New(),
+		ResourceKinds:          make(map[schema.GroupVersionResource]string),
+		ClusterScopedResources: *hashset.New(),
+	}
+	for gvr, kind := range resources {
+		rm.addResource(gvr, kind, nil)
+	}
+	return rm
+}
+
+func Test_GetClusterResourcesRelation(t *testing.T) {
+	t.Run("relations are built from the metadata of the objects", func(t *testing.T) {
+		rm := newTestResourceMapper(t,
+			map[schema.GroupVersionResource]string{
+				deploymentsGVR: "Deployment",
+				replicaSetsGVR: "ReplicaSet",
+				podsGVR:        "Pod",
+			},
+			newPartialObject("apps/v1", "Deployment", "guestbook-ui"),
+			newPartialObject("apps/v1", "ReplicaSet", "guestbook-ui-5d8f",
+				v1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "guestbook-ui"}),
+			newPartialObject("v1", "Pod", "guestbook-ui-5d8f-x2k4",
+				v1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "guestbook-ui-5d8f"}),
+		)
+
+		relations, provenance, err := rm.GetClusterResourcesRelation(context.Background(), nil)
+		require.NoError(t, err)
+		require.Len(t, relations, 2)
+		assert.ElementsMatch(t, []any{"apps_ReplicaSet"}, relations["apps_Deployment"].Values())
+		assert.ElementsMatch(t, []any{"core_Pod"}, relations["apps_ReplicaSet"].Values())
+		assert.Equal(t, common.Provenance{
+			Source: common.SourceOwnerReference,
+			Parent: common.ResourceInfo{Group: "apps", Kind: "ReplicaSet", Namespace: "guestbook", Name: "guestbook-ui-5d8f"},
+			Child:  common.ResourceInfo{Kind: "Pod", Namespace: "guestbook", Name: "guestbook-ui-5d8f-x2k4"},
+		}, provenance[Relation{Parent: "apps_ReplicaSet", Child: "core_Pod"}])
+	})
+
+	t.Run("inferred relations are only added when objects exist", func(t *testing.T) {
+		rm := newTestResourceMapper(t, map[schema.GroupVersionResource]string{secretsGVR: "Secret"})
+
+		relations, _, err := rm.GetClusterResourcesRelation(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Empty(t, relations)
+	})
+
+	t.Run("relations are inferred from the metadata of the objects", func(t *testing.T) {
+		token := newPartialObject("v1", "Secret", "builder-token")
+		token.Annotations = map[string]string{serviceAccountNameAnnotation: "builder"}
+		rm := newTestResourceMapper(t,
+			map[schema.GroupVersionResource]string{secretsGVR: "Secret"},
+			token,
+			newPartialObject("v1", "Secret", "guestbook-config"),
+		)
+
+		relations, provenance, err := rm.GetClusterResourcesRelation(context.Background(), nil)
+		require.NoError(t, err)
+		require.Len(t, relations, 1)
+		assert.ElementsMatch(t, []any{"core_Secret"}, relations["core_ServiceAccount"].Values())
+		link := provenance[Relation{Parent: "core_ServiceAccount", Child: "core_Secret"}]
+		assert.Equal(t, common.SourceRule, link.Source)
+		assert.Equal(t, "serviceaccount-token", link.Rule)
+	})
+
+	t.Run("relations are inferred from the content of the objects", func(t *testing.T) {
+		statefulSetsGVR := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}
+		statefulSet := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "StatefulSet",
+			"metadata":   map[string]interface{}{"name": "redis", "namespace": "guestbook"},
+			"spec": map[string]interface{}{
+				"volumeClaimTemplates": []interface{}{
+					map[string]interface{}{"metadata": map[string]interface{}{"name": "data"}},
+				},
+			},
+		}}
+		rm := newTestResourceMapper(t,
+			map[schema.GroupVersionResource]string{statefulSetsGVR: "StatefulSet"},
+			statefulSet,
+		)
+
+		relations, _, err := rm.GetClusterResourcesRelation(context.Background(), nil)
+		require.NoError(t, err)
+		require.Len(t, relations, 1)
+		assert.ElementsMatch(t, []any{"core_PersistentVolumeClaim"}, relations["apps_StatefulSet"].Values())
+	})
+
+	t.Run("cluster-scoped resources matched by an inferrer are scanned across the cluster", func(t *testing.T) {
+		compositesGVR := schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "xdatabases"}
+		composite := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.org/v1",
+			"kind":       "XDatabase",
+			"metadata":   map[string]interface{}{"name": "guestbook-db-x7k2"},
+			"spec": map[string]interface{}{
+				"resourceRefs": []interface{}{
+					map[string]interface{}{"apiVersion": "rds.aws.upbound.io/v1beta1", "kind": "Instance", "name": "guestbook-db-x7k2-r4m9"},
+				},
+			},
+		}}
+		rm := newTestResourceMapper(t, map[schema.GroupVersionResource]string{compositesGVR: "XDatabase"}, composite)
+		rm.addResource(compositesGVR, "XDatabase", []string{"crossplane", "composite"})
+		rm.addClusterScopedResource("example.org_XDatabase")
+
+		relations, provenance, err := rm.GetClusterResourcesRelation(context.Background(), []string{"guestbook"})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []any{"rds.aws.upbound.io_Instance"}, relations["example.org_XDatabase"].Values())
+		link := provenance[Relation{Parent: "example.org_XDatabase", Child: "rds.aws.upbound.io_Instance"}]
+		assert.Equal(t, "crossplane-resource-refs", link.Rule)
+		assert.Equal(t, "guestbook-db-x7k2", link.Parent.Name)
+	})
+
+	t.Run("only the given namespaces are scanned", func(t *testing.T) {
+		unmanagedPod := newPartialObject("v1", "Pod", "node-exporter-x2k4",
+			v1.OwnerReference{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "node-exporter"})
+		unmanagedPod.Namespace = "monitoring"
+		rm := newTestResourceMapper(t,
+			map[schema.GroupVersionResource]string{podsGVR: "Pod"},
+			newPartialObject("v1", "Pod", "guestbook-ui-5d8f-x2k4",
+				v1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "guestbook-ui-5d8f"}),
+			unmanagedPod,
+		)
+
+		relations, _, err := rm.GetClusterResourcesRelation(context.Background(), []string{"guestbook"})
+		require.NoError(t, err)
+		assert.Contains(t, relations, "apps_ReplicaSet")
+		assert.NotContains(t, relations, "apps_DaemonSet")
+
+		relations, _, err = rm.GetClusterResourcesRelation(context.Background(), []string{v1.NamespaceAll})
+		require.NoError(t, err)
+		assert.Contains(t, relations, "apps_ReplicaSet")
+		assert.Contains(t, relations, "apps_DaemonSet")
+	})
+}
+
+func Test_FindRelationChain(t *testing.T) {
+	relations := map[string]*hashset.Set{
+		"apps_Deployment": hashset.New("apps_ReplicaSet"),
+		"apps_ReplicaSet": hashset.New("core_Pod"),
+		"core_Pod":        hashset.New(),
+	}
+	provenance := map[Relation]common.Provenance{
+		{Parent: "apps_Deployment", Child: "apps_ReplicaSet"}: {
+			Source: common.SourceOwnerReference,
+			Parent: common.ResourceInfo{Group: "apps", Kind: "Deployment", Name: "guestbook-ui"},
+			Child:  common.ResourceInfo{Group: "apps", Kind: "ReplicaSet", Name: "guestbook-ui-5d8f"},
+		},
+	}
+
+	chain := FindRelationChain(relations, provenance, "apps_Deployment", "core_Pod")
+	require.Len(t, chain, 2)
+	assert.Equal(t, common.SourceOwnerReference, chain[0].Source)
+	assert.Equal(t, "guestbook-ui-5d8f", chain[0].Child.Name)
+	// Relations without provenance are reported with their kinds only
+	assert.Equal(t, common.Provenance{
+		Parent: common.ResourceInfo{Group: "apps", Kind: "ReplicaSet"},
+		Child:  common.ResourceInfo{Kind: "Pod"},
+	}, chain[1])
+
+	assert.Empty(t, FindRelationChain(relations, provenance, "apps_Deployment", "apps_Deployment"))
+	assert.NotNil(t, FindRelationChain(relations, provenance, "apps_Deployment", "apps_Deployment"))
+	assert.Nil(t, FindRelationChain(relations, provenance, "core_Pod", "apps_Deployment"))
+}
+
+func Test_ScanNamespaces(t *testing.T) {
+	rm := newTestResourceMapper(t, nil)
+	rm.addClusterScopedResource("core_Namespace")
+	rm.addClusterScopedResource("rbac.authorization.k8s.io_ClusterRole")
+	rm.addClusterScopedResource("example.com_Tenant")
+
+	t.Run("destination, manifest and created namespaces are scanned", func(t *testing.T) {
+		namespaces := rm.ScanNamespaces("guestbook", []*common.ResourceInfo{
+			{Group: "apps", Kind: "Deployment", Name: "guestbook-ui"},
+			{Kind: "ConfigMap", Name: "guestbook-config", Namespace: "shared"},
+			{Kind: "Namespace", Name: "guestbook-jobs"},
+			{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "guestbook"},
+		})
+		assert.Equal(t, []string{"guestbook", "guestbook-jobs", "shared"}, namespaces)
+	})
+
+	t.Run("cluster scoped parents are scanned across all namespaces", func(t *testing.T) {
+		namespaces := rm.ScanNamespaces("guestbook", []*common.ResourceInfo{
+			{Group: "apps", Kind: "Deployment", Name: "guestbook-ui"},
+			{Group: "example.com", Kind: "Tenant", Name: "guestbook"},
+		})
+		assert.Equal(t, []string{v1.NamespaceAll}, namespaces)
+	})
+
+	t.Run("namespaced objects without a namespace are scanned across all namespaces", func(t *testing.T) {
+		namespaces := rm.ScanNamespaces("", []*common.ResourceInfo{
+			{Group: "apps", Kind: "Deployment", Name: "guestbook-ui"},
+		})
+		assert.Equal(t, []string{v1.NamespaceAll}, namespaces)
+	})
+}
+
+func TestNextPageSize(t *testing.T) {
+	remaining := func(n int64) *int64 { return &n }
+
+	assert.Equal(t, int64(2*minListPageSize), nextPageSize(minListPageSize, nil))
+	assert.Equal(t, int64(maxListPageSize), nextPageSize(maxListPageSize, nil))
+	assert.Equal(t, int64(300), nextPageSize(minListPageSize, remaining(300)))
+	assert.Equal(t, int64(2*minListPageSize), nextPageSize(minListPageSize, remaining(0)))
+}
+
+func newCRD(scope apiextensionsv1.ResourceScope, versions ...apiextensionsv1.CustomResourceDefinitionVersion) *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: v1.ObjectMeta{Name: "rollouts.argoproj.io"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group:    "argoproj.io",
+			Names:    apiextensionsv1.CustomResourceDefinitionNames{Kind: "Rollout", Plural: "rollouts"},
+			Scope:    scope,
+			Versions: versions,
+		},
+	}
+}
+
+func Test_CRDLifecycle(t *testing.T) {
+	v1alpha1GVR := schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}
+	v1beta1GVR := schema.GroupVersionResource{Group: "argoproj.io", Version: "v1beta1", Resource: "rollouts"}
+
+	t.Run("versions follow the served versions of the CRD", func(t *testing.T) {
+		rm := newTestResourceMapper(t, nil)
+		var changes []string
+		rm.OnResourceChange(func(key string) { changes = append(changes, key) })
+
+		rm.addToResourceList(newCRD(apiextensionsv1.NamespaceScoped,
+			apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1alpha1", Served: true},
+			apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1beta1", Served: true},
+		))
+		assert.True(t, rm.hasResource(v1alpha1GVR))
+		assert.True(t, rm.hasResource(v1beta1GVR))
+
+		rm.addToResourceList(newCRD(apiextensionsv1.NamespaceScoped,
+			apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1alpha1", Served: false},
+			apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1beta1", Served: true},
+		))
+		assert.False(t, rm.hasResource(v1alpha1GVR))
+		assert.True(t, rm.hasResource(v1beta1GVR))
+		assert.NotContains(t, rm.ResourceKinds, v1alpha1GVR)
+
+		// An update without change in the served versions is not reported
+		rm.addToResourceList(newCRD(apiextensionsv1.NamespaceScoped,
+			apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1beta1", Served: true},
+		))
+		assert.Equal(t, []string{"argoproj.io_Rollout", "argoproj.io_Rollout"}, changes)
+
+		rm.removeFromResourceList(cache.DeletedFinalStateUnknown{Obj: newCRD(apiextensionsv1.NamespaceScoped)})
+		assert.Empty(t, rm.resources())
+		assert.Len(t, changes, 3)
+	})
+
+	t.Run("deleted cluster scoped kinds are pruned", func(t *testing.T) {
+		rm := newTestResourceMapper(t, nil)
+		crd := newCRD(apiextensionsv1.ClusterScoped, apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1alpha1", Served: true})
+		rm.addToResourceList(crd)
+		assert.True(t, rm.IsClusterScoped("argoproj.io_Rollout"))
+		assert.Empty(t, rm.resources())
+		rm.removeFromResourceList(crd)
+		assert.False(t, rm.IsClusterScoped("argoproj.io_Rollout"))
+	})
+
+	t.Run("cluster scoped kinds matched by an inferrer are scanned", func(t *testing.T) {
+		rm := newTestResourceMapper(t, nil)
+		crd := newCRD(apiextensionsv1.ClusterScoped, apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1alpha1", Served: true})
+		crd.Spec.Names.Categories = []string{"crossplane", "composite"}
+		rm.addToResourceList(crd)
+		assert.True(t, rm.IsClusterScoped("argoproj.io_Rollout"))
+		assert.True(t, rm.hasResource(v1alpha1GVR))
+		rm.removeFromResourceList(crd)
+		assert.Empty(t, rm.resources())
+	})
+
+	t.Run("relations of a changed kind are dropped from the cache", func(t *testing.T) {
+		const server = "https://kubernetes.default.svc"
+		rt := NewDynamicTracker(log.NewEntry(log.New()), DefaultScanOptions)
+		rt.RelationCaches[server] = newClusterRelations()
+		rt.RelationCaches[server].Relations["argoproj.io_Rollout"] = hashset.New("apps_ReplicaSet")
+		rt.RelationCaches[server].Relations["apps_ReplicaSet"] = hashset.New("core_Pod")
+		rt.RelationCaches[server].Relations["argoproj.io_AnalysisRun"] = hashset.New("argoproj.io_Rollout")
+		rt.RelationCaches[server].Provenance[Relation{Parent: "argoproj.io_Rollout", Child: "apps_ReplicaSet"}] = common.Provenance{}
+
+		rt.resourceChangeHandler(server)("argoproj.io_Rollout")
+		assert.Equal(t, []string{"argoproj.io_Rollout"}, rt.MissingKeys(server, []string{"argoproj.io_Rollout", "apps_ReplicaSet"}))
+		assert.False(t, rt.RelationCaches[server].Relations["argoproj.io_AnalysisRun"].Contains("argoproj.io_Rollout"))
+		assert.Empty(t, rt.RelationCaches[server].Provenance)
+	})
+
+	t.Run("stopping a mapper stops its relation watch", func(t *testing.T) {
+		rm := newTestResourceMapper(t, map[schema.GroupVersionResource]string{podsGVR: "Pod"})
+		require.NoError(t, rm.WatchRelations(func(Relation, common.Provenance, int) {}))
+		assert.Len(t, rm.watch.informers, 1)
+		rm.Stop()
+		rm.Stop()
+		assert.Empty(t, rm.watch.informers)
+	})
+}
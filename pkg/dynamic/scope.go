@@ -0,0 +1,68 @@
+package dynamic
+
+import (
+	"slices"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// clusterScopedLeafKinds are well known cluster scoped kinds which do not own namespaced
+// objects, so an application deploying them can still be scanned namespace by namespace.
+var clusterScopedLeafKinds = map[string]bool{
+	"rbac.authorization.k8s.io_ClusterRole":                       true,
+	"rbac.authorization.k8s.io_ClusterRoleBinding":                true,
+	"apiextensions.k8s.io_CustomResourceDefinition":               true,
+	"admissionregistration.k8s.io_MutatingWebhookConfiguration":   true,
+	"admissionregistration.k8s.io_ValidatingWebhookConfiguration": true,
+	"storage.k8s.io_StorageClass":                                 true,
+	"scheduling.k8s.io_PriorityClass":                             true,
+	"networking.k8s.io_IngressClass":                              true,
+	"core_PersistentVolume":                                       true,
+}
+
+// ScanNamespaces returns the namespaces in which the relations of an application deploying the
+// given manifests to destinationNamespace have to be scanned: the destination namespace, the
+// namespaces of the manifests and the Namespaces the application creates. It returns
+// v1.NamespaceAll alone when the application deploys cluster scoped objects which may own
+// objects in any namespace, or namespaced objects without a known namespace.
+func (r *ResourceMapper) ScanNamespaces(destinationNamespace string, manifests []*common.ResourceInfo) []string {
+	namespaces := make(map[string]struct{})
+	if destinationNamespace != "" {
+		namespaces[destinationNamespace] = struct{}{}
+	}
+	for _, manifest := range manifests {
+		key := ResourceInfoKey(manifest.Group, manifest.Kind)
+		if r.IsClusterScoped(key) {
+			if key == "core_Namespace" {
+				namespaces[manifest.Name] = struct{}{}
+			} else if !clusterScopedLeafKinds[key] {
+				return []string{v1.NamespaceAll}
+			}
+			continue
+		}
+		namespace := manifest.Namespace
+		if namespace == "" {
+			namespace = destinationNamespace
+		}
+		if namespace == "" {
+			return []string{v1.NamespaceAll}
+		}
+		namespaces[namespace] = struct{}{}
+	}
+	out := make([]string, 0, len(namespaces))
+	for namespace := range namespaces {
+		out = append(out, namespace)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// ResourceInfoKey returns the resource key of the given API group and kind, the empty group
+// standing for the core group.
+func ResourceInfoKey(group, kind string) string {
+	if group == "" {
+		group = "core"
+	}
+	return group + "_" + kind
+}
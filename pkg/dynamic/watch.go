@@ -0,0 +1,197 @@
+package dynamic
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// RelationHandler is called for every relation of an object, with a delta of 1 when the object
+// starts linking the relation and -1 when it stops. The provenance has an empty cluster.
+type RelationHandler func(relation Relation, provenance common.Provenance, delta int)
+
+// relationWatch holds the informers used to keep the relations of a cluster current. Metadata
+// informers are used, except for the resources whose relations are inferred from their content.
+type relationWatch struct {
+	informers map[schema.GroupVersionResource]*resourceInformer
+	handler   RelationHandler
+}
+
+// resourceInformer is the informer of a single resource, which is stopped on its own when the
+// resource is removed from the cluster.
+type resourceInformer struct {
+	informer  cache.SharedIndexInformer
+	resource  ResourceType
+	inferrers []RelationInferrer
+	stopCh    chan struct{}
+	started   bool
+}
+
+// start starts the informers added since the last call.
+func (w *relationWatch) start() {
+	for _, ri := range w.informers {
+		if !ri.started {
+			ri.started = true
+			go ri.informer.Run(ri.stopCh)
+		}
+	}
+}
+
+// stop stops every informer of the watch.
+func (w *relationWatch) stop() {
+	for gvr, ri := range w.informers {
+		close(ri.stopCh)
+		delete(w.informers, gvr)
+	}
+}
+
+// WatchRelations starts metadata informers for every resource in ResourceList, and for the
+// resources added to it later, reporting the parent -> child links of the objects to handler.
+// The informers run until Stop is called.
+func (r *ResourceMapper) WatchRelations(handler RelationHandler) error {
+	r.resourcesMu.Lock()
+	defer r.resourcesMu.Unlock()
+	if r.watch != nil {
+		return fmt.Errorf("relations of cluster %s are already watched", r.ClusterHostname)
+	}
+	r.watch = &relationWatch{
+		informers: make(map[schema.GroupVersionResource]*resourceInformer),
+		handler:   handler,
+	}
+	for _, resource := range r.resourcesLocked() {
+		if err := r.watchResourceLocked(resource); err != nil {
+			return err
+		}
+	}
+	log.Infof("Starting relation watch for %d resources on cluster %s", len(r.watch.informers), r.ClusterHostname)
+	r.watch.start()
+	return nil
+}
+
+// WaitForRelationsSynced waits until the informers of the relation watch have listed all the
+// existing objects. It returns false if ctx is done first or the relations are not watched.
+func (r *ResourceMapper) WaitForRelationsSynced(ctx context.Context) bool {
+	r.resourcesMu.RLock()
+	if r.watch == nil {
+		r.resourcesMu.RUnlock()
+		return false
+	}
+	synced := make([]cache.InformerSynced, 0, len(r.watch.informers))
+	for _, ri := range r.watch.informers {
+		synced = append(synced, ri.informer.HasSynced)
+	}
+	r.resourcesMu.RUnlock()
+	return cache.WaitForCacheSync(ctx.Done(), synced...)
+}
+
+// watchResourceLocked adds an informer for the given resource to the relation watch.
+// Callers must hold resourcesMu.
+func (r *ResourceMapper) watchResourceLocked(resource ResourceType) error {
+	gvr := resource.GVR
+	if _, exists := r.watch.informers[gvr]; exists || isExcludedKind(gvr.Group, resource.Kind) {
+		return nil
+	}
+	inferrers := inferrersFor(resource)
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	var informer cache.SharedIndexInformer
+	if needObjects(inferrers) {
+		informer = dynamicinformer.NewFilteredDynamicInformer(r.DynamicClient, gvr, v1.NamespaceAll, 0, indexers, nil).Informer()
+	} else {
+		informer = metadatainformer.NewFilteredMetadataInformer(r.MetadataClient, gvr, v1.NamespaceAll, 0, indexers, nil).Informer()
+		// Only the metadata used to derive the relations is needed, strip everything else to keep the caches small
+		if err := informer.SetTransform(stripMetadata(len(inferrers) > 0)); err != nil {
+			return fmt.Errorf("failed to watch resource %s: %w", gvr, err)
+		}
+	}
+	handler := r.watch.handler
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			for relation, provenance := range objectRelations(resource, inferrers, obj) {
+				handler(relation, provenance, 1)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldRelations := objectRelations(resource, inferrers, oldObj)
+			newRelations := objectRelations(resource, inferrers, newObj)
+			for relation, provenance := range newRelations {
+				if _, ok := oldRelations[relation]; !ok {
+					handler(relation, provenance, 1)
+				}
+			}
+			for relation, provenance := range oldRelations {
+				if _, ok := newRelations[relation]; !ok {
+					handler(relation, provenance, -1)
+				}
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			for relation, provenance := range objectRelations(resource, inferrers, obj) {
+				handler(relation, provenance, -1)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch resource %s: %w", gvr, err)
+	}
+	r.watch.informers[gvr] = &resourceInformer{
+		informer:  informer,
+		resource:  resource,
+		inferrers: inferrers,
+		stopCh:    make(chan struct{}),
+	}
+	return nil
+}
+
+// unwatchResourceLocked stops the informer of the given resource, and withdraws the links of the
+// objects it holds, as no event will be received for them any more. Callers must hold resourcesMu.
+func (r *ResourceMapper) unwatchResourceLocked(gvr schema.GroupVersionResource) {
+	ri, ok := r.watch.informers[gvr]
+	if !ok {
+		return
+	}
+	delete(r.watch.informers, gvr)
+	close(ri.stopCh)
+	for _, obj := range ri.informer.GetStore().List() {
+		for relation, provenance := range objectRelations(ri.resource, ri.inferrers, obj) {
+			r.watch.handler(relation, provenance, -1)
+		}
+	}
+}
+
+// stripMetadata returns a transform which drops every field of the object metadata that is not
+// needed to derive its relations. Annotations are kept for the inferrers if keepAnnotations is true.
+func stripMetadata(keepAnnotations bool) cache.TransformFunc {
+	return func(obj interface{}) (interface{}, error) {
+		item, ok := obj.(*v1.PartialObjectMetadata)
+		if !ok {
+			return obj, nil
+		}
+		stripped := &v1.PartialObjectMetadata{
+			TypeMeta: item.TypeMeta,
+			ObjectMeta: v1.ObjectMeta{
+				Name:            item.Name,
+				Namespace:       item.Namespace,
+				UID:             item.UID,
+				ResourceVersion: item.ResourceVersion,
+				OwnerReferences: item.OwnerReferences,
+			},
+		}
+		if keepAnnotations {
+			stripped.Annotations = maps.Clone(item.Annotations)
+			delete(stripped.Annotations, corev1.LastAppliedConfigAnnotation)
+		}
+		return stripped, nil
+	}
+}
@@ -0,0 +1,139 @@
+package graph
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/avitaltamir/cyphernetes/pkg/core"
+	"github.com/avitaltamir/cyphernetes/pkg/provider"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// recordProvenance records how the given child was reached from its parent, unless the child
+// was reached before.
+func (q *QueryServer) recordProvenance(parent, child *common.ResourceInfo) {
+	if q.Provenance == nil {
+		q.Provenance = make(map[common.ResourceInfo]common.Provenance)
+	}
+	if _, ok := q.Provenance[*child]; ok || *parent == *child {
+		return
+	}
+	provenance := common.Provenance{
+		Source:  common.SourceRule,
+		Cluster: q.Cluster,
+		Parent:  *parent,
+		Child:   *child,
+	}
+	if rule, ok := q.findRule(parent, child); ok {
+		if isOwnerReferenceRule(rule) {
+			provenance.Source = common.SourceOwnerReference
+		} else {
+			provenance.Rule = string(rule.Relationship)
+		}
+	}
+	q.Provenance[*child] = provenance
+}
+
+// ProvenanceChain returns the chain of relations through which the given node was reached from
+// the root of a traversal, the relation leading to the root coming first. The chain is empty if
+// the node is a root.
+func (q *QueryServer) ProvenanceChain(node common.ResourceInfo) []common.Provenance {
+	var chain []common.Provenance
+	visited := make(map[common.ResourceInfo]bool)
+	for !visited[node] {
+		visited[node] = true
+		provenance, ok := q.Provenance[node]
+		if !ok {
+			break
+		}
+		chain = append(chain, provenance)
+		node = provenance.Parent
+	}
+	slices.Reverse(chain)
+	return chain
+}
+
+// findRule returns the relationship rule of this QueryServer relating the kinds of the given
+// parent and child.
+func (q *QueryServer) findRule(parent, child *common.ResourceInfo) (core.RelationshipRule, bool) {
+	if q.Provider == nil {
+		return core.RelationshipRule{}, false
+	}
+	parentGVR, err := findResourceGVR(q.Provider, parent)
+	if err != nil {
+		return core.RelationshipRule{}, false
+	}
+	childGVR, err := findResourceGVR(q.Provider, child)
+	if err != nil {
+		return core.RelationshipRule{}, false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.indexRules()
+	rule, ok := q.ruleIndex[gvrPair{parentGVR, childGVR}]
+	return rule, ok
+}
+
+// gvrPair is a pair of kinds related by a relationship rule, keying the rule index.
+type gvrPair [2]schema.GroupVersionResource
+
+// indexRules adds the rules added since the previous call to the rule index, under both orders
+// of their kinds. The first rule relating two kinds is kept. The rules of a registry are only
+// ever appended, so the rules indexed before are left as they are. The caller must hold q.mu.
+func (q *QueryServer) indexRules() {
+	rules := q.rules.GetRelationshipRules()
+	if q.ruleIndex == nil {
+		q.ruleIndex = make(map[gvrPair]core.RelationshipRule)
+	}
+	for _, rule := range rules[q.indexedRules:] {
+		kindA, errA := resolveGVR(q.Provider, rule.KindA)
+		kindB, errB := resolveGVR(q.Provider, rule.KindB)
+		if errA != nil || errB != nil {
+			continue
+		}
+		for _, pair := range []gvrPair{{kindA, kindB}, {kindB, kindA}} {
+			if _, ok := q.ruleIndex[pair]; !ok {
+				q.ruleIndex[pair] = rule
+			}
+		}
+	}
+	q.indexedRules = len(rules)
+}
+
+// isOwnerReferenceRule returns true if the rule matches objects through their ownerReferences.
+func isOwnerReferenceRule(rule core.RelationshipRule) bool {
+	for _, criterion := range rule.MatchCriteria {
+		if strings.Contains(criterion.FieldA, "ownerReferences") || strings.Contains(criterion.FieldB, "ownerReferences") {
+			return true
+		}
+	}
+	return false
+}
+
+// findResourceGVR returns the GVR of the kind of the given resource, using its group to pick
+// among the resources sharing the same kind.
+func findResourceGVR(p provider.Provider, info *common.ResourceInfo) (schema.GroupVersionResource, error) {
+	gvr, err := p.FindGVR(info.Kind)
+	if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		return gvr, err
+	}
+	// The ambiguous error lists the qualified names of the candidates, one per line
+	for _, option := range strings.Split(err.Error(), "\n")[1:] {
+		candidate, optionErr := p.FindGVR(option)
+		if optionErr == nil && candidate.Group == info.Group {
+			return candidate, nil
+		}
+	}
+	return gvr, err
+}
+
+// resolveGVR returns the GVR of a kind named in a relationship rule, preferring the core group
+// when the name is ambiguous.
+func resolveGVR(p provider.Provider, kind string) (schema.GroupVersionResource, error) {
+	gvr, err := p.FindGVR(kind)
+	if err != nil && strings.Contains(err.Error(), "ambiguous") {
+		return p.FindGVR("core." + kind)
+	}
+	return gvr, err
+}
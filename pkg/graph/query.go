@@ -1,11 +1,15 @@
 package graph
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/anandf/resource-tracker/pkg/kube"
 	"github.com/avitaltamir/cyphernetes/pkg/core"
 	"github.com/avitaltamir/cyphernetes/pkg/provider"
 	"github.com/avitaltamir/cyphernetes/pkg/provider/apiserver"
@@ -19,8 +23,17 @@ const (
 	AnnotationTrackingCriteria = "$.metadata.annotations.argocd\\.argoproj\\.io/tracking-id"
 	TrackingMethodLabel        = "label"
 	TrackingMethodAnnotation   = "annotation"
+	// DefaultQueryTimeout is the default time allowed for a single graph query.
+	DefaultQueryTimeout = 1 * time.Minute
+	// DefaultTraversalTimeout is the default time budget for traversing the children of all
+	// the applications in a single analysis run.
+	DefaultTraversalTimeout = 10 * time.Minute
 )
 
+// ErrTraversalIncomplete is returned along with the partial result of a traversal, when one or
+// more nodes could not be visited because a query timed out or the traversal ran out of time.
+var ErrTraversalIncomplete = errors.New("traversal incomplete")
+
 var (
 	ArgoAppGVR = schema.GroupVersionResource{
 		Group:    "argoproj.io",
@@ -102,8 +115,32 @@ type QueryServer struct {
 	Tracker             string
 	Comparison          core.ComparisonType
 	VisitedKinds        map[common.ResourceInfo]bool
+	// QueryTimeout is the time allowed for a single graph query, no timeout is applied if zero.
+	QueryTimeout time.Duration
+	// ControlPlaneNamespace is the namespace of the Argo CD control plane, the applications
+	// outside of it are tracked as <namespace>_<app>.
+	ControlPlaneNamespace string
+	// Cluster is the server of the cluster queried, recorded in the provenance of the nodes.
+	Cluster string
+	// RestConfig is the config of the cluster queried.
+	RestConfig *rest.Config
+	// Provenance holds how each node reached by a traversal was first reached from its parent.
+	// It is kept across traversals until it is reset by the caller.
+	Provenance map[common.ResourceInfo]common.Provenance
+	// rules is the set of relationship rules known to this QueryServer, built from the
+	// resource kinds of the cluster its Provider points to.
+	rules *core.RuleRegistry
+	// ruleIndex holds the first rule relating each pair of kinds, for the first indexedRules
+	// rules of the registry
+	ruleIndex    map[gvrPair]core.RelationshipRule
+	indexedRules int
+	// mu serializes the queries of this QueryServer, its executor and rules are not safe for
+	// concurrent use. The QueryServers of other clusters are not blocked.
+	mu sync.Mutex
 }
 
+// NewQueryServer creates a QueryServer with its own query executor and relationship rules
+// for the cluster given by restConfig.
 func NewQueryServer(restConfig *rest.Config, trackingMethod string, loadCustomRules bool) (*QueryServer, error) {
 	// Create the API server provider
 	p, err := apiserver.NewAPIServerProviderWithOptions(&apiserver.APIServerProviderConfig{
@@ -114,44 +151,61 @@ func NewQueryServer(restConfig *rest.Config, trackingMethod string, loadCustomRu
 	if err != nil {
 		return nil, err
 	}
-
-	tracker := "LBL"
-	fieldAMatchCriteria := LabelTrackingCriteria
-	comparison := core.ExactMatch
-	if trackingMethod == TrackingMethodAnnotation {
-		tracker = "ANN"
-		fieldAMatchCriteria = AnnotationTrackingCriteria
-		comparison = core.StringContains
+	qs, err := NewProviderQueryServer(p, trackingMethod)
+	if err != nil {
+		return nil, err
 	}
+	qs.Cluster = restConfig.Host
+	qs.RestConfig = restConfig
+	rules := qs.rules
+
 	if loadCustomRules {
 		for _, knownResourceKind := range p.(*apiserver.APIServerProvider).GetKnownResourceKinds() {
 			if blackListedKinds[knownResourceKind] || leafKinds[knownResourceKind] {
 				log.Infof("skipping resource kind: %s", knownResourceKind)
 				continue
 			}
-			relationshipTypeName := strings.ToUpper(fmt.Sprintf("%s_%s_%s", "ARGOAPP_OWN", tracker, knownResourceKind))
+			relationshipTypeName := strings.ToUpper(fmt.Sprintf("%s_%s_%s", "ARGOAPP_OWN", qs.Tracker, knownResourceKind))
 			if strings.Index(relationshipTypeName, ".") != -1 {
 				relationshipTypeName = strings.Replace(relationshipTypeName, ".", "_", -1)
 			}
-			core.AddRelationshipRule(core.RelationshipRule{
-				KindA:        strings.ToLower(knownResourceKind),
-				KindB:        "applications.argoproj.io",
-				Relationship: core.RelationshipType(relationshipTypeName),
-				MatchCriteria: []core.MatchCriterion{
-					{
-						FieldA:         fieldAMatchCriteria,
-						FieldB:         "$.metadata.name",
-						ComparisonType: comparison,
-					},
-				},
+			rules.AddRelationshipRule(core.RelationshipRule{
+				KindA:         strings.ToLower(knownResourceKind),
+				KindB:         "applications.argoproj.io",
+				Relationship:  core.RelationshipType(relationshipTypeName),
+				MatchCriteria: []core.MatchCriterion{qs.trackingCriterion()},
 			})
 		}
-		addOpenShiftSpecificRules()
+		addOpenShiftSpecificRules(rules)
 	}
-	// Create query executor with the provider
-	executor := core.GetQueryExecutorInstance(p)
-	if executor == nil {
-		os.Exit(1)
+	// Derive the relationships from the resource specs of this cluster
+	specs, err := p.GetOpenAPIResourceSpecs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize relationships: %w", err)
+	}
+	rules.InitializeRelationships(specs, p)
+	return qs, nil
+}
+
+// NewProviderQueryServer creates a QueryServer with its own query executor and the built-in
+// relationship rules, for the cluster reached through the given provider.
+func NewProviderQueryServer(p provider.Provider, trackingMethod string) (*QueryServer, error) {
+	// The tracking id of a resource starts with the instance name of its application, matched
+	// by matchesTrackingID. The instance label is the instance name, matched by
+	// matchesInstanceLabel.
+	tracker := "LBL"
+	fieldAMatchCriteria := LabelTrackingCriteria
+	comparison := core.ExactMatch
+	if trackingMethod == TrackingMethodAnnotation {
+		tracker = "ANN"
+		fieldAMatchCriteria = AnnotationTrackingCriteria
+		comparison = core.CustomMatch
+	}
+	// Create query executor bound to the provider and the rules of this cluster
+	rules := core.NewRuleRegistry()
+	executor, err := core.NewQueryExecutorWithRules(p, rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query executor: %w", err)
 	}
 	return &QueryServer{
 		Provider:            p,
@@ -160,12 +214,28 @@ func NewQueryServer(restConfig *rest.Config, trackingMethod string, loadCustomRu
 		FieldAMatchCriteria: fieldAMatchCriteria,
 		Comparison:          comparison,
 		VisitedKinds:        make(map[common.ResourceInfo]bool),
+		QueryTimeout:        DefaultQueryTimeout,
+		Provenance:          make(map[common.ResourceInfo]common.Provenance),
+		rules:               rules,
 	}, nil
+}
 
+// ServedKinds returns the kinds served by the cluster of the QueryServer which can be listed and
+// watched, by group.
+func (q *QueryServer) ServedKinds() (common.GroupedResourceKinds, error) {
+	p, ok := q.Provider.(*apiserver.APIServerProvider)
+	if !ok {
+		return nil, fmt.Errorf("provider of cluster %s has no discovery client", q.Cluster)
+	}
+	discoveryClient, err := p.GetDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	return kube.ServedKinds(discoveryClient)
 }
 
-func (q *QueryServer) GetApplicationChildResources(name, namespace string) (common.ResourceInfoSet, error) {
-	return q.GetNestedChildResources(&common.ResourceInfo{
+func (q *QueryServer) GetApplicationChildResources(ctx context.Context, name, namespace string) (common.ResourceInfoSet, error) {
+	return q.GetNestedChildResources(ctx, &common.ResourceInfo{
 		Kind:      "applications.argoproj.io",
 		Group:     "argoproj.io",
 		Name:      name,
@@ -173,21 +243,24 @@ func (q *QueryServer) GetApplicationChildResources(name, namespace string) (comm
 	})
 }
 
-func (q *QueryServer) GetNestedChildResources(resource *common.ResourceInfo) (common.ResourceInfoSet, error) {
+// GetNestedChildResources returns the given resource along with its children at all levels.
+// If the traversal could not visit every node before ctx is done, the partial result is
+// returned along with an error wrapping ErrTraversalIncomplete.
+func (q *QueryServer) GetNestedChildResources(ctx context.Context, resource *common.ResourceInfo) (common.ResourceInfoSet, error) {
 	allLevelChildren := make(common.ResourceInfoSet)
 	for resInfo := range defaultIncludedResources {
 		allLevelChildren[resInfo] = common.Void{}
 		q.VisitedKinds[resInfo] = true
 	}
-	allLevelChildren, err := q.depthFirstTraversal(resource, allLevelChildren)
-	if err != nil {
+	allLevelChildren, err := q.depthFirstTraversal(ctx, resource, allLevelChildren)
+	if err != nil && !errors.Is(err, ErrTraversalIncomplete) {
 		return nil, err
 	}
-	return allLevelChildren, nil
+	return allLevelChildren, err
 }
 
 // getChildren returns the immediate direct child of a given node by doing a graph query.
-func (q *QueryServer) getChildren(parentResourceInfo *common.ResourceInfo) ([]*common.ResourceInfo, error) {
+func (q *QueryServer) getChildren(ctx context.Context, parentResourceInfo *common.ResourceInfo) ([]*common.ResourceInfo, error) {
 	if leafKinds[parentResourceInfo.Kind] || blackListedKinds[parentResourceInfo.Kind] {
 		log.Infof("skipping leaf or blacklisted resource: %v", parentResourceInfo)
 		return nil, nil
@@ -206,7 +279,7 @@ func (q *QueryServer) getChildren(parentResourceInfo *common.ResourceInfo) ([]*c
 	if parentResourceInfo.Name != "" {
 		queryStr = fmt.Sprintf("MATCH (p: %s{name:\"%s\"}) -> (c) RETURN c.kind, c.apiVersion, c.metadata.namespace", unambiguousKind, parentResourceInfo.Name)
 	}
-	queryResult, err := q.executeQuery(queryStr, parentResourceInfo.Namespace)
+	queryResult, err := q.executeQuery(ctx, queryStr, parentResourceInfo.Namespace)
 	if err != nil {
 		return nil, err
 	}
@@ -218,27 +291,48 @@ func (q *QueryServer) getChildren(parentResourceInfo *common.ResourceInfo) ([]*c
 	return results, nil
 }
 
-// executeQuery executes the graph query using graph library
-func (q *QueryServer) executeQuery(queryStr, namespace string) (*core.QueryResult, error) {
+// executeQuery executes the graph query using graph library. The query is cancelled if it
+// does not complete within QueryTimeout or before ctx is done.
+func (q *QueryServer) executeQuery(ctx context.Context, queryStr, namespace string) (*core.QueryResult, error) {
 	// Parse the query to get an AST
 	ast, err := core.ParseQuery(queryStr)
 	if err != nil {
 		return nil, err
 	}
+	if q.QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.QueryTimeout)
+		defer cancel()
+	}
 
-	// Execute the query against the Kubernetes API.
-	queryResult, err := q.Executor.Execute(ast, namespace)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query %q did not complete: %w", queryStr, err)
+	}
+	// Execute the query against the Kubernetes API, using the rules of this QueryServer. The
+	// resources being listed are abandoned once ctx is done.
+	result, err := q.Executor.ExecuteContext(ctx, ast, namespace)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("query %q did not complete: %w", queryStr, ctxErr)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &queryResult, err
+	return &result, nil
 }
 
 // depthFirstTraversal recursively traverses the resource tree using a DFS approach.
-func (q *QueryServer) depthFirstTraversal(info *common.ResourceInfo, visitedNodes common.ResourceInfoSet) (common.ResourceInfoSet, error) {
+// Nodes whose children could not be queried in time are skipped, and the traversal stops
+// once ctx is done; in both cases an error wrapping ErrTraversalIncomplete is returned
+// along with the nodes visited so far.
+func (q *QueryServer) depthFirstTraversal(ctx context.Context, info *common.ResourceInfo, visitedNodes common.ResourceInfoSet) (common.ResourceInfoSet, error) {
 	if info == nil {
 		return visitedNodes, nil
 	}
+	if err := ctx.Err(); err != nil {
+		return visitedNodes, fmt.Errorf("%w: stopped before visiting %v: %w", ErrTraversalIncomplete, info, err)
+	}
 	log.Debugf("Visiting: %v\n", info)
 	if _, ok := visitedNodes[*info]; ok {
 		log.Debugf("Resource visited already: %v", info)
@@ -246,38 +340,103 @@ func (q *QueryServer) depthFirstTraversal(info *common.ResourceInfo, visitedNode
 	}
 	visitedNodes[*info] = common.Void{}
 	// 2. Get children of the current node
-	children, err := q.getChildren(info)
+	children, err := q.getChildren(ctx, info)
 	if err != nil {
 		log.Errorf("error getting children of resource %v : %v", info, err)
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return visitedNodes, fmt.Errorf("%w: %w", ErrTraversalIncomplete, err)
+		}
 		return visitedNodes, err
 	}
 
 	// 3. Recursively call DFS for each child
+	var incompleteErr error
 	for _, child := range children {
-		visitedNodes, err = q.depthFirstTraversal(child, visitedNodes)
-		if err != nil {
-			continue
+		// The nodes visited before, like the root reached back from its children, keep their
+		// provenance
+		if _, ok := visitedNodes[*child]; !ok {
+			q.recordProvenance(info, child)
+		}
+		visitedNodes, err = q.depthFirstTraversal(ctx, child, visitedNodes)
+		if errors.Is(err, ErrTraversalIncomplete) {
+			incompleteErr = err
+			if ctx.Err() != nil {
+				break
+			}
 		}
 	}
-	return visitedNodes, nil
+	return visitedNodes, incompleteErr
 }
 
 // AddRuleForResourceKind adds the rule for a new resource kind that was added
 func (q *QueryServer) AddRuleForResourceKind(resourceKind string) {
-	core.AddRelationshipRule(core.RelationshipRule{
-		KindA:        strings.ToLower(resourceKind),
-		KindB:        "applications",
-		Relationship: core.RelationshipType(strings.ToUpper(fmt.Sprintf("%s_%s_%s", "ARGOAPP_OWN", q.Tracker, resourceKind))),
-		MatchCriteria: []core.MatchCriterion{
-			{
-				FieldA:         q.FieldAMatchCriteria,
-				FieldB:         "$.metadata.name",
-				ComparisonType: q.Comparison,
-			},
-		},
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rules.AddRelationshipRule(core.RelationshipRule{
+		KindA:         strings.ToLower(resourceKind),
+		KindB:         "applications",
+		Relationship:  core.RelationshipType(strings.ToUpper(fmt.Sprintf("%s_%s_%s", "ARGOAPP_OWN", q.Tracker, resourceKind))),
+		MatchCriteria: []core.MatchCriterion{q.trackingCriterion()},
 	})
 }
 
+// trackingCriterion returns the criterion matching the resources of an application by the
+// tracking method of the QueryServer.
+func (q *QueryServer) trackingCriterion() core.MatchCriterion {
+	compare := q.matchesInstanceLabel
+	if q.Comparison == core.CustomMatch {
+		compare = q.matchesTrackingID
+	}
+	return core.MatchCriterion{
+		FieldA:         q.FieldAMatchCriteria,
+		FieldB:         "$.metadata",
+		ComparisonType: core.CustomMatch,
+		Compare:        compare,
+	}
+}
+
+// matchesTrackingID returns true if the given tracking id, formatted as
+// <app>:<group>/<kind>:<namespace>/<name>, belongs to the application of the given metadata.
+func (q *QueryServer) matchesTrackingID(trackingID, appMetadata interface{}) bool {
+	id, ok := trackingID.(string)
+	if !ok {
+		return false
+	}
+	instanceName, _, found := strings.Cut(id, ":")
+	if !found {
+		return false
+	}
+	return q.isInstanceName(instanceName, appMetadata)
+}
+
+// matchesInstanceLabel returns true if the given instance label belongs to the application of the
+// given metadata.
+func (q *QueryServer) matchesInstanceLabel(label, appMetadata interface{}) bool {
+	instanceName, ok := label.(string)
+	return ok && q.isInstanceName(instanceName, appMetadata)
+}
+
+// isInstanceName returns true if the given instance name is the one of the application of the
+// given metadata. The application is named <namespace>_<app> when it is outside the
+// control-plane namespace. If the control-plane namespace is unknown, both forms are matched.
+func (q *QueryServer) isInstanceName(instanceName string, appMetadata interface{}) bool {
+	metadata, ok := appMetadata.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+	if name == "" || instanceName == "" {
+		return false
+	}
+	if q.ControlPlaneNamespace == "" || namespace == "" || namespace == q.ControlPlaneNamespace {
+		if instanceName == name {
+			return true
+		}
+	}
+	return namespace != "" && namespace != q.ControlPlaneNamespace && instanceName == namespace+"_"+name
+}
+
 // extractResourceInfo extracts the ResourceInfo from a given query result and variable name.
 func extractResourceInfo(queryResult *core.QueryResult, variable string) ([]*common.ResourceInfo, error) {
 	child := queryResult.Data[variable]
@@ -322,8 +481,8 @@ func extractResourceInfo(queryResult *core.QueryResult, variable string) ([]*com
 }
 
 // addOpenShiftSpecificRules adds rules that are specific to OpenShift CustomResources
-func addOpenShiftSpecificRules() {
-	core.AddRelationshipRule(core.RelationshipRule{
+func addOpenShiftSpecificRules(rules *core.RuleRegistry) {
+	rules.AddRelationshipRule(core.RelationshipRule{
 		KindA:        "hostfirmwaresettings",
 		KindB:        "baremetalhosts",
 		Relationship: "BAREMETALHOSTS_OWN_HOSTFIRMWARE_SETTINGS",
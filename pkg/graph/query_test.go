@@ -0,0 +1,167 @@
+package graph
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/avitaltamir/cyphernetes/pkg/core"
+	"github.com/avitaltamir/cyphernetes/pkg/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_GetNestedChildResources(t *testing.T) {
+	t.Run("returns partial result when the traversal budget is spent", func(t *testing.T) {
+		qs := &QueryServer{VisitedKinds: make(map[common.ResourceInfo]bool)}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		children, err := qs.GetNestedChildResources(ctx, &common.ResourceInfo{Kind: "Deployment", Group: "apps", Name: "guestbook"})
+		require.ErrorIs(t, err, ErrTraversalIncomplete)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Len(t, children, len(defaultIncludedResources))
+	})
+
+	t.Run("leaf resources are visited without querying", func(t *testing.T) {
+		qs := &QueryServer{VisitedKinds: make(map[common.ResourceInfo]bool)}
+		leaf := common.ResourceInfo{Kind: "ConfigMap", Name: "guestbook-config"}
+
+		children, err := qs.GetNestedChildResources(context.Background(), &leaf)
+		require.NoError(t, err)
+		assert.Contains(t, children, leaf)
+	})
+}
+
+func Test_ProvenanceChain(t *testing.T) {
+	qs := &QueryServer{Cluster: "https://kubernetes.default.svc"}
+	deployment := common.ResourceInfo{Kind: "Deployment", Group: "apps", Name: "guestbook-ui", Namespace: "guestbook"}
+	replicaSet := common.ResourceInfo{Kind: "ReplicaSet", Group: "apps", Namespace: "guestbook"}
+	pod := common.ResourceInfo{Kind: "Pod", Namespace: "guestbook"}
+	qs.recordProvenance(&deployment, &replicaSet)
+	qs.recordProvenance(&replicaSet, &pod)
+	// Only the first parent a node is reached from is recorded
+	qs.recordProvenance(&deployment, &pod)
+
+	chain := qs.ProvenanceChain(pod)
+	require.Len(t, chain, 2)
+	assert.Equal(t, deployment, chain[0].Parent)
+	assert.Equal(t, replicaSet, chain[1].Parent)
+	assert.Equal(t, pod, chain[1].Child)
+	assert.Equal(t, "https://kubernetes.default.svc", chain[1].Cluster)
+	assert.Empty(t, qs.ProvenanceChain(deployment))
+}
+
+// blockingProvider lists the resources of a kind once its context is done, or right away once
+// unblocked.
+type blockingProvider struct {
+	provider.Provider
+	unblocked atomic.Bool
+}
+
+func (p *blockingProvider) FindGVR(kind string) (schema.GroupVersionResource, error) {
+	return schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, nil
+}
+
+func (p *blockingProvider) GetK8sResourcesContext(ctx context.Context, kind, fieldSelector, labelSelector, namespace string) (interface{}, error) {
+	if p.unblocked.Load() {
+		return []map[string]interface{}{}, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func Test_executeQuery(t *testing.T) {
+	p := &blockingProvider{}
+	executor, err := core.NewQueryExecutorWithRules(p, core.NewRuleRegistry())
+	require.NoError(t, err)
+	qs := &QueryServer{Provider: p, Executor: executor, QueryTimeout: 50 * time.Millisecond}
+
+	_, err = qs.executeQuery(context.Background(), "MATCH (d:Deployment) RETURN d.kind", "default")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The cancelled query released the QueryServer, the next one runs right away
+	p.unblocked.Store(true)
+	done := make(chan error, 1)
+	go func() {
+		_, err := qs.executeQuery(context.Background(), "MATCH (d:Deployment) RETURN d.kind", "default")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("query blocked by the cancelled one")
+	}
+}
+
+func Test_matchesTrackingID(t *testing.T) {
+	qs := &QueryServer{ControlPlaneNamespace: "argocd", FieldAMatchCriteria: AnnotationTrackingCriteria, Comparison: core.CustomMatch}
+	tests := []struct {
+		name       string
+		trackingID interface{}
+		metadata   map[string]interface{}
+		expected   bool
+	}{
+		{"application in the control-plane namespace", "guestbook:apps/Deployment:guestbook/guestbook-ui", map[string]interface{}{"name": "guestbook", "namespace": "argocd"}, true},
+		{"application outside the control-plane namespace", "team_guestbook:apps/Deployment:guestbook/guestbook-ui", map[string]interface{}{"name": "guestbook", "namespace": "team"}, true},
+		{"application name as a prefix of another", "guestbook-ui:apps/Deployment:guestbook/guestbook-ui", map[string]interface{}{"name": "guestbook", "namespace": "argocd"}, false},
+		{"application name as a substring", "my-guestbook:apps/Deployment:guestbook/guestbook-ui", map[string]interface{}{"name": "guestbook", "namespace": "argocd"}, false},
+		{"application name in the resource part", "other:apps/Deployment:guestbook/guestbook", map[string]interface{}{"name": "guestbook", "namespace": "argocd"}, false},
+		{"same name in another namespace", "guestbook:apps/Deployment:guestbook/guestbook-ui", map[string]interface{}{"name": "guestbook", "namespace": "team"}, false},
+		{"namespaced form in the control-plane namespace", "argocd_guestbook:apps/Deployment:guestbook/guestbook-ui", map[string]interface{}{"name": "guestbook", "namespace": "argocd"}, false},
+		{"tracking id without separator", "guestbook", map[string]interface{}{"name": "guestbook", "namespace": "argocd"}, false},
+		{"missing tracking id", nil, map[string]interface{}{"name": "guestbook", "namespace": "argocd"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, qs.matchesTrackingID(tt.trackingID, tt.metadata))
+		})
+	}
+
+	t.Run("both forms are matched without control-plane namespace", func(t *testing.T) {
+		qs := &QueryServer{}
+		metadata := map[string]interface{}{"name": "guestbook", "namespace": "team"}
+		assert.True(t, qs.matchesTrackingID("guestbook:apps/Deployment:guestbook/guestbook-ui", metadata))
+		assert.True(t, qs.matchesTrackingID("team_guestbook:apps/Deployment:guestbook/guestbook-ui", metadata))
+		assert.False(t, qs.matchesTrackingID("guestbook-ui:apps/Deployment:guestbook/guestbook-ui", metadata))
+	})
+}
+
+func Test_matchesInstanceLabel(t *testing.T) {
+	qs := &QueryServer{ControlPlaneNamespace: "argocd", FieldAMatchCriteria: LabelTrackingCriteria, Comparison: core.ExactMatch}
+	tests := []struct {
+		name     string
+		label    interface{}
+		metadata map[string]interface{}
+		expected bool
+	}{
+		{"application in the control-plane namespace", "guestbook", map[string]interface{}{"name": "guestbook", "namespace": "argocd"}, true},
+		{"application outside the control-plane namespace", "team_guestbook", map[string]interface{}{"name": "guestbook", "namespace": "team"}, true},
+		{"bare name outside the control-plane namespace", "guestbook", map[string]interface{}{"name": "guestbook", "namespace": "team"}, false},
+		{"application name as a prefix of another", "guestbook-ui", map[string]interface{}{"name": "guestbook", "namespace": "argocd"}, false},
+		{"missing label", nil, map[string]interface{}{"name": "guestbook", "namespace": "argocd"}, false},
+		{"empty label", "", map[string]interface{}{"name": "", "namespace": "argocd"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, qs.matchesInstanceLabel(tt.label, tt.metadata))
+		})
+	}
+}
+
+func Test_trackingCriterion(t *testing.T) {
+	label := (&QueryServer{FieldAMatchCriteria: LabelTrackingCriteria, Comparison: core.ExactMatch}).trackingCriterion()
+	assert.Equal(t, core.CustomMatch, label.ComparisonType)
+	assert.Equal(t, "$.metadata", label.FieldB)
+	assert.NotNil(t, label.Compare)
+	assert.True(t, label.Compare("team_guestbook", map[string]interface{}{"name": "guestbook", "namespace": "team"}))
+
+	annotation := (&QueryServer{FieldAMatchCriteria: AnnotationTrackingCriteria, Comparison: core.CustomMatch}).trackingCriterion()
+	assert.Equal(t, core.CustomMatch, annotation.ComparisonType)
+	assert.Equal(t, "$.metadata", annotation.FieldB)
+	assert.NotNil(t, annotation.Compare)
+}
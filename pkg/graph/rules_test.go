@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	"github.com/avitaltamir/cyphernetes/pkg/core"
+	"github.com/avitaltamir/cyphernetes/pkg/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_ruleIsolation(t *testing.T) {
+	builtin := len(core.NewRuleRegistry().GetRelationshipRules())
+
+	t.Run("rules added to one query server are not visible in another", func(t *testing.T) {
+		clusterA := &QueryServer{Tracker: "LBL", FieldAMatchCriteria: LabelTrackingCriteria, rules: core.NewRuleRegistry()}
+		clusterB := &QueryServer{Tracker: "LBL", FieldAMatchCriteria: LabelTrackingCriteria, rules: core.NewRuleRegistry()}
+		clusterA.AddRuleForResourceKind("DeploymentConfig")
+
+		assert.Len(t, clusterA.rules.GetRelationshipRules(), builtin+1)
+		assert.Len(t, clusterB.rules.GetRelationshipRules(), builtin)
+		assert.Len(t, core.GetRelationshipRules(), builtin)
+	})
+
+	t.Run("match criteria are not shared between query servers", func(t *testing.T) {
+		clusterA := core.NewRuleRegistry()
+		clusterB := core.NewRuleRegistry()
+		rule := clusterA.GetRelationshipRules()[0]
+		criteria := len(rule.MatchCriteria)
+		clusterA.GetRelationshipRules()[0].MatchCriteria = append(rule.MatchCriteria, core.MatchCriterion{FieldA: "$.spec.foo"})
+		assert.Len(t, clusterB.GetRelationshipRules()[0].MatchCriteria, criteria)
+	})
+}
+
+// kindsProvider resolves the kinds of its map, and counts the lookups.
+type kindsProvider struct {
+	provider.Provider
+	kinds   map[string]schema.GroupVersionResource
+	lookups int
+}
+
+func (p *kindsProvider) FindGVR(kind string) (schema.GroupVersionResource, error) {
+	p.lookups++
+	gvr, ok := p.kinds[kind]
+	if !ok {
+		return schema.GroupVersionResource{}, fmt.Errorf("kind %s not found", kind)
+	}
+	return gvr, nil
+}
+
+func Test_findRule(t *testing.T) {
+	p := &kindsProvider{kinds: map[string]schema.GroupVersionResource{
+		"Deployment":  {Group: "apps", Version: "v1", Resource: "deployments"},
+		"deployments": {Group: "apps", Version: "v1", Resource: "deployments"},
+		"ReplicaSet":  {Group: "apps", Version: "v1", Resource: "replicasets"},
+		"replicasets": {Group: "apps", Version: "v1", Resource: "replicasets"},
+		"ConfigMap":   {Version: "v1", Resource: "configmaps"},
+		"configmaps":  {Version: "v1", Resource: "configmaps"},
+	}}
+	rules := &core.RuleRegistry{}
+	rules.AddRelationshipRule(core.RelationshipRule{
+		KindA:         "replicasets",
+		KindB:         "deployments",
+		Relationship:  "DEPLOYMENT_OWN_REPLICASET",
+		MatchCriteria: []core.MatchCriterion{{FieldA: "$.metadata.ownerReferences[].name", FieldB: "$.metadata.name"}},
+	})
+	qs := &QueryServer{Provider: p, rules: rules}
+	deployment := &common.ResourceInfo{Kind: "Deployment", Group: "apps"}
+	replicaSet := &common.ResourceInfo{Kind: "ReplicaSet", Group: "apps"}
+	configMap := &common.ResourceInfo{Kind: "ConfigMap"}
+
+	rule, ok := qs.findRule(deployment, replicaSet)
+	require.True(t, ok)
+	assert.Equal(t, core.RelationshipType("DEPLOYMENT_OWN_REPLICASET"), rule.Relationship)
+	// The rules are indexed once, later lookups only resolve the kinds of the nodes
+	p.lookups = 0
+	rule, ok = qs.findRule(replicaSet, deployment)
+	require.True(t, ok)
+	assert.Equal(t, core.RelationshipType("DEPLOYMENT_OWN_REPLICASET"), rule.Relationship)
+	assert.Equal(t, 2, p.lookups)
+
+	_, ok = qs.findRule(deployment, configMap)
+	assert.False(t, ok)
+	// A rule added later is indexed on the next lookup
+	rules.AddRelationshipRule(core.RelationshipRule{KindA: "configmaps", KindB: "deployments", Relationship: "DEPLOYMENT_VOLUME_CONFIGMAP"})
+	rule, ok = qs.findRule(deployment, configMap)
+	require.True(t, ok)
+	assert.Equal(t, core.RelationshipType("DEPLOYMENT_VOLUME_CONFIGMAP"), rule.Relationship)
+}
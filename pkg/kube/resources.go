@@ -0,0 +1,253 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	argocdcommon "github.com/argoproj/argo-cd/v3/common"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
+)
+
+// legacyDiscovery is implemented by the discovery clients which can be restricted to the legacy
+// discovery format.
+type legacyDiscovery interface {
+	WithLegacy() discovery.DiscoveryInterface
+}
+
+// PreferredResources returns the preferred version of each resource served by the cluster of the
+// given discovery client. The resources of all the groups are fetched in two requests with
+// aggregated discovery, which servers support since Kubernetes 1.26, and with one request per
+// group version from older servers. If the aggregated discovery fails as a whole, the legacy
+// discovery is tried. The error returned along with a partial result is a group discovery
+// failure.
+func PreferredResources(discoveryClient discovery.DiscoveryInterface) ([]*metav1.APIResourceList, error) {
+	resourceLists, err := discoveryClient.ServerPreferredResources()
+	if err == nil || discovery.IsGroupDiscoveryFailedError(err) {
+		return resourceLists, err
+	}
+	legacyClient, ok := discoveryClient.(legacyDiscovery)
+	if !ok {
+		return nil, err
+	}
+	log.WithError(err).Warn("Aggregated discovery failed, falling back to legacy discovery")
+	return legacyClient.WithLegacy().ServerPreferredResources()
+}
+
+// KindScopes returns, for each kind served by the cluster, whether its resources are namespaced.
+// The kinds of the groups which could not be discovered are left out.
+func KindScopes(restConfig *rest.Config) (map[schema.GroupKind]bool, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	resourceLists, err := PreferredResources(discoveryClient)
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("failed to discover resources: %w", err)
+	}
+	if err != nil {
+		log.Warnf("Partial success when performing preferred resource discovery: %v", err)
+	}
+	scopes := make(map[schema.GroupKind]bool)
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			// The kind of a subresource, such as the Scale of deployments/scale, is not a kind of the
+			// objects of the group
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+			scopes[schema.GroupKind{Group: gv.Group, Kind: resource.Kind}] = resource.Namespaced
+		}
+	}
+	return scopes, nil
+}
+
+// ServedKinds returns the kinds served by the cluster of the given discovery client which can be
+// listed and watched, by group. Those are the kinds a wildcard of resource.inclusions makes the
+// Argo CD application controller watch. The groups which could not be discovered are left out.
+func ServedKinds(discoveryClient discovery.DiscoveryInterface) (common.GroupedResourceKinds, error) {
+	resourceLists, err := PreferredResources(discoveryClient)
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("failed to discover resources: %w", err)
+	}
+	if err != nil {
+		log.Warnf("Partial success when performing preferred resource discovery: %v", err)
+	}
+	served := make(common.GroupedResourceKinds)
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			if strings.Contains(resource.Name, "/") || !slices.Contains(resource.Verbs, "list") || !slices.Contains(resource.Verbs, "watch") {
+				continue
+			}
+			served.MergeResourceInfos([]*common.ResourceInfo{{Group: gv.Group, Kind: resource.Kind}})
+		}
+	}
+	return served, nil
+}
+
+// isListableNamespaced returns true if the given resource is a namespaced resource which can be
+// listed. The subresources are left out, as the discovery clients which do not leave them out
+// list them with the kind they return, such as the Scale of deployments/scale.
+func isListableNamespaced(resource metav1.APIResource) bool {
+	return !strings.Contains(resource.Name, "/") && resource.Namespaced && slices.Contains(resource.Verbs, "list")
+}
+
+// NamespaceKinds returns the namespaced kinds which have at least one object in the given
+// namespace. The subresources and the resources which cannot be listed are left out.
+func NamespaceKinds(ctx context.Context, restConfig *rest.Config, namespace string) ([]schema.GroupKind, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	metadataClient, err := metadata.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metadata client: %w", err)
+	}
+	resourceLists, err := PreferredResources(discoveryClient)
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("failed to discover resources: %w", err)
+	}
+	var kinds []schema.GroupKind
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			if !isListableNamespaced(resource) {
+				continue
+			}
+			list, err := metadataClient.Resource(gv.WithResource(resource.Name)).Namespace(namespace).List(ctx, metav1.ListOptions{Limit: 1})
+			if err != nil {
+				log.Debugf("Skipping resource %s/%s in namespace %s: %v", gv, resource.Name, namespace, err)
+				continue
+			}
+			if len(list.Items) > 0 {
+				kinds = append(kinds, schema.GroupKind{Group: gv.Group, Kind: resource.Kind})
+			}
+		}
+	}
+	return kinds, nil
+}
+
+// trackedResourcesPageSize is the number of objects requested per page when listing the tracked
+// objects of a cluster.
+const trackedResourcesPageSize = 500
+
+// TrackedResources holds the objects of a cluster carrying the tracking label or, if annotation is
+// true, the tracking annotation of an application, indexed by application instance name. The
+// cluster is listed once, on the first lookup, and the result is shared by the applications
+// deployed to it. Annotations cannot be selected on, so tracking by annotation lists every object
+// of the cluster and filters on the annotation in memory.
+type TrackedResources struct {
+	restConfig *rest.Config
+	annotation bool
+	listed     bool
+	byInstance map[string][]*common.ResourceInfo
+	err        error
+}
+
+// NewTrackedResources returns the tracked objects of the cluster of the given rest config, listed
+// on the first lookup.
+func NewTrackedResources(restConfig *rest.Config, annotation bool) *TrackedResources {
+	return &TrackedResources{restConfig: restConfig, annotation: annotation}
+}
+
+// Get returns the objects tracked to the given application instance.
+func (t *TrackedResources) Get(ctx context.Context, instanceName string) ([]*common.ResourceInfo, error) {
+	if !t.listed {
+		t.byInstance, t.err = t.list(ctx)
+		t.listed = true
+	}
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t.byInstance[instanceName], nil
+}
+
+// list lists the tracked objects of every listable resource of the cluster, page by page.
+func (t *TrackedResources) list(ctx context.Context) (map[string][]*common.ResourceInfo, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(t.restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	metadataClient, err := metadata.NewForConfig(t.restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metadata client: %w", err)
+	}
+	resourceLists, err := PreferredResources(discoveryClient)
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("failed to discover resources: %w", err)
+	}
+	// Only the objects carrying the tracking label are listed when tracking by label
+	labelSelector := argocdcommon.LabelKeyAppInstance
+	if t.annotation {
+		labelSelector = ""
+	}
+	byInstance := make(map[string][]*common.ResourceInfo)
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			if strings.Contains(resource.Name, "/") || !slices.Contains(resource.Verbs, "list") {
+				continue
+			}
+			listOptions := metav1.ListOptions{LabelSelector: labelSelector, Limit: trackedResourcesPageSize}
+			for {
+				list, err := metadataClient.Resource(gv.WithResource(resource.Name)).List(ctx, listOptions)
+				if err != nil {
+					log.Debugf("Skipping resource %s/%s: %v", gv, resource.Name, err)
+					break
+				}
+				for _, item := range list.Items {
+					instanceName := t.instanceName(&item)
+					if instanceName == "" {
+						continue
+					}
+					byInstance[instanceName] = append(byInstance[instanceName], &common.ResourceInfo{
+						Group:     gv.Group,
+						Kind:      resource.Kind,
+						Name:      item.GetName(),
+						Namespace: item.GetNamespace(),
+					})
+				}
+				if list.GetContinue() == "" {
+					break
+				}
+				listOptions.Continue = list.GetContinue()
+			}
+		}
+	}
+	return byInstance, nil
+}
+
+// instanceName returns the application instance the given object is tracked to, from the text
+// before the first colon of its tracking annotation or from its tracking label.
+func (t *TrackedResources) instanceName(item *metav1.PartialObjectMetadata) string {
+	if !t.annotation {
+		return item.GetLabels()[argocdcommon.LabelKeyAppInstance]
+	}
+	trackingID := item.GetAnnotations()[argocdcommon.AnnotationKeyAppInstance]
+	instanceName, _, found := strings.Cut(trackingID, ":")
+	if !found {
+		return ""
+	}
+	return instanceName
+}
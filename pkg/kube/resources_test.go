@@ -0,0 +1,179 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/anandf/resource-tracker/pkg/common"
+	argocdcommon "github.com/argoproj/argo-cd/v3/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apidiscoveryv2 "k8s.io/api/apidiscovery/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+// discoveryServer serves the aggregated discovery documents of a cluster serving deployments,
+// unless aggregated is false, and the legacy discovery documents. It records the requested paths.
+type discoveryServer struct {
+	aggregated bool
+	mu         sync.Mutex
+	paths      []string
+}
+
+func (s *discoveryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.paths = append(s.paths, r.URL.Path)
+	s.mu.Unlock()
+	deployments := metav1.APIResource{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: metav1.Verbs{"list", "watch"}}
+	// A subresource, left out by the discovery client
+	scale := metav1.APIResource{Name: "deployments/scale", Group: "autoscaling", Version: "v1", Kind: "Scale", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "update"}}
+	var body interface{}
+	contentType := "application/json"
+	switch {
+	case strings.Contains(r.Header.Get("Accept"), "apidiscovery.k8s.io"):
+		if !s.aggregated {
+			http.Error(w, "aggregated discovery unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		contentType = "application/json;g=apidiscovery.k8s.io;v=v2;as=APIGroupDiscoveryList"
+		list := apidiscoveryv2.APIGroupDiscoveryList{TypeMeta: metav1.TypeMeta{Kind: "APIGroupDiscoveryList", APIVersion: "apidiscovery.k8s.io/v2"}}
+		if r.URL.Path == "/apis" {
+			list.Items = []apidiscoveryv2.APIGroupDiscovery{{
+				ObjectMeta: metav1.ObjectMeta{Name: "apps"},
+				Versions: []apidiscoveryv2.APIVersionDiscovery{{
+					Version: "v1",
+					Resources: []apidiscoveryv2.APIResourceDiscovery{{
+						Resource:         "deployments",
+						ResponseKind:     &metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+						Scope:            apidiscoveryv2.ScopeNamespace,
+						Verbs:            []string{"list", "watch"},
+						SingularResource: "deployment",
+					}},
+				}},
+			}}
+		}
+		body = list
+	case r.URL.Path == "/api":
+		body = metav1.APIVersions{}
+	case r.URL.Path == "/apis":
+		body = metav1.APIGroupList{Groups: []metav1.APIGroup{{
+			Name:             "apps",
+			Versions:         []metav1.GroupVersionForDiscovery{{GroupVersion: "apps/v1", Version: "v1"}},
+			PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "apps/v1", Version: "v1"},
+		}}}
+	case r.URL.Path == "/apis/apps/v1":
+		body = metav1.APIResourceList{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{deployments, scale}}
+	case strings.HasPrefix(r.URL.Path, "/apis/apps/v1/namespaces/guestbook/"):
+		body = metav1.PartialObjectMetadataList{
+			TypeMeta: metav1.TypeMeta{Kind: "PartialObjectMetadataList", APIVersion: "meta.k8s.io/v1"},
+			Items: []metav1.PartialObjectMetadata{{
+				TypeMeta:   metav1.TypeMeta{Kind: "PartialObjectMetadata", APIVersion: "meta.k8s.io/v1"},
+				ObjectMeta: metav1.ObjectMeta{Name: "guestbook-ui", Namespace: "guestbook"},
+			}},
+		}
+	case r.URL.Path == "/apis/apps/v1/deployments":
+		// Two pages of deployments, tracked to guestbook by annotation, to another instance and
+		// not tracked
+		items := []metav1.PartialObjectMetadata{
+			{ObjectMeta: metav1.ObjectMeta{Name: "guestbook-ui", Namespace: "guestbook", Annotations: map[string]string{argocdcommon.AnnotationKeyAppInstance: "guestbook:apps/Deployment:guestbook/guestbook-ui"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "guestbook", Annotations: map[string]string{argocdcommon.AnnotationKeyAppInstance: "guestbook-other:apps/Deployment:guestbook/other"}}},
+		}
+		list := metav1.PartialObjectMetadataList{
+			TypeMeta: metav1.TypeMeta{Kind: "PartialObjectMetadataList", APIVersion: "meta.k8s.io/v1"},
+		}
+		if r.URL.Query().Get("continue") == "" {
+			list.Items = items
+			list.Continue = "page2"
+		} else {
+			list.Items = []metav1.PartialObjectMetadata{
+				{ObjectMeta: metav1.ObjectMeta{Name: "redis", Namespace: "guestbook", Annotations: map[string]string{argocdcommon.AnnotationKeyAppInstance: "guestbook:apps/Deployment:guestbook/redis"}}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "untracked", Namespace: "guestbook"}},
+			}
+		}
+		body = list
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func Test_PreferredResources(t *testing.T) {
+	for _, aggregated := range []bool{true, false} {
+		server := &discoveryServer{aggregated: aggregated}
+		ts := httptest.NewServer(server)
+		discoveryClient, err := discovery.NewDiscoveryClientForConfig(&rest.Config{Host: ts.URL})
+		require.NoError(t, err)
+
+		resourceLists, err := PreferredResources(discoveryClient)
+		ts.Close()
+		require.NoError(t, err)
+		require.Len(t, resourceLists, 1)
+		assert.Equal(t, "apps/v1", resourceLists[0].GroupVersion)
+		assert.Equal(t, "Deployment", resourceLists[0].APIResources[0].Kind)
+		if aggregated {
+			// The resources of every group come with the group list
+			assert.ElementsMatch(t, []string{"/api", "/apis"}, server.paths)
+		} else {
+			assert.Contains(t, server.paths, "/apis/apps/v1")
+		}
+	}
+}
+
+func Test_NamespaceKinds(t *testing.T) {
+	ts := httptest.NewServer(&discoveryServer{})
+	defer ts.Close()
+
+	kinds, err := NamespaceKinds(context.Background(), &rest.Config{Host: ts.URL}, "guestbook")
+	require.NoError(t, err)
+	assert.Equal(t, []schema.GroupKind{{Group: "apps", Kind: "Deployment"}}, kinds)
+
+	scopes, err := KindScopes(&rest.Config{Host: ts.URL})
+	require.NoError(t, err)
+	assert.Equal(t, map[schema.GroupKind]bool{{Group: "apps", Kind: "Deployment"}: true}, scopes)
+}
+
+func Test_TrackedResources(t *testing.T) {
+	server := &discoveryServer{aggregated: true}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	trackedResources := NewTrackedResources(&rest.Config{Host: ts.URL}, true)
+	resources, err := trackedResources.Get(context.Background(), "guestbook")
+	require.NoError(t, err)
+	assert.Equal(t, []*common.ResourceInfo{
+		{Group: "apps", Kind: "Deployment", Name: "guestbook-ui", Namespace: "guestbook"},
+		{Group: "apps", Kind: "Deployment", Name: "redis", Namespace: "guestbook"},
+	}, resources)
+	resources, err = trackedResources.Get(context.Background(), "guestbook-other")
+	require.NoError(t, err)
+	assert.Equal(t, []*common.ResourceInfo{{Group: "apps", Kind: "Deployment", Name: "other", Namespace: "guestbook"}}, resources)
+	resources, err = trackedResources.Get(context.Background(), "missing")
+	require.NoError(t, err)
+	assert.Empty(t, resources)
+
+	// The cluster is listed once, in two pages, for every instance
+	var lists int
+	for _, path := range server.paths {
+		if path == "/apis/apps/v1/deployments" {
+			lists++
+		}
+	}
+	assert.Equal(t, 2, lists)
+}
+
+func Test_isListableNamespaced(t *testing.T) {
+	assert.True(t, isListableNamespaced(metav1.APIResource{Name: "deployments", Namespaced: true, Verbs: metav1.Verbs{"list"}}))
+	assert.False(t, isListableNamespaced(metav1.APIResource{Name: "deployments/scale", Kind: "Scale", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}}))
+	assert.False(t, isListableNamespaced(metav1.APIResource{Name: "clusterroles", Verbs: metav1.Verbs{"list"}}))
+	assert.False(t, isListableNamespaced(metav1.APIResource{Name: "bindings", Namespaced: true, Verbs: metav1.Verbs{"create"}}))
+}
@@ -0,0 +1,298 @@
+package repo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/argo-cd/v3/reposerver/apiclient"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultManifestCacheMaxAge is the age after which the manifests cached on disk are
+	// generated again
+	DefaultManifestCacheMaxAge = 7 * 24 * time.Hour
+	// DefaultManifestCacheMaxSize is the size in bytes above which the oldest manifests cached on
+	// disk are removed
+	DefaultManifestCacheMaxSize = 512 * 1024 * 1024
+)
+
+// manifestCache caches the manifests generated by the repo-server by cache key, in memory and, if
+// dir is set, on disk so that they are kept across runs. The manifests cached on disk are kept for
+// maxAge, and the oldest are removed once they take more than maxSize bytes.
+type manifestCache struct {
+	dir     string
+	maxAge  time.Duration
+	maxSize int64
+	mu      sync.Mutex
+	entries map[string][]string
+	// group makes concurrent requests for the same key generate the manifests once
+	group singleflight.Group
+}
+
+func newManifestCache(dir string) *manifestCache {
+	return &manifestCache{
+		dir:     dir,
+		maxAge:  DefaultManifestCacheMaxAge,
+		maxSize: DefaultManifestCacheMaxSize,
+		entries: make(map[string][]string),
+	}
+}
+
+// get returns the manifests cached under the given key, or generates and caches them. The
+// manifests are generated with a context which is not canceled with ctx, as the callers requesting
+// the same key wait for them too, while get returns as soon as ctx is done.
+func (c *manifestCache) get(ctx context.Context, key string, generate func(ctx context.Context) ([]string, error)) ([]string, error) {
+	c.mu.Lock()
+	manifests, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		log.Debugf("Using manifests cached in memory for key %s", key)
+		return manifests, nil
+	}
+	generateCtx := context.WithoutCancel(ctx)
+	results := c.group.DoChan(key, func() (any, error) {
+		if manifests, ok := c.read(key); ok {
+			log.Debugf("Using manifests cached on disk for key %s", key)
+			c.store(key, manifests, false)
+			return manifests, nil
+		}
+		manifests, err := generate(generateCtx)
+		if err != nil {
+			return nil, err
+		}
+		c.store(key, manifests, true)
+		return manifests, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.([]string), nil
+	}
+}
+
+// store caches the given manifests in memory, and on disk if write is true.
+func (c *manifestCache) store(key string, manifests []string, write bool) {
+	c.mu.Lock()
+	c.entries[key] = manifests
+	c.mu.Unlock()
+	if !write || c.dir == "" {
+		return
+	}
+	if err := c.write(key, manifests); err != nil {
+		log.WithError(err).Warnf("Error caching manifests on disk for key %s", key)
+	}
+	if err := c.prune(); err != nil {
+		log.WithError(err).Warn("Error removing the manifests cached on disk which are too old or too large")
+	}
+}
+
+// read returns the manifests cached on disk under the given key, if any and younger than maxAge.
+func (c *manifestCache) read(key string) ([]string, bool) {
+	if c.dir == "" {
+		return nil, false
+	}
+	if info, err := os.Stat(c.path(key)); err == nil && c.maxAge > 0 && time.Since(info.ModTime()) > c.maxAge {
+		log.Debugf("Manifests cached on disk for key %s are older than %s", key, c.maxAge)
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithError(err).Warnf("Error reading manifests cached on disk for key %s", key)
+		}
+		return nil, false
+	}
+	var manifests []string
+	if err := json.Unmarshal(data, &manifests); err != nil {
+		log.WithError(err).Warnf("Ignoring invalid manifests cached on disk for key %s", key)
+		return nil, false
+	}
+	return manifests, true
+}
+
+// write caches the given manifests on disk under the given key. The file is renamed into place so
+// that a concurrent reader never sees it partially written.
+func (c *manifestCache) write(key string, manifests []string) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(manifests)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// prune removes the manifests cached on disk which are older than maxAge, then the oldest ones
+// until they take at most maxSize bytes.
+func (c *manifestCache) prune() error {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	files := make([]os.FileInfo, 0, len(dirEntries))
+	var size int64
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !strings.HasSuffix(dirEntry.Name(), ".json") {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		if c.maxAge > 0 && time.Since(info.ModTime()) > c.maxAge {
+			if err := os.Remove(filepath.Join(c.dir, info.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		files = append(files, info)
+		size += info.Size()
+	}
+	if c.maxSize <= 0 || size <= c.maxSize {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, file := range files {
+		if size <= c.maxSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, file.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		size -= file.Size()
+	}
+	return nil
+}
+
+func (c *manifestCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// manifestCacheKeyFields are the fields of a manifest request which change the generated manifests.
+type manifestCacheKeyFields struct {
+	RepoURL            string
+	Revision           string
+	Source             appsv1alpha1.ApplicationSource
+	RefSources         map[string]refSourceKeyFields
+	AppName            string
+	Namespace          string
+	ProjectName        string
+	KubeVersion        string
+	ApiVersions        []string
+	EnabledSourceTypes map[string]bool
+	KustomizeOptions   *appsv1alpha1.KustomizeOptions
+	HelmOptions        *appsv1alpha1.HelmOptions
+}
+
+// refSourceKeyFields are the fields of a ref source which change the generated manifests.
+type refSourceKeyFields struct {
+	RepoURL  string
+	Revision string
+	Chart    string
+}
+
+// manifestCacheKey returns the key of the manifests generated for the given request, whose
+// revision and ref sources are resolved to commit SHAs or chart versions. The credentials and the
+// fields which only change the tracking metadata are left out, as are the name and namespace of
+// the application for the directory and Kustomize sources, so that the applications sharing a
+// source and its parameters share their manifests, unless the source refers to the build
+// environment variables naming the application. A Helm release is named after the application
+// unless its release name is set.
+func manifestCacheKey(req *apiclient.ManifestRequest) (string, error) {
+	fields := manifestCacheKeyFields{
+		Revision:           req.Revision,
+		RefSources:         make(map[string]refSourceKeyFields, len(req.RefSources)),
+		KubeVersion:        req.KubeVersion,
+		ApiVersions:        req.ApiVersions,
+		EnabledSourceTypes: req.EnabledSourceTypes,
+		KustomizeOptions:   req.KustomizeOptions,
+		HelmOptions:        req.HelmOptions,
+	}
+	if req.Repo != nil {
+		fields.RepoURL = req.Repo.Repo
+	}
+	for ref, target := range req.RefSources {
+		fields.RefSources[ref] = refSourceKeyFields{RepoURL: target.Repo.Repo, Revision: target.TargetRevision, Chart: target.Chart}
+	}
+	if req.ApplicationSource != nil {
+		fields.Source = *req.ApplicationSource.DeepCopy()
+		fields.Source.TargetRevision = ""
+	}
+	sourceType, err := fields.Source.ExplicitType()
+	if err != nil {
+		return "", err
+	}
+	sharedAcrossApps := sourceType != nil &&
+		(*sourceType == appsv1alpha1.ApplicationSourceTypeDirectory || *sourceType == appsv1alpha1.ApplicationSourceTypeKustomize)
+	if !sharedAcrossApps || usesAppBuildEnv(&fields.Source) {
+		fields.AppName = req.AppName
+		fields.Namespace = req.Namespace
+		fields.ProjectName = req.ProjectName
+		if fields.Source.Helm != nil && fields.Source.Helm.ReleaseName != "" && !usesAppBuildEnv(&fields.Source) {
+			fields.AppName = ""
+		}
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling manifest cache key: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// localManifestCacheKey returns the key of the manifests rendered from a local checkout for the
+// request of the given key.
+func localManifestCacheKey(key string) string {
+	return "local-" + key
+}
+
+// appBuildEnv are the build environment variables of Argo CD which identify the application the
+// manifests are generated for.
+var appBuildEnv = []string{"ARGOCD_APP_NAME", "ARGOCD_APP_NAMESPACE", "ARGOCD_APP_PROJECT_NAME"}
+
+// usesAppBuildEnv returns true if the parameters of the given source refer to a build environment
+// variable identifying the application, such as the Jsonnet variables, the Helm parameters and
+// the Kustomize common annotations substituted by Argo CD.
+func usesAppBuildEnv(source *appsv1alpha1.ApplicationSource) bool {
+	data, err := json.Marshal(source)
+	if err != nil {
+		return true
+	}
+	for _, name := range appBuildEnv {
+		if strings.Contains(string(data), name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package repo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	appsv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/argo-cd/v3/reposerver/apiclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_manifestCacheKey(t *testing.T) {
+	request := func(appName string, source appsv1alpha1.ApplicationSource) *apiclient.ManifestRequest {
+		return &apiclient.ManifestRequest{
+			Repo:              &appsv1alpha1.Repository{Repo: "https://github.com/argoproj/argocd-example-apps", Password: appName},
+			Revision:          "53e28ff20cc530b9ada2173fbbd64d48338583ba",
+			AppName:           appName,
+			Namespace:         appName,
+			ApplicationSource: &source,
+		}
+	}
+	key := func(req *apiclient.ManifestRequest) string {
+		k, err := manifestCacheKey(req)
+		require.NoError(t, err)
+		return k
+	}
+
+	kustomize := appsv1alpha1.ApplicationSource{Path: "kustomize-guestbook", TargetRevision: "HEAD", Kustomize: &appsv1alpha1.ApplicationSourceKustomize{}}
+	assert.Equal(t, key(request("dev", kustomize)), key(request("prod", kustomize)))
+
+	// The name and namespace of the application are part of a Helm release
+	helm := appsv1alpha1.ApplicationSource{Path: "helm-guestbook", TargetRevision: "HEAD", Helm: &appsv1alpha1.ApplicationSourceHelm{}}
+	assert.NotEqual(t, key(request("dev", helm)), key(request("prod", helm)))
+
+	// Other parameters of the source change the manifests
+	prodKustomize := kustomize.DeepCopy()
+	prodKustomize.Kustomize.NamePrefix = "prod-"
+	assert.NotEqual(t, key(request("dev", kustomize)), key(request("dev", *prodKustomize)))
+	other := request("dev", kustomize)
+	other.Revision = "c0ffee0000000000000000000000000000000000"
+	assert.NotEqual(t, key(request("dev", kustomize)), key(other))
+
+	// A source referring to the build environment variables naming the application is rendered
+	// for each application
+	envsubst := kustomize.DeepCopy()
+	envsubst.Kustomize.CommonAnnotations = map[string]string{"app": "${ARGOCD_APP_NAME}"}
+	envsubst.Kustomize.CommonAnnotationsEnvsubst = true
+	assert.NotEqual(t, key(request("dev", *envsubst)), key(request("prod", *envsubst)))
+	jsonnet := appsv1alpha1.ApplicationSource{Path: "jsonnet-guestbook", Directory: &appsv1alpha1.ApplicationSourceDirectory{
+		Jsonnet: appsv1alpha1.ApplicationSourceJsonnet{ExtVars: []appsv1alpha1.JsonnetVar{{Name: "namespace", Value: "$ARGOCD_APP_NAMESPACE"}}},
+	}}
+	assert.NotEqual(t, key(request("dev", jsonnet)), key(request("prod", jsonnet)))
+	releaseName := helm.DeepCopy()
+	releaseName.Helm.ReleaseName = "guestbook"
+	renamed := request("dev", *releaseName)
+	renamed.AppName = "guestbook-dev"
+	assert.Equal(t, key(request("dev", *releaseName)), key(renamed))
+	releaseName.Helm.Parameters = []appsv1alpha1.HelmParameter{{Name: "app", Value: "$ARGOCD_APP_NAME"}}
+	renamed = request("dev", *releaseName)
+	renamed.AppName = "guestbook-dev"
+	assert.NotEqual(t, key(request("dev", *releaseName)), key(renamed))
+}
+
+func Test_manifestCache(t *testing.T) {
+	dir := t.TempDir()
+	generated := 0
+	generate := func(ctx context.Context) ([]string, error) {
+		generated++
+		return []string{`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"guestbook"}}`}, nil
+	}
+
+	cache := newManifestCache(dir)
+	manifests, err := cache.get(context.Background(), "key", generate)
+	require.NoError(t, err)
+	cached, err := cache.get(context.Background(), "key", generate)
+	require.NoError(t, err)
+	assert.Equal(t, manifests, cached)
+	assert.Equal(t, 1, generated)
+
+	// The manifests are kept on disk across runs
+	cached, err = newManifestCache(dir).get(context.Background(), "key", generate)
+	require.NoError(t, err)
+	assert.Equal(t, manifests, cached)
+	assert.Equal(t, 1, generated)
+}
+
+func Test_manifestCache_canceled(t *testing.T) {
+	cache := newManifestCache("")
+	started := make(chan struct{})
+	release := make(chan struct{})
+	generate := func(ctx context.Context) ([]string, error) {
+		close(started)
+		<-release
+		// The generation is not canceled with the caller which started it
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return []string{"manifest"}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error)
+	go func() {
+		_, err := cache.get(ctx, "key", generate)
+		canceled <- err
+	}()
+	<-started
+	waiting := make(chan []string)
+	go func() {
+		manifests, err := cache.get(context.Background(), "key", generate)
+		assert.NoError(t, err)
+		waiting <- manifests
+	}()
+	cancel()
+	assert.ErrorIs(t, <-canceled, context.Canceled)
+	close(release)
+	assert.Equal(t, []string{"manifest"}, <-waiting)
+}
+
+func Test_manifestCache_prune(t *testing.T) {
+	dir := t.TempDir()
+	cache := newManifestCache(dir)
+	cache.maxAge = time.Hour
+	writeEntry := func(key string, age time.Duration) {
+		require.NoError(t, cache.write(key, []string{"manifest"}))
+		modTime := time.Now().Add(-age)
+		require.NoError(t, os.Chtimes(cache.path(key), modTime, modTime))
+	}
+	exists := func(key string) bool {
+		_, err := os.Stat(cache.path(key))
+		return err == nil
+	}
+
+	// Manifests older than the maximum age are generated again and removed
+	writeEntry("old", 2*time.Hour)
+	_, ok := cache.read("old")
+	assert.False(t, ok)
+	writeEntry("older", 3*time.Hour)
+	writeEntry("recent", time.Minute)
+	require.NoError(t, cache.prune())
+	assert.False(t, exists("old"))
+	assert.False(t, exists("older"))
+	assert.True(t, exists("recent"))
+
+	// The oldest manifests are removed once the cache is too large
+	writeEntry("newest", 0)
+	info, err := os.Stat(cache.path("newest"))
+	require.NoError(t, err)
+	cache.maxSize = info.Size()
+	require.NoError(t, cache.prune())
+	assert.False(t, exists("recent"))
+	assert.True(t, exists("newest"))
+	entries, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,734 @@
+package repo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/argo-cd/v3/reposerver/apiclient"
+	"github.com/argoproj/argo-cd/v3/util/git"
+	"github.com/argoproj/argo-cd/v3/util/glob"
+	"github.com/argoproj/gitops-engine/pkg/utils/kube"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	log "github.com/sirupsen/logrus"
+	cryptossh "golang.org/x/crypto/ssh"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/strvals"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/krusty"
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// manifestFile matches the files of a directory source which are read as manifests.
+var manifestFile = regexp.MustCompile(`^.*\.(yaml|yml|json)$`)
+
+// kustomizationFiles are the file names marking a directory as a Kustomize source.
+var kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// LocalRenderer renders the manifests of application sources in-process, from local checkouts of
+// their git repositories, when the repo-server cannot be reached. Plain directory, Kustomize and
+// Helm sources stored in git are supported. Helm charts pulled from a Helm repository, Jsonnet
+// files and config management plugins are not.
+type LocalRenderer struct {
+	checkoutDir string
+	// mu serializes the renders, as they fetch the clones shared by the checkouts of a repository
+	mu sync.Mutex
+}
+
+// maxCheckoutsPerRepository is the number of the most recently used revisions of a repository
+// which are kept checked out.
+const maxCheckoutsPerRepository = 4
+
+// NewLocalRenderer returns a LocalRenderer keeping a clone of each repository in checkoutDir,
+// along with a checkout of each of its recently used revisions.
+func NewLocalRenderer(checkoutDir string) *LocalRenderer {
+	return &LocalRenderer{checkoutDir: checkoutDir}
+}
+
+// GenerateManifests renders the manifests of the source of the given request, the way the
+// repo-server would, and returns them as JSON.
+func (l *LocalRenderer) GenerateManifests(ctx context.Context, req *apiclient.ManifestRequest) ([]string, error) {
+	source := req.ApplicationSource
+	if source == nil || req.Repo == nil {
+		return nil, fmt.Errorf("manifest request has no source")
+	}
+	if source.IsHelm() {
+		return nil, fmt.Errorf("rendering Helm chart %s from repository %s locally is not supported", source.Chart, source.RepoURL)
+	}
+	if source.IsRef() {
+		return nil, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Each revision of a repository is checked out in its own directory, so the ref sources may
+	// refer to the repository of the rendered source at another revision
+	refPaths := make(map[string]string, len(req.RefSources))
+	for ref, target := range req.RefSources {
+		if target.Chart != "" {
+			continue
+		}
+		root, err := l.checkout(ctx, &target.Repo, target.TargetRevision)
+		if err != nil {
+			return nil, fmt.Errorf("error checking out ref source %s: %w", ref, err)
+		}
+		refPaths[ref] = root
+	}
+	root, err := l.checkout(ctx, req.Repo, req.Revision)
+	if err != nil {
+		return nil, err
+	}
+	appPath, err := inboundPath(root, source.Path)
+	if err != nil {
+		return nil, err
+	}
+	sourceType, err := localSourceType(source, appPath)
+	if err != nil {
+		return nil, err
+	}
+	var objs []*unstructured.Unstructured
+	switch sourceType {
+	case appsv1alpha1.ApplicationSourceTypeDirectory:
+		objs, err = renderDirectory(appPath, source.Directory)
+	case appsv1alpha1.ApplicationSourceTypeKustomize:
+		objs, err = renderKustomize(root, appPath, source.Kustomize, req.KustomizeOptions)
+	case appsv1alpha1.ApplicationSourceTypeHelm:
+		objs, err = renderHelm(root, appPath, refPaths, req)
+	default:
+		err = fmt.Errorf("rendering %s sources locally is not supported", sourceType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error rendering %s source %s of repository %s: %w", sourceType, source.Path, source.RepoURL, err)
+	}
+	manifests := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		data, err := json.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, string(data))
+	}
+	return manifests, nil
+}
+
+// checkout clones the given repository, or fetches it if it was already cloned, and returns the
+// path of the checkout of the commit of the given revision. The checkouts of a commit are reused,
+// and the least recently used checkouts of the repository are removed.
+func (l *LocalRenderer) checkout(ctx context.Context, repo *appsv1alpha1.Repository, revision string) (string, error) {
+	auth, err := gitAuth(repo)
+	if err != nil {
+		return "", err
+	}
+	cloneDir := filepath.Join(l.checkoutDir, checkoutName(repo.Repo))
+	r, err := gogit.PlainOpen(cloneDir)
+	switch {
+	case errors.Is(err, gogit.ErrRepositoryNotExists):
+		log.Debugf("Cloning repository %s into %s", repo.Repo, cloneDir)
+		r, err = gogit.PlainCloneContext(ctx, cloneDir, false, &gogit.CloneOptions{
+			URL:             repo.Repo,
+			Auth:            auth,
+			NoCheckout:      true,
+			InsecureSkipTLS: repo.IsInsecure(),
+		})
+		if err != nil {
+			return "", fmt.Errorf("error cloning repository %s: %w", repo.Repo, err)
+		}
+	case err != nil:
+		return "", fmt.Errorf("error opening clone of repository %s: %w", repo.Repo, err)
+	default:
+		log.Debugf("Fetching repository %s into %s", repo.Repo, cloneDir)
+		err = r.FetchContext(ctx, &gogit.FetchOptions{
+			Auth:            auth,
+			Tags:            gogit.AllTags,
+			Force:           true,
+			InsecureSkipTLS: repo.IsInsecure(),
+		})
+		if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+			return "", fmt.Errorf("error fetching repository %s: %w", repo.Repo, err)
+		}
+	}
+	hash, err := resolveLocalRevision(ctx, r, auth, revision)
+	if err != nil {
+		return "", fmt.Errorf("error resolving revision %q of repository %s: %w", revision, repo.Repo, err)
+	}
+	dir := cloneDir + "-" + hash.String()
+	if _, err := os.Stat(dir); err == nil {
+		// The checkout is marked as used
+		now := time.Now()
+		if err := os.Chtimes(dir, now, now); err != nil {
+			return "", err
+		}
+		return dir, nil
+	}
+	commit, err := r.CommitObject(*hash)
+	if err != nil {
+		return "", fmt.Errorf("error reading commit %s of repository %s: %w", hash, repo.Repo, err)
+	}
+	// The commit is written to a temporary directory renamed into place, so that an interrupted
+	// checkout is never reused
+	tmp, err := os.MkdirTemp(l.checkoutDir, checkoutName(repo.Repo)+".tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := writeCommitFiles(commit, tmp); err != nil {
+		return "", fmt.Errorf("error checking out revision %s of repository %s: %w", hash, repo.Repo, err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return "", err
+	}
+	if err := removeOldCheckouts(cloneDir); err != nil {
+		log.WithError(err).Warnf("Error removing the old checkouts of repository %s", repo.Repo)
+	}
+	return dir, nil
+}
+
+// writeCommitFiles writes the files of the given commit to dir.
+func writeCommitFiles(commit *object.Commit, dir string) error {
+	files, err := commit.Files()
+	if err != nil {
+		return err
+	}
+	return files.ForEach(func(file *object.File) error {
+		path := filepath.Join(dir, filepath.FromSlash(file.Name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if file.Mode == filemode.Symlink {
+			target, err := file.Contents()
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, path)
+		}
+		perm := os.FileMode(0o644)
+		if file.Mode == filemode.Executable {
+			perm = 0o755
+		}
+		reader, err := file.Reader()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, reader); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+// removeOldCheckouts removes the checkouts of the repository cloned in cloneDir, except the
+// maxCheckoutsPerRepository most recently used ones.
+func removeOldCheckouts(cloneDir string) error {
+	paths, err := filepath.Glob(cloneDir + "-*")
+	if err != nil {
+		return err
+	}
+	if len(paths) <= maxCheckoutsPerRepository {
+		return nil
+	}
+	modTimes := make(map[string]time.Time, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		modTimes[path] = info.ModTime()
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return modTimes[paths[i]].After(modTimes[paths[j]])
+	})
+	for _, path := range paths[maxCheckoutsPerRepository:] {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkoutName returns the name of the directory of the clone of the given repository.
+func checkoutName(repoURL string) string {
+	sum := sha256.Sum256([]byte(git.NormalizeGitURL(repoURL)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// gitAuth returns the credentials of the given repository. Without credentials, SSH repositories
+// are authenticated by the SSH agent.
+func gitAuth(repo *appsv1alpha1.Repository) (transport.AuthMethod, error) {
+	if repo.SSHPrivateKey != "" {
+		user := "git"
+		if endpoint, err := transport.NewEndpoint(repo.Repo); err == nil && endpoint.User != "" {
+			user = endpoint.User
+		}
+		keys, err := gitssh.NewPublicKeys(user, []byte(repo.SSHPrivateKey), "")
+		if err != nil {
+			return nil, fmt.Errorf("error parsing SSH private key of repository %s: %w", repo.Repo, err)
+		}
+		if repo.IsInsecure() {
+			keys.HostKeyCallback = cryptossh.InsecureIgnoreHostKey()
+		}
+		return keys, nil
+	}
+	if repo.Password != "" {
+		username := repo.Username
+		if username == "" {
+			username = "x-access-token"
+		}
+		return &githttp.BasicAuth{Username: username, Password: repo.Password}, nil
+	}
+	return nil, nil
+}
+
+// resolveLocalRevision resolves the given revision, a branch, a tag or a commit SHA, to a commit
+// of the fetched repository. HEAD is the default branch of the remote.
+func resolveLocalRevision(ctx context.Context, r *gogit.Repository, auth transport.AuthMethod, revision string) (*plumbing.Hash, error) {
+	if revision == "" || revision == "HEAD" {
+		remote, err := r.Remote(gogit.DefaultRemoteName)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := remote.ListContext(ctx, &gogit.ListOptions{Auth: auth})
+		if err != nil {
+			return nil, err
+		}
+		target := plumbing.HEAD
+		for range 2 {
+			for _, ref := range refs {
+				if ref.Name() != target {
+					continue
+				}
+				if ref.Type() == plumbing.HashReference {
+					hash := ref.Hash()
+					return &hash, nil
+				}
+				target = ref.Target()
+			}
+		}
+		return nil, fmt.Errorf("remote has no HEAD")
+	}
+	for _, candidate := range []string{
+		plumbing.NewRemoteReferenceName(gogit.DefaultRemoteName, revision).String(),
+		plumbing.NewTagReferenceName(revision).String(),
+		revision,
+	} {
+		if hash, err := r.ResolveRevision(plumbing.Revision(candidate)); err == nil {
+			return hash, nil
+		}
+	}
+	return nil, plumbing.ErrReferenceNotFound
+}
+
+// inboundPath returns the given path relative to root, or an error if it points outside of root.
+func inboundPath(root, path string) (string, error) {
+	joined := filepath.Join(root, path)
+	if joined != root && !strings.HasPrefix(joined, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside of the repository", path)
+	}
+	return joined, nil
+}
+
+// localSourceType returns the explicit type of the given source, or the type detected from the
+// files of its path like the repo-server does.
+func localSourceType(source *appsv1alpha1.ApplicationSource, appPath string) (appsv1alpha1.ApplicationSourceType, error) {
+	explicitType, err := source.ExplicitType()
+	if err != nil {
+		return "", err
+	}
+	if explicitType != nil {
+		return *explicitType, nil
+	}
+	if _, err := os.Stat(filepath.Join(appPath, "Chart.yaml")); err == nil {
+		return appsv1alpha1.ApplicationSourceTypeHelm, nil
+	}
+	for _, name := range kustomizationFiles {
+		if _, err := os.Stat(filepath.Join(appPath, name)); err == nil {
+			return appsv1alpha1.ApplicationSourceTypeKustomize, nil
+		}
+	}
+	return appsv1alpha1.ApplicationSourceTypeDirectory, nil
+}
+
+// renderDirectory reads the YAML and JSON manifests of a directory source, applying its include
+// and exclude globs. Jsonnet files are skipped.
+func renderDirectory(appPath string, directory *appsv1alpha1.ApplicationSourceDirectory) ([]*unstructured.Unstructured, error) {
+	if directory == nil {
+		directory = &appsv1alpha1.ApplicationSourceDirectory{}
+	}
+	objs := make([]*unstructured.Unstructured, 0)
+	err := filepath.WalkDir(appPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != appPath && (!directory.Recurse || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(d.Name(), ".jsonnet") {
+			log.Warnf("Skipping Jsonnet file %s, which is not rendered locally", path)
+			return nil
+		}
+		if !d.Type().IsRegular() || !manifestFile.MatchString(d.Name()) {
+			return nil
+		}
+		relPath, err := filepath.Rel(appPath, path)
+		if err != nil {
+			return err
+		}
+		if directory.Exclude != "" && glob.Match(directory.Exclude, relPath) {
+			return nil
+		}
+		if directory.Include != "" && !glob.Match(directory.Include, relPath) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fileObjs, err := kube.SplitYAML(data)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal %q: %w", relPath, err)
+		}
+		objs = append(objs, fileObjs...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objs, nil
+}
+
+// renderKustomize builds a Kustomize source. The parameters of the source are applied by an
+// overlay written next to it, except the images which change neither the kinds nor the names of
+// the rendered objects.
+func renderKustomize(root, appPath string, source *appsv1alpha1.ApplicationSourceKustomize, options *appsv1alpha1.KustomizeOptions) ([]*unstructured.Unstructured, error) {
+	opts := krusty.MakeDefaultOptions()
+	if options != nil && strings.Contains(options.BuildOptions, "--enable-helm") {
+		// Kustomize inflates the Helm charts with the helm binary
+		helmCommand, err := exec.LookPath("helm")
+		if err != nil {
+			return nil, fmt.Errorf("the Kustomize build options enable Helm, which needs the helm binary: %w", err)
+		}
+		opts.PluginConfig.HelmConfig.Enabled = true
+		opts.PluginConfig.HelmConfig.Command = helmCommand
+	}
+	buildPath := appPath
+	if source != nil && !source.IsZero() {
+		overlay, err := os.MkdirTemp(root, ".resource-tracker-overlay-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(overlay)
+		if err := writeKustomizeOverlay(overlay, appPath, source); err != nil {
+			return nil, fmt.Errorf("error writing overlay with the Kustomize parameters: %w", err)
+		}
+		// The overlay refers to the patches and components of the source, outside of its root
+		opts.LoadRestrictions = kustomizetypes.LoadRestrictionsNone
+		buildPath = overlay
+	}
+	resMap, err := krusty.MakeKustomizer(opts).Run(filesys.MakeFsOnDisk(), buildPath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := resMap.AsYaml()
+	if err != nil {
+		return nil, err
+	}
+	return kube.SplitYAML(data)
+}
+
+// writeKustomizeOverlay writes to dir a kustomization building appPath with the parameters of
+// the given source.
+func writeKustomizeOverlay(dir, appPath string, source *appsv1alpha1.ApplicationSourceKustomize) error {
+	relPath := func(path string) (string, error) {
+		return filepath.Rel(dir, filepath.Join(appPath, path))
+	}
+	base, err := relPath(".")
+	if err != nil {
+		return err
+	}
+	kustomization := map[string]any{
+		"apiVersion": kustomizetypes.KustomizationVersion,
+		"kind":       kustomizetypes.KustomizationKind,
+		"resources":  []string{base},
+	}
+	if source.NamePrefix != "" {
+		kustomization["namePrefix"] = source.NamePrefix
+	}
+	if source.NameSuffix != "" {
+		kustomization["nameSuffix"] = source.NameSuffix
+	}
+	if source.Namespace != "" {
+		kustomization["namespace"] = source.Namespace
+	}
+	if len(source.CommonLabels) > 0 {
+		kustomization["commonLabels"] = source.CommonLabels
+	}
+	if len(source.CommonAnnotations) > 0 {
+		kustomization["commonAnnotations"] = source.CommonAnnotations
+	}
+	if len(source.Replicas) > 0 {
+		replicas := make([]map[string]any, 0, len(source.Replicas))
+		for _, replica := range source.Replicas {
+			count, err := replica.GetIntCount()
+			if err != nil {
+				return err
+			}
+			replicas = append(replicas, map[string]any{"name": replica.Name, "count": count})
+		}
+		kustomization["replicas"] = replicas
+	}
+	if len(source.Patches) > 0 {
+		patches := make([]appsv1alpha1.KustomizePatch, 0, len(source.Patches))
+		for _, patch := range source.Patches {
+			if patch.Path != "" {
+				if patch.Path, err = relPath(patch.Path); err != nil {
+					return err
+				}
+			}
+			patches = append(patches, patch)
+		}
+		kustomization["patches"] = patches
+	}
+	if len(source.Components) > 0 {
+		components := make([]string, 0, len(source.Components))
+		for _, component := range source.Components {
+			path, err := relPath(component)
+			if err != nil {
+				return err
+			}
+			components = append(components, path)
+		}
+		kustomization["components"] = components
+	}
+	data, err := yaml.Marshal(kustomization)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "kustomization.yaml"), data, 0o644)
+}
+
+// renderHelm renders a Helm chart stored in git like `helm template`, with the values and the
+// parameters of the source and the capabilities of the destination cluster. The dependencies of
+// the chart must be vendored in its charts directory.
+func renderHelm(root, appPath string, refPaths map[string]string, req *apiclient.ManifestRequest) ([]*unstructured.Unstructured, error) {
+	chart, err := loader.Load(appPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading chart: %w", err)
+	}
+	if len(chart.Dependencies()) < len(chart.Metadata.Dependencies) {
+		return nil, fmt.Errorf("the dependencies of chart %s are not vendored", chart.Name())
+	}
+	helm := req.ApplicationSource.Helm
+	if helm == nil {
+		helm = &appsv1alpha1.ApplicationSourceHelm{}
+	}
+	values, err := helmValues(root, appPath, refPaths, helm)
+	if err != nil {
+		return nil, err
+	}
+	releaseName := helm.ReleaseName
+	if releaseName == "" {
+		releaseName = req.AppName
+	}
+	namespace := helm.Namespace
+	if namespace == "" {
+		namespace = req.Namespace
+	}
+	caps := chartutil.DefaultCapabilities.Copy()
+	kubeVersion := helm.KubeVersion
+	if kubeVersion == "" {
+		kubeVersion = req.KubeVersion
+	}
+	if kubeVersion != "" {
+		version, err := chartutil.ParseKubeVersion(kubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing Kubernetes version %q: %w", kubeVersion, err)
+		}
+		caps.KubeVersion = *version
+	}
+	apiVersions := helm.APIVersions
+	if len(apiVersions) == 0 {
+		apiVersions = req.ApiVersions
+	}
+	caps.APIVersions = append(caps.APIVersions, apiVersions...)
+	renderValues, err := chartutil.ToRenderValuesWithSchemaValidation(chart, values, chartutil.ReleaseOptions{
+		Name:      releaseName,
+		Namespace: namespace,
+		Revision:  1,
+		IsInstall: true,
+	}, caps, helm.SkipSchemaValidation)
+	if err != nil {
+		return nil, fmt.Errorf("error computing values: %w", err)
+	}
+	rendered, err := engine.Render(chart, renderValues)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering chart: %w", err)
+	}
+	objs := make([]*unstructured.Unstructured, 0)
+	if !helm.SkipCrds {
+		for _, crd := range chart.CRDObjects() {
+			crdObjs, err := kube.SplitYAML(crd.File.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal %q: %w", crd.Filename, err)
+			}
+			objs = append(objs, crdObjs...)
+		}
+	}
+	names := make([]string, 0, len(rendered))
+	for name := range rendered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.HasPrefix(filepath.Base(name), "_") || strings.HasSuffix(name, "NOTES.txt") {
+			continue
+		}
+		templateObjs, err := kube.SplitYAML([]byte(rendered[name]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %q: %w", name, err)
+		}
+		for _, obj := range templateObjs {
+			if helm.SkipTests && isHelmTestHook(obj) {
+				continue
+			}
+			objs = append(objs, obj)
+		}
+	}
+	return objs, nil
+}
+
+// helmTestHooks are the Helm hooks of the test objects of a chart, test-success and
+// test-failure being the names Helm 2 used.
+var helmTestHooks = []string{"test", "test-success", "test-failure"}
+
+// isHelmTestHook returns true if the given object is a test of its chart, which `helm template
+// --skip-tests` leaves out.
+func isHelmTestHook(obj *unstructured.Unstructured) bool {
+	for _, hook := range strings.Split(obj.GetAnnotations()["helm.sh/hook"], ",") {
+		if slices.Contains(helmTestHooks, strings.TrimSpace(hook)) {
+			return true
+		}
+	}
+	return false
+}
+
+// helmValues merges the value files, the values and the parameters of a Helm source, in the
+// order `helm template` applies them.
+func helmValues(root, appPath string, refPaths map[string]string, helm *appsv1alpha1.ApplicationSourceHelm) (map[string]any, error) {
+	values := make(map[string]any)
+	for _, file := range helm.ValueFiles {
+		path, err := helmValueFilePath(root, appPath, refPaths, file)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) && helm.IgnoreMissingValueFiles {
+			log.Debugf("Ignoring missing value file %s", file)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading value file %s: %w", file, err)
+		}
+		if err := mergeHelmValues(values, data); err != nil {
+			return nil, fmt.Errorf("error parsing value file %s: %w", file, err)
+		}
+	}
+	inlineValues := []byte(helm.ValuesString())
+	if len(inlineValues) > 0 {
+		if err := mergeHelmValues(values, inlineValues); err != nil {
+			return nil, fmt.Errorf("error parsing values: %w", err)
+		}
+	}
+	for _, param := range helm.Parameters {
+		parse := strvals.ParseInto
+		if param.ForceString {
+			parse = strvals.ParseIntoString
+		}
+		if err := parse(param.Name+"="+param.Value, values); err != nil {
+			return nil, fmt.Errorf("error parsing parameter %s: %w", param.Name, err)
+		}
+	}
+	for _, param := range helm.FileParameters {
+		path, err := inboundPath(root, filepath.Join(strings.TrimPrefix(appPath, root), param.Path))
+		if err != nil {
+			return nil, err
+		}
+		reader := func([]rune) (any, error) {
+			data, err := os.ReadFile(path)
+			return string(data), err
+		}
+		if err := strvals.ParseIntoFile(param.Name+"="+param.Path, values, reader); err != nil {
+			return nil, fmt.Errorf("error parsing file parameter %s: %w", param.Name, err)
+		}
+	}
+	return values, nil
+}
+
+// helmValueFilePath returns the path of a value file of a Helm source, relative to the chart or,
+// if it starts with $<ref>, to the checkout of that ref source.
+func helmValueFilePath(root, appPath string, refPaths map[string]string, file string) (string, error) {
+	if strings.Contains(file, "://") {
+		return "", fmt.Errorf("remote value file %s is not supported", file)
+	}
+	if strings.HasPrefix(file, "$") {
+		ref, rest, _ := strings.Cut(file, "/")
+		refRoot, ok := refPaths[ref]
+		if !ok {
+			return "", fmt.Errorf("value file %s refers to unknown source %s", file, ref)
+		}
+		return inboundPath(refRoot, rest)
+	}
+	return inboundPath(root, filepath.Join(strings.TrimPrefix(appPath, root), file))
+}
+
+// mergeHelmValues merges the given YAML values into dst, overriding the existing keys.
+func mergeHelmValues(dst map[string]any, data []byte) error {
+	src := make(map[string]any)
+	if err := yaml.Unmarshal(data, &src); err != nil {
+		return err
+	}
+	mergeMaps(dst, src)
+	return nil
+}
+
+func mergeMaps(dst, src map[string]any) {
+	for key, value := range src {
+		if srcMap, ok := value.(map[string]any); ok {
+			if dstMap, ok := dst[key].(map[string]any); ok {
+				mergeMaps(dstMap, srcMap)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
@@ -0,0 +1,194 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	appsv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/argo-cd/v3/reposerver/apiclient"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// newTestRepository creates a git repository with the given files committed on its default branch.
+func newTestRepository(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	r, err := gogit.PlainInit(dir, false)
+	require.NoError(t, err)
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	worktree, err := r.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, worktree.AddGlob("."))
+	_, err = worktree.Commit("initial", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+	return dir
+}
+
+// commitOnBranch commits the given files to a new branch of the given repository, and checks out
+// its default branch again.
+func commitOnBranch(t *testing.T, dir, branch string, files map[string]string) {
+	r, err := gogit.PlainOpen(dir)
+	require.NoError(t, err)
+	worktree, err := r.Worktree()
+	require.NoError(t, err)
+	head, err := r.Head()
+	require.NoError(t, err)
+	require.NoError(t, worktree.Checkout(&gogit.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(branch), Create: true}))
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	require.NoError(t, worktree.AddGlob("."))
+	_, err = worktree.Commit(branch, &gogit.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+	require.NoError(t, worktree.Checkout(&gogit.CheckoutOptions{Branch: head.Name()}))
+}
+
+func Test_LocalRenderer_GenerateManifests(t *testing.T) {
+	repoDir := newTestRepository(t, map[string]string{
+		"plain/deployment.yaml":        "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+		"plain/nested/cm.yaml":         "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n",
+		"plain/README.md":              "not a manifest",
+		"kustomize/kustomization.yaml": "resources:\n- service.yaml\n",
+		"kustomize/service.yaml":       "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n",
+		"chart/Chart.yaml":             "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"chart/values.yaml":            "monitor: false\n",
+		"chart/templates/sa.yaml":      "apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: {{ .Release.Name }}\n",
+		"chart/templates/monitor.yaml": "{{ if .Values.monitor }}\napiVersion: monitoring.coreos.com/v1\nkind: ServiceMonitor\nmetadata:\n  name: web\n{{ end }}\n",
+	})
+	renderer := NewLocalRenderer(t.TempDir())
+	render := func(source appsv1alpha1.ApplicationSource) []string {
+		manifests, err := renderer.GenerateManifests(context.Background(), &apiclient.ManifestRequest{
+			Repo:              &appsv1alpha1.Repository{Repo: repoDir},
+			Revision:          "HEAD",
+			AppName:           "guestbook",
+			Namespace:         "default",
+			ApplicationSource: &source,
+		})
+		require.NoError(t, err)
+		objs, err := unmarshalManifests(manifests)
+		require.NoError(t, err)
+		names := make([]string, 0, len(objs))
+		for _, obj := range objs {
+			names = append(names, obj.GetKind()+"/"+obj.GetName())
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	assert.Equal(t, []string{"Deployment/web"}, render(appsv1alpha1.ApplicationSource{Path: "plain"}))
+	assert.Equal(t, []string{"ConfigMap/config", "Deployment/web"},
+		render(appsv1alpha1.ApplicationSource{Path: "plain", Directory: &appsv1alpha1.ApplicationSourceDirectory{Recurse: true}}))
+	assert.Equal(t, []string{"ConfigMap/config"},
+		render(appsv1alpha1.ApplicationSource{Path: "plain", Directory: &appsv1alpha1.ApplicationSourceDirectory{Recurse: true, Exclude: "deployment.yaml"}}))
+
+	assert.Equal(t, []string{"Service/web"}, render(appsv1alpha1.ApplicationSource{Path: "kustomize"}))
+	assert.Equal(t, []string{"Service/prod-web"},
+		render(appsv1alpha1.ApplicationSource{Path: "kustomize", Kustomize: &appsv1alpha1.ApplicationSourceKustomize{NamePrefix: "prod-"}}))
+
+	assert.Equal(t, []string{"ServiceAccount/guestbook"}, render(appsv1alpha1.ApplicationSource{Path: "chart"}))
+	assert.Equal(t, []string{"ServiceAccount/web", "ServiceMonitor/web"}, render(appsv1alpha1.ApplicationSource{
+		Path: "chart",
+		Helm: &appsv1alpha1.ApplicationSourceHelm{
+			ReleaseName: "web",
+			Parameters:  []appsv1alpha1.HelmParameter{{Name: "monitor", Value: "true"}},
+		},
+	}))
+
+	// A source outside of the repository is rejected
+	_, err := renderer.GenerateManifests(context.Background(), &apiclient.ManifestRequest{
+		Repo:              &appsv1alpha1.Repository{Repo: repoDir},
+		ApplicationSource: &appsv1alpha1.ApplicationSource{Path: "../.."},
+	})
+	assert.Error(t, err)
+}
+
+func Test_LocalRenderer_refSourceRevision(t *testing.T) {
+	repoDir := newTestRepository(t, map[string]string{
+		"chart/Chart.yaml":             "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"chart/templates/monitor.yaml": "{{ if .Values.monitor }}\napiVersion: monitoring.coreos.com/v1\nkind: ServiceMonitor\nmetadata:\n  name: web\n{{ end }}\n",
+		"chart/templates/sa.yaml":      "apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: web\n",
+		"values.yaml":                  "monitor: false\n",
+	})
+	commitOnBranch(t, repoDir, "monitored", map[string]string{"values.yaml": "monitor: true\n"})
+	renderer := NewLocalRenderer(t.TempDir())
+	request := &apiclient.ManifestRequest{
+		Repo:     &appsv1alpha1.Repository{Repo: repoDir},
+		Revision: "HEAD",
+		AppName:  "guestbook",
+		ApplicationSource: &appsv1alpha1.ApplicationSource{
+			RepoURL: repoDir,
+			Path:    "chart",
+			Helm:    &appsv1alpha1.ApplicationSourceHelm{ValueFiles: []string{"$values/values.yaml"}},
+		},
+		// The ref source checks out the repository of the chart at another revision
+		RefSources: appsv1alpha1.RefTargetRevisionMapping{
+			"$values": &appsv1alpha1.RefTarget{Repo: appsv1alpha1.Repository{Repo: repoDir}, TargetRevision: "monitored"},
+		},
+	}
+	manifests, err := renderer.GenerateManifests(context.Background(), request)
+	require.NoError(t, err)
+	objs, err := unmarshalManifests(manifests)
+	require.NoError(t, err)
+	kinds := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		kinds = append(kinds, obj.GetKind())
+	}
+	assert.ElementsMatch(t, []string{"ServiceAccount", "ServiceMonitor"}, kinds)
+}
+
+func Test_removeOldCheckouts(t *testing.T) {
+	cloneDir := filepath.Join(t.TempDir(), "repo")
+	for i := 0; i < maxCheckoutsPerRepository+2; i++ {
+		dir := fmt.Sprintf("%s-%d", cloneDir, i)
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		modTime := time.Now().Add(time.Duration(i) * time.Minute)
+		require.NoError(t, os.Chtimes(dir, modTime, modTime))
+	}
+	require.NoError(t, removeOldCheckouts(cloneDir))
+	paths, err := filepath.Glob(cloneDir + "-*")
+	require.NoError(t, err)
+	sort.Strings(paths)
+	assert.Equal(t, []string{cloneDir + "-2", cloneDir + "-3", cloneDir + "-4", cloneDir + "-5"}, paths)
+}
+
+func Test_renderKustomize_helmBinary(t *testing.T) {
+	appPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(appPath, "kustomization.yaml"), []byte("resources: []\n"), 0o644))
+	t.Setenv("PATH", t.TempDir())
+	_, err := renderKustomize(appPath, appPath, nil, &appsv1alpha1.KustomizeOptions{BuildOptions: "--enable-helm"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "helm binary")
+}
+
+func Test_isHelmTestHook(t *testing.T) {
+	hooked := func(hook string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		if hook != "" {
+			obj.SetAnnotations(map[string]string{"helm.sh/hook": hook})
+		}
+		return obj
+	}
+	assert.True(t, isHelmTestHook(hooked("test")))
+	assert.True(t, isHelmTestHook(hooked("test-success")))
+	assert.True(t, isHelmTestHook(hooked("test-failure")))
+	assert.True(t, isHelmTestHook(hooked("post-install, test")))
+	assert.False(t, isHelmTestHook(hooked("post-install")))
+	assert.False(t, isHelmTestHook(hooked("")))
+}
@@ -2,27 +2,37 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
 
+	trackerkube "github.com/anandf/resource-tracker/pkg/kube"
 	"github.com/argoproj/argo-cd/v3/common"
 	appsv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
 	"github.com/argoproj/argo-cd/v3/reposerver/apiclient"
 	"github.com/argoproj/argo-cd/v3/util/argo"
 	"github.com/argoproj/argo-cd/v3/util/db"
 	"github.com/argoproj/argo-cd/v3/util/env"
+	"github.com/argoproj/argo-cd/v3/util/git"
 	"github.com/argoproj/argo-cd/v3/util/io"
 	kubeutil "github.com/argoproj/argo-cd/v3/util/kube"
 	"github.com/argoproj/argo-cd/v3/util/settings"
 	"github.com/argoproj/argo-cd/v3/util/tls"
 	"github.com/argoproj/gitops-engine/pkg/utils/kube"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
 
+// clusterAPIDetails holds the version and the API resources of a destination cluster, which are
+// sent to the repo-server so that manifests are rendered for the cluster they are deployed to.
 type clusterAPIDetails struct {
-	APIVersions  string
+	KubeVersion  string
 	APIResources []kube.APIResourceInfo
 }
 
@@ -32,13 +42,37 @@ type RepoServerManager struct {
 	repoClientset apiclient.Clientset
 	kubectl       kube.Kubectl
 	controllerNS  string
+	// clusters caches the API details of each destination cluster, by server
+	mu       sync.Mutex
+	clusters map[string]*clusterAPIDetails
+	// manifests caches the generated manifests by resolved revision and source parameters
+	manifests *manifestCache
+	// local renders the manifests from local checkouts when the repo-server fails, if set
+	local                 *LocalRenderer
+	repoServerAddress     string
+	repoServerUnavailable atomic.Bool
 }
 
+// includeAllResources is a resource filter which excludes no resource. The resource inclusions of
+// Argo CD are not applied to the API resources sent to the repo-server, as a chart which renders a
+// kind only if the cluster serves it would otherwise never reveal that the kind is needed.
+type includeAllResources struct{}
+
+func (includeAllResources) IsExcludedResource(_, _, _ string) bool {
+	return false
+}
+
+// NewRepoServerManager returns a RepoServerManager generating manifests through the given
+// repo-server. The generated manifests are cached on disk in manifestCacheDir unless it is empty.
+// Unless localCheckoutDir is empty, the manifests are rendered from local checkouts of the
+// repositories in localCheckoutDir when the repo-server address is empty or the repo-server fails.
 func NewRepoServerManager(kubeConfig *rest.Config,
 	controllerNamespace string, repoServerAddress string,
 	repoServerTimeoutSeconds int,
 	repoServerPlaintext bool,
-	repoServerStrictTLS bool) (*RepoServerManager, error) {
+	repoServerStrictTLS bool,
+	manifestCacheDir string,
+	localCheckoutDir string) (*RepoServerManager, error) {
 	clientSet, err := kubernetes.NewForConfig(kubeConfig)
 	if err != nil {
 		return nil, err
@@ -61,17 +95,134 @@ func NewRepoServerManager(kubeConfig *rest.Config,
 	}
 	repoClientset := apiclient.NewRepoServerClientset(repoServerAddress, repoServerTimeoutSeconds, tlsConfig)
 	kubectl := kubeutil.NewKubectl()
-	return &RepoServerManager{
-		db:            dbInstance,
-		settingsMgr:   settingsMgr,
-		repoClientset: repoClientset,
-		kubectl:       kubectl,
-		controllerNS:  controllerNamespace,
-	}, nil
+	manager := &RepoServerManager{
+		db:                dbInstance,
+		settingsMgr:       settingsMgr,
+		repoClientset:     repoClientset,
+		kubectl:           kubectl,
+		controllerNS:      controllerNamespace,
+		clusters:          make(map[string]*clusterAPIDetails),
+		manifests:         newManifestCache(manifestCacheDir),
+		repoServerAddress: repoServerAddress,
+	}
+	if localCheckoutDir != "" {
+		manager.local = NewLocalRenderer(localCheckoutDir)
+	}
+	return manager, nil
+}
+
+// getClusterAPIDetails returns the version and the API resources of the given destination
+// cluster, from the cache if they were already fetched.
+func (r *RepoServerManager) getClusterAPIDetails(ctx context.Context, destination appsv1alpha1.ApplicationDestination, kubeconfig string) (*clusterAPIDetails, error) {
+	cluster, err := argo.GetDestinationCluster(ctx, destination, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("error getting destination cluster: %w", err)
+	}
+	r.mu.Lock()
+	details, ok := r.clusters[cluster.Server]
+	r.mu.Unlock()
+	if ok {
+		return details, nil
+	}
+	restConfig, err := trackerkube.RestConfigFromCluster(cluster, kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("error building rest.Config for cluster %q: %w", cluster.Server, err)
+	}
+	kubeVersion, err := r.kubectl.GetServerVersion(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error getting version of cluster %q: %w", cluster.Server, err)
+	}
+	apiResources, err := r.kubectl.GetAPIResources(restConfig, true, includeAllResources{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting API resources of cluster %q: %w", cluster.Server, err)
+	}
+	details = &clusterAPIDetails{KubeVersion: kubeVersion, APIResources: apiResources}
+	r.mu.Lock()
+	r.clusters[cluster.Server] = details
+	r.mu.Unlock()
+	return details, nil
 }
 
-// GetApplicationChildManifests fetches manifests and filters direct child resources
+// GetApplicationChildManifests fetches manifests and filters direct child resources. The manifest
+// requests are filled the way the Argo CD application controller fills them, including the
+// version and API resources of the destination cluster, so that Helm charts branching on
+// .Capabilities or .Release.Namespace and config management plugins reading the ARGOCD_APP_*
+// and KUBE_* variables render what Argo CD deploys. An application with a source hydrator is
+// rendered from its sync source.
 func (r *RepoServerManager) GetApplicationChildManifests(ctx context.Context, application *appsv1alpha1.Application, proj *appsv1alpha1.AppProject, kubeconfig string) ([]*unstructured.Unstructured, error) {
+	requestSettings, err := r.getManifestSettings(ctx, application, proj, kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	// Establish a connection with the repo-server, unless the manifests are rendered locally
+	var repoClient apiclient.RepoServerServiceClient
+	if !r.renderLocally() {
+		conn, client, err := r.repoClientset.NewRepoServerClient()
+		if err != nil && r.local == nil {
+			return nil, fmt.Errorf("error connecting to repo-server: %w", err)
+		} else if err != nil {
+			log.WithError(err).Warnf("Error connecting to repo-server, rendering the manifests of application %s from local checkouts", application.Name)
+		} else {
+			defer io.Close(conn)
+			repoClient = client
+		}
+	}
+	// The sources of an application with a source hydrator are its sync source
+	sources := application.Spec.GetSources()
+	revisions := make([]string, 0, len(sources))
+	for _, source := range sources {
+		revisions = append(revisions, source.TargetRevision)
+	}
+	refSources, err := argo.GetRefSources(ctx, sources, application.Spec.Project, r.db.GetRepository, revisions, false)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ref sources: %w", err)
+	}
+	repos := make([]*appsv1alpha1.Repository, len(sources))
+	for i, source := range sources {
+		repos[i], err = r.db.GetRepository(ctx, source.RepoURL, proj.Name)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching repository: %w", err)
+		}
+	}
+	resolved := r.resolveRevisions(ctx, repoClient, application, repos, revisions, refSources)
+	targetObjs := make([]*unstructured.Unstructured, 0)
+	for i, source := range sources {
+		request, err := requestSettings.manifestRequest(application, proj, &source, repos[i], revisions[i], refSources, r.controllerNS)
+		if err != nil {
+			return nil, err
+		}
+		manifests, err := r.generateManifests(ctx, repoClient, request, resolved)
+		if err != nil {
+			return nil, fmt.Errorf("error generating manifest: %w", err)
+		}
+		targetObj, err := unmarshalManifests(manifests)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling manifests: %w", err)
+		}
+		targetObjs = append(targetObjs, targetObj...)
+		log.Debugf("Successfully fetched %v target manifest(s) for application: %s. Manifests: %v", len(manifests), application.Name, manifests)
+	}
+	return targetObjs, nil
+}
+
+// manifestSettings holds the settings of Argo CD and the details of the destination cluster the
+// manifest requests of an application are filled with.
+type manifestSettings struct {
+	helmRepos          []*appsv1alpha1.Repository
+	helmCredentials    []*appsv1alpha1.RepoCreds
+	enabledSourceTypes map[string]bool
+	kustomizeSettings  *settings.KustomizeSettings
+	helmOptions        *appsv1alpha1.HelmOptions
+	appLabelKey        string
+	installationID     string
+	trackingMethod     string
+	kubeVersion        string
+	apiVersions        []string
+}
+
+// getManifestSettings returns the settings the manifest requests of the given application are
+// filled with, with the Helm repositories and credentials permitted by its project.
+func (r *RepoServerManager) getManifestSettings(ctx context.Context, application *appsv1alpha1.Application, proj *appsv1alpha1.AppProject, kubeconfig string) (*manifestSettings, error) {
 	// Fetch Helm repositories
 	helmRepos, err := r.db.ListHelmRepositories(ctx)
 	if err != nil {
@@ -101,61 +252,171 @@ func (r *RepoServerManager) GetApplicationChildManifests(ctx context.Context, ap
 	if err != nil {
 		return nil, fmt.Errorf("error fetching Kustomize settings: %w", err)
 	}
-	// Establish a connection with the repo-server
-	conn, repoClient, err := r.repoClientset.NewRepoServerClient()
+	helmOptions, err := r.settingsMgr.GetHelmSettings()
 	if err != nil {
-		return nil, fmt.Errorf("error connecting to repo-server: %w", err)
+		return nil, fmt.Errorf("error fetching Helm settings: %w", err)
 	}
-	defer io.Close(conn)
-	sources := make([]appsv1alpha1.ApplicationSource, 0)
-	revisions := make([]string, 0)
-	if application.Spec.HasMultipleSources() {
-		for _, source := range application.Spec.Sources {
-			sources = append(sources, source)
-			revisions = append(revisions, source.TargetRevision)
-		}
+	appLabelKey, err := r.settingsMgr.GetAppInstanceLabelKey()
+	if err != nil {
+		return nil, fmt.Errorf("error getting application instance label key: %w", err)
+	}
+	installationID, err := r.settingsMgr.GetInstallationID()
+	if err != nil {
+		return nil, fmt.Errorf("error getting installation ID: %w", err)
+	}
+	requestSettings := &manifestSettings{
+		helmRepos:          permittedHelmRepos,
+		helmCredentials:    permittedHelmCredentials,
+		enabledSourceTypes: enabledSourceTypes,
+		kustomizeSettings:  kustomizeSettings,
+		helmOptions:        helmOptions,
+		appLabelKey:        appLabelKey,
+		installationID:     installationID,
+		trackingMethod:     string(argo.GetTrackingMethod(r.settingsMgr)),
+	}
+	// Without the details of the destination cluster, the manifests are rendered with the
+	// defaults of the repo-server
+	if details, err := r.getClusterAPIDetails(ctx, application.Spec.Destination, kubeconfig); err != nil {
+		log.WithError(err).Warnf("Rendering the manifests of application %s without the version and API resources of its destination cluster", application.Name)
 	} else {
-		revision := application.Spec.GetSource().TargetRevision
-		revisions = append(revisions, revision)
-		sources = append(sources, application.Spec.GetSource())
+		requestSettings.kubeVersion = details.KubeVersion
+		requestSettings.apiVersions = argo.APIResourcesToStrings(details.APIResources, true)
 	}
-	refSources, err := argo.GetRefSources(ctx, sources, application.Spec.Project, r.db.GetRepository, revisions, false)
+	return requestSettings, nil
+}
+
+// manifestRequest returns the request generating the manifests of the given source of the
+// application at the given revision, filled the way the Argo CD application controller fills it.
+func (s *manifestSettings) manifestRequest(application *appsv1alpha1.Application, proj *appsv1alpha1.AppProject, source *appsv1alpha1.ApplicationSource, repo *appsv1alpha1.Repository, revision string, refSources appsv1alpha1.RefTargetRevisionMapping, controllerNamespace string) (*apiclient.ManifestRequest, error) {
+	kustomizeOptions, err := s.kustomizeSettings.GetOptions(*source)
 	if err != nil {
-		return nil, fmt.Errorf("error getting ref sources: %w", err)
+		return nil, fmt.Errorf("error getting Kustomize options: %w", err)
 	}
-	targetObjs := make([]*unstructured.Unstructured, 0)
-	for i, source := range sources {
-		repo, err := r.db.GetRepository(ctx, source.RepoURL, proj.Name)
+	return &apiclient.ManifestRequest{
+		Repo:                            repo,
+		Repos:                           s.helmRepos,
+		Revision:                        revision,
+		AppLabelKey:                     s.appLabelKey,
+		AppName:                         application.InstanceName(controllerNamespace),
+		Namespace:                       application.Spec.Destination.Namespace,
+		ApplicationSource:               source,
+		KustomizeOptions:                kustomizeOptions,
+		KubeVersion:                     s.kubeVersion,
+		ApiVersions:                     s.apiVersions,
+		HelmRepoCreds:                   s.helmCredentials,
+		TrackingMethod:                  s.trackingMethod,
+		EnabledSourceTypes:              s.enabledSourceTypes,
+		HelmOptions:                     s.helmOptions,
+		HasMultipleSources:              application.Spec.HasMultipleSources(),
+		RefSources:                      refSources,
+		ProjectName:                     proj.Name,
+		ProjectSourceRepos:              proj.Spec.SourceRepos,
+		AnnotationManifestGeneratePaths: application.GetAnnotation(appsv1alpha1.AnnotationKeyManifestGeneratePaths),
+		InstallationID:                  s.installationID,
+	}, nil
+}
+
+// resolveRevisions resolves the given revisions of the sources of the application to commit SHAs
+// or chart versions in place, along with the revisions of the ref sources, so that the manifests
+// generated at the same revisions are cached. It returns false if a revision could not be resolved,
+// in which case the manifests of the application are not cached.
+func (r *RepoServerManager) resolveRevisions(ctx context.Context, repoClient apiclient.RepoServerServiceClient, application *appsv1alpha1.Application, repos []*appsv1alpha1.Repository, revisions []string, refSources appsv1alpha1.RefTargetRevisionMapping) bool {
+	if repoClient == nil {
+		return false
+	}
+	resolved := true
+	for i, source := range application.Spec.GetSources() {
+		revision, err := resolveRevision(ctx, repoClient, repos[i], application, i, revisions[i])
 		if err != nil {
-			return nil, fmt.Errorf("error fetching repository: %w", err)
+			r.checkAvailability(err)
+			log.WithError(err).Warnf("Error resolving revision %q of source %s of application %s, its manifests are not cached", revisions[i], source.RepoURL, application.Name)
+			resolved = false
+			continue
 		}
-		kustomizeOptions, err := kustomizeSettings.GetOptions(source)
-		if err != nil {
-			return nil, fmt.Errorf("error getting ref sources: %w", err)
+		revisions[i] = revision
+		if refSource, ok := refSources["$"+source.Ref]; ok && source.Ref != "" {
+			refSource.TargetRevision = revision
 		}
-		// Generate manifest using the RepoServer client
-		manifestInfo, err := repoClient.GenerateManifest(ctx, &apiclient.ManifestRequest{
-			Repo:               repo,
-			Repos:              permittedHelmRepos,
-			Revision:           revisions[i],
-			ApplicationSource:  &source,
-			EnabledSourceTypes: enabledSourceTypes,
-			KustomizeOptions:   kustomizeOptions,
-			HelmRepoCreds:      permittedHelmCredentials,
-			HasMultipleSources: application.Spec.HasMultipleSources(),
-			RefSources:         refSources,
-		})
-		if err != nil {
-			return nil, fmt.Errorf("error generating manifest: %w", err)
+	}
+	return resolved
+}
+
+// generateManifests returns the manifests of the given request generated by the repo-server, or
+// rendered from a local checkout if the repo-server cannot be reached. The manifests are cached if
+// cached is true, the ones rendered locally under their own key as they may differ from the ones
+// the repo-server generates.
+func (r *RepoServerManager) generateManifests(ctx context.Context, repoClient apiclient.RepoServerServiceClient, request *apiclient.ManifestRequest, cached bool) ([]string, error) {
+	key, err := manifestCacheKey(request)
+	if err != nil {
+		cached = false
+	}
+	get := func(key string, generate func(ctx context.Context) ([]string, error)) ([]string, error) {
+		if !cached {
+			return generate(ctx)
 		}
-		targetObj, err := unmarshalManifests(manifestInfo.Manifests)
+		return r.manifests.get(ctx, key, generate)
+	}
+	renderLocally := func(ctx context.Context) ([]string, error) {
+		return r.local.GenerateManifests(ctx, request)
+	}
+	if repoClient == nil || r.renderLocally() {
+		return get(localManifestCacheKey(key), renderLocally)
+	}
+	manifests, err := get(key, func(ctx context.Context) ([]string, error) {
+		manifestInfo, err := repoClient.GenerateManifest(ctx, request)
 		if err != nil {
-			return nil, fmt.Errorf("error unmarshalling manifests: %w", err)
+			return nil, err
 		}
-		targetObjs = append(targetObjs, targetObj...)
-		log.Debugf("Successfully fetched %v target manifest(s) from repo-server for application: %s. Manifests: %v", len(manifestInfo.Manifests), application.Name, manifestInfo.Manifests)
+		return manifestInfo.Manifests, nil
+	})
+	if err == nil || r.local == nil || !isUnreachable(err) {
+		return manifests, err
 	}
-	return targetObjs, nil
+	r.checkAvailability(err)
+	log.WithError(err).Warnf("Error generating manifests of source %s of application %s with the repo-server, rendering them from a local checkout", request.ApplicationSource.RepoURL, request.AppName)
+	return get(localManifestCacheKey(key), renderLocally)
+}
+
+// renderLocally returns whether the manifests are rendered locally without trying the repo-server,
+// because it has no address or was found unavailable.
+func (r *RepoServerManager) renderLocally() bool {
+	return r.local != nil && (r.repoServerAddress == "" || r.repoServerUnavailable.Load())
+}
+
+// checkAvailability records that the repo-server is unavailable if the given error says so, so
+// that the next manifests are rendered locally without waiting for it.
+func (r *RepoServerManager) checkAvailability(err error) {
+	if r.local != nil && isUnreachable(err) && !r.repoServerUnavailable.Swap(true) {
+		log.WithError(err).Warn("Repo-server is unavailable, rendering the next manifests from local checkouts")
+	}
+}
+
+// isUnreachable returns true if the given error of a repo-server call says that the repo-server
+// could not be reached, rather than failing to generate the manifests.
+func isUnreachable(err error) bool {
+	if status.Code(err) == codes.Unavailable {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// resolveRevision resolves the given revision of the source of the application at the given index
+// to a commit SHA or a chart version.
+func resolveRevision(ctx context.Context, repoClient apiclient.RepoServerServiceClient, repo *appsv1alpha1.Repository, application *appsv1alpha1.Application, index int, revision string) (string, error) {
+	if git.IsCommitSHA(revision) {
+		return revision, nil
+	}
+	response, err := repoClient.ResolveRevision(ctx, &apiclient.ResolveRevisionRequest{
+		Repo:              repo,
+		App:               application,
+		AmbiguousRevision: revision,
+		SourceIndex:       int64(index),
+	})
+	if err != nil {
+		return "", err
+	}
+	return response.Revision, nil
 }
 
 func unmarshalManifests(manifests []string) ([]*unstructured.Unstructured, error) {
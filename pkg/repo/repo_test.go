@@ -0,0 +1,240 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	appsv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/argo-cd/v3/reposerver/apiclient"
+	"github.com/argoproj/argo-cd/v3/util/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testSHA = "53e28ff20cc530b9ada2173fbbd64d48338583ba"
+
+// fakeRepoClient resolves the given revisions of a repo-server, and fails to resolve the others
+// with err. It records the resolve requests, and generates the given manifests or fails with
+// generateErr.
+type fakeRepoClient struct {
+	apiclient.RepoServerServiceClient
+	revisions   map[string]string
+	err         error
+	requests    []*apiclient.ResolveRevisionRequest
+	manifests   []string
+	generateErr error
+	generated   int
+}
+
+func (f *fakeRepoClient) GenerateManifest(ctx context.Context, in *apiclient.ManifestRequest, opts ...grpc.CallOption) (*apiclient.ManifestResponse, error) {
+	f.generated++
+	if f.generateErr != nil {
+		return nil, f.generateErr
+	}
+	return &apiclient.ManifestResponse{Manifests: f.manifests}, nil
+}
+
+func (f *fakeRepoClient) ResolveRevision(ctx context.Context, in *apiclient.ResolveRevisionRequest, opts ...grpc.CallOption) (*apiclient.ResolveRevisionResponse, error) {
+	f.requests = append(f.requests, in)
+	revision, ok := f.revisions[in.AmbiguousRevision]
+	if !ok {
+		return nil, f.err
+	}
+	return &apiclient.ResolveRevisionResponse{Revision: revision, AmbiguousRevision: in.AmbiguousRevision}, nil
+}
+
+func Test_resolveRevision(t *testing.T) {
+	repoClient := &fakeRepoClient{revisions: map[string]string{"HEAD": testSHA}, err: errors.New("unknown revision")}
+	app := &appsv1alpha1.Application{}
+	repo := &appsv1alpha1.Repository{Repo: "https://github.com/argoproj/argocd-example-apps"}
+
+	// A commit SHA is not resolved
+	revision, err := resolveRevision(context.Background(), repoClient, repo, app, 0, testSHA)
+	require.NoError(t, err)
+	assert.Equal(t, testSHA, revision)
+	assert.Empty(t, repoClient.requests)
+
+	revision, err = resolveRevision(context.Background(), repoClient, repo, app, 1, "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, testSHA, revision)
+	require.Len(t, repoClient.requests, 1)
+	assert.Equal(t, int64(1), repoClient.requests[0].SourceIndex)
+	assert.Same(t, repo, repoClient.requests[0].Repo)
+
+	_, err = resolveRevision(context.Background(), repoClient, repo, app, 0, "missing")
+	assert.Error(t, err)
+}
+
+func Test_resolveRevisions(t *testing.T) {
+	sources := appsv1alpha1.ApplicationSources{
+		{RepoURL: "https://github.com/argoproj/argocd-example-apps", Path: "helm-guestbook", TargetRevision: "HEAD"},
+		{RepoURL: "https://github.com/argoproj/values", TargetRevision: "main", Ref: "values"},
+	}
+	app := &appsv1alpha1.Application{Spec: appsv1alpha1.ApplicationSpec{Sources: sources}}
+	repos := []*appsv1alpha1.Repository{{Repo: sources[0].RepoURL}, {Repo: sources[1].RepoURL}}
+	newRefSources := func() appsv1alpha1.RefTargetRevisionMapping {
+		return appsv1alpha1.RefTargetRevisionMapping{"$values": &appsv1alpha1.RefTarget{Repo: *repos[1], TargetRevision: "main"}}
+	}
+
+	t.Run("revisions of the sources and of the ref sources are resolved", func(t *testing.T) {
+		repoClient := &fakeRepoClient{revisions: map[string]string{"HEAD": testSHA, "main": "c2a5a5b0e2e0d8f7f0b0f5a1b4a9e2d3c4b5a6f7"}}
+		revisions := []string{"HEAD", "main"}
+		refSources := newRefSources()
+		assert.True(t, (&RepoServerManager{}).resolveRevisions(context.Background(), repoClient, app, repos, revisions, refSources))
+		assert.Equal(t, []string{testSHA, "c2a5a5b0e2e0d8f7f0b0f5a1b4a9e2d3c4b5a6f7"}, revisions)
+		assert.Equal(t, "c2a5a5b0e2e0d8f7f0b0f5a1b4a9e2d3c4b5a6f7", refSources["$values"].TargetRevision)
+	})
+
+	t.Run("manifests are not cached if a revision is not resolved", func(t *testing.T) {
+		repoClient := &fakeRepoClient{revisions: map[string]string{"HEAD": testSHA}, err: errors.New("unknown revision")}
+		revisions := []string{"HEAD", "main"}
+		refSources := newRefSources()
+		assert.False(t, (&RepoServerManager{}).resolveRevisions(context.Background(), repoClient, app, repos, revisions, refSources))
+		assert.Equal(t, []string{testSHA, "main"}, revisions)
+		assert.Equal(t, "main", refSources["$values"].TargetRevision)
+	})
+
+	t.Run("manifests are not cached without repo-server", func(t *testing.T) {
+		revisions := []string{"HEAD", "main"}
+		assert.False(t, (&RepoServerManager{}).resolveRevisions(context.Background(), nil, app, repos, revisions, newRefSources()))
+		assert.Equal(t, []string{"HEAD", "main"}, revisions)
+	})
+
+	t.Run("an unavailable repo-server is recorded", func(t *testing.T) {
+		repoClient := &fakeRepoClient{err: status.Error(codes.Unavailable, "connection refused")}
+		manager := &RepoServerManager{local: NewLocalRenderer(t.TempDir()), repoServerAddress: "argocd-repo-server:8081"}
+		assert.False(t, manager.resolveRevisions(context.Background(), repoClient, app, repos, []string{"HEAD", "main"}, newRefSources()))
+		assert.True(t, manager.renderLocally())
+	})
+}
+
+func Test_manifestRequest(t *testing.T) {
+	requestSettings := &manifestSettings{
+		helmRepos:          []*appsv1alpha1.Repository{{Repo: "https://charts.example.com", Type: "helm"}},
+		helmCredentials:    []*appsv1alpha1.RepoCreds{{URL: "https://charts.example.com"}},
+		enabledSourceTypes: map[string]bool{"helm": true, "kustomize": true},
+		kustomizeSettings:  &settings.KustomizeSettings{BuildOptions: "--enable-helm"},
+		helmOptions:        &appsv1alpha1.HelmOptions{ValuesFileSchemes: []string{"https"}},
+		appLabelKey:        "app.kubernetes.io/instance",
+		installationID:     "test",
+		trackingMethod:     "annotation",
+		kubeVersion:        "1.31.0",
+		apiVersions:        []string{"apps/v1", "apps/v1/Deployment"},
+	}
+	source := &appsv1alpha1.ApplicationSource{RepoURL: "https://github.com/argoproj/argocd-example-apps", Path: "kustomize-guestbook", TargetRevision: "HEAD"}
+	app := &appsv1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "guestbook",
+			Namespace:   "team-a",
+			Annotations: map[string]string{appsv1alpha1.AnnotationKeyManifestGeneratePaths: "."},
+		},
+		Spec: appsv1alpha1.ApplicationSpec{
+			Destination: appsv1alpha1.ApplicationDestination{Namespace: "guestbook"},
+			Sources:     appsv1alpha1.ApplicationSources{*source, {RepoURL: "https://github.com/argoproj/values", Ref: "values"}},
+		},
+	}
+	proj := &appsv1alpha1.AppProject{ObjectMeta: metav1.ObjectMeta{Name: "default"}, Spec: appsv1alpha1.AppProjectSpec{SourceRepos: []string{"*"}}}
+	repo := &appsv1alpha1.Repository{Repo: source.RepoURL}
+	refSources := appsv1alpha1.RefTargetRevisionMapping{"$values": &appsv1alpha1.RefTarget{TargetRevision: testSHA}}
+
+	request, err := requestSettings.manifestRequest(app, proj, source, repo, testSHA, refSources, "argocd")
+	require.NoError(t, err)
+	assert.Same(t, repo, request.Repo)
+	assert.Same(t, source, request.ApplicationSource)
+	assert.Equal(t, testSHA, request.Revision)
+	// Applications outside the control plane namespace are named after their namespace
+	assert.Equal(t, "team-a_guestbook", request.AppName)
+	assert.Equal(t, "guestbook", request.Namespace)
+	assert.Equal(t, "--enable-helm", request.KustomizeOptions.BuildOptions)
+	assert.Equal(t, requestSettings.helmRepos, request.Repos)
+	assert.Equal(t, requestSettings.helmCredentials, request.HelmRepoCreds)
+	assert.Equal(t, "1.31.0", request.KubeVersion)
+	assert.Equal(t, requestSettings.apiVersions, request.ApiVersions)
+	assert.Equal(t, "annotation", request.TrackingMethod)
+	assert.Equal(t, "app.kubernetes.io/instance", request.AppLabelKey)
+	assert.Equal(t, "test", request.InstallationID)
+	assert.True(t, request.HasMultipleSources)
+	assert.Same(t, refSources["$values"], request.RefSources["$values"])
+	assert.Equal(t, "default", request.ProjectName)
+	assert.Equal(t, []string{"*"}, request.ProjectSourceRepos)
+	assert.Equal(t, ".", request.AnnotationManifestGeneratePaths)
+
+	// The application in the control plane namespace is named after itself
+	app.Namespace = "argocd"
+	request, err = requestSettings.manifestRequest(app, proj, source, repo, testSHA, refSources, "argocd")
+	require.NoError(t, err)
+	assert.Equal(t, "guestbook", request.AppName)
+}
+
+func Test_generateManifests(t *testing.T) {
+	repoDir := newTestRepository(t, map[string]string{
+		"plain/cm.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: local\n",
+	})
+	remote := []string{`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"remote"}}`}
+	request := &apiclient.ManifestRequest{
+		Repo:              &appsv1alpha1.Repository{Repo: repoDir},
+		Revision:          "HEAD",
+		AppName:           "guestbook",
+		ApplicationSource: &appsv1alpha1.ApplicationSource{RepoURL: repoDir, Path: "plain"},
+	}
+	key, err := manifestCacheKey(request)
+	require.NoError(t, err)
+	newManager := func() *RepoServerManager {
+		return &RepoServerManager{
+			manifests:         newManifestCache(""),
+			local:             NewLocalRenderer(t.TempDir()),
+			repoServerAddress: "argocd-repo-server:8081",
+		}
+	}
+	names := func(manifests []string) []string {
+		objs, err := unmarshalManifests(manifests)
+		require.NoError(t, err)
+		var names []string
+		for _, obj := range objs {
+			names = append(names, obj.GetName())
+		}
+		return names
+	}
+
+	t.Run("manifests of the repo-server are cached", func(t *testing.T) {
+		manager := newManager()
+		repoClient := &fakeRepoClient{manifests: remote}
+		manifests, err := manager.generateManifests(context.Background(), repoClient, request, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"remote"}, names(manifests))
+		_, err = manager.generateManifests(context.Background(), repoClient, request, true)
+		require.NoError(t, err)
+		assert.Equal(t, 1, repoClient.generated)
+	})
+
+	t.Run("errors of the repo-server are not rendered locally", func(t *testing.T) {
+		manager := newManager()
+		repoClient := &fakeRepoClient{generateErr: status.Error(codes.InvalidArgument, "invalid chart")}
+		_, err := manager.generateManifests(context.Background(), repoClient, request, true)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.False(t, manager.renderLocally())
+	})
+
+	for name, generateErr := range map[string]error{
+		"unavailable": status.Error(codes.Unavailable, "connection refused"),
+		"dial":        &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+	} {
+		t.Run("manifests are rendered locally if the repo-server is unreachable: "+name, func(t *testing.T) {
+			manager := newManager()
+			repoClient := &fakeRepoClient{generateErr: generateErr}
+			manifests, err := manager.generateManifests(context.Background(), repoClient, request, true)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"local"}, names(manifests))
+			assert.True(t, manager.renderLocally())
+			// The local render is not cached as the manifests of the repo-server
+			assert.NotContains(t, manager.manifests.entries, key)
+			assert.Contains(t, manager.manifests.entries, localManifestCacheKey(key))
+		})
+	}
+}
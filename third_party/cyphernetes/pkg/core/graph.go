@@ -0,0 +1,85 @@
+package core
+
+import "fmt"
+
+func (q *QueryExecutor) buildGraph(result *QueryResult) {
+	debugLog(fmt.Sprintln("Building graph"))
+	debugLog(fmt.Sprintf("Initial nodes: %+v\n", result.Graph.Nodes))
+
+	// Process nodes from result data
+	for key, resources := range result.Data {
+		resourcesSlice, ok := resources.([]interface{})
+		if !ok || len(resourcesSlice) == 0 {
+			continue
+		}
+		for _, resource := range resourcesSlice {
+			resourceMap, ok := resource.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			metadata, ok := resourceMap["metadata"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, ok := metadata["name"].(string)
+			if !ok {
+				continue
+			}
+			kind, ok := resourceMap["kind"].(string)
+			if !ok {
+				continue
+			}
+			node := Node{
+				Id:   key,
+				Kind: kind,
+				Name: name,
+			}
+			if node.Kind != "Namespace" {
+				node.Namespace = getNamespaceName(metadata)
+			}
+			debugLog(fmt.Sprintf("Adding node from result data: %+v\n", node))
+			result.Graph.Nodes = append(result.Graph.Nodes, node)
+		}
+	}
+
+	// Process edges
+	var edges []Edge
+	edgeMap := make(map[string]bool)
+	for _, edge := range result.Graph.Edges {
+		edgeKey := fmt.Sprintf("%s-%s-%s", edge.From, edge.To, edge.Type)
+		reverseEdgeKey := fmt.Sprintf("%s-%s-%s", edge.To, edge.From, edge.Type)
+
+		if !edgeMap[edgeKey] && !edgeMap[reverseEdgeKey] {
+			edges = append(edges, edge)
+			edgeMap[edgeKey] = true
+			edgeMap[reverseEdgeKey] = true
+		}
+	}
+	result.Graph.Edges = edges
+}
+
+func getNamespaceName(metadata map[string]interface{}) string {
+	namespace, ok := metadata["namespace"].(string)
+	if !ok {
+		namespace = "default"
+	}
+	return namespace
+}
+
+func getTargetK8sResourceName(resourceTemplate map[string]interface{}, resourceName string, foreignName string) string {
+	// We'll use these in order of preference:
+	// 1. The .name or .metadata.name specified in the resource template
+	// 2. The name of the kubernetes resource represented by the foreign node
+	// 3. The name of the node
+	name := ""
+	if foreignName != "" {
+		name = foreignName
+	} else if resourceTemplate["name"] != nil {
+		name = resourceTemplate["name"].(string)
+	} else if resourceTemplate["metadata"] != nil && resourceTemplate["metadata"].(map[string]interface{})["name"] != nil {
+		name = resourceTemplate["metadata"].(map[string]interface{})["name"].(string)
+	} else {
+		name = resourceName
+	}
+	return name
+}
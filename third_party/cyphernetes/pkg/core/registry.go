@@ -0,0 +1,67 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/avitaltamir/cyphernetes/pkg/provider"
+)
+
+// RuleRegistry holds a set of relationship rules and the potential kinds derived from them. Each
+// QueryExecutor resolves relationships against its own registry, so that executors bound to
+// different clusters hold separate rules and can run queries concurrently. A registry is not safe
+// for concurrent use while rules are added to it or initialized.
+type RuleRegistry struct {
+	rules            []RelationshipRule
+	potentialKinds   map[string][]string // kind -> potential kinds
+	potentialKindsMu sync.RWMutex
+}
+
+// defaultRegistry is used by the package level functions and the executors created without a
+// registry of their own.
+var defaultRegistry = NewRuleRegistry()
+
+// NewRuleRegistry returns a registry holding the built-in relationship rules.
+func NewRuleRegistry() *RuleRegistry {
+	return &RuleRegistry{
+		rules:          copyRelationshipRules(builtinRelationshipRules),
+		potentialKinds: make(map[string][]string),
+	}
+}
+
+// AddRelationshipRule adds the given rule to the default registry.
+func AddRelationshipRule(rule RelationshipRule) {
+	defaultRegistry.AddRelationshipRule(rule)
+}
+
+// GetRelationshipRules returns the rules of the default registry.
+func GetRelationshipRules() []RelationshipRule {
+	return defaultRegistry.GetRelationshipRules()
+}
+
+// InitializeRelationships derives the relationships of the given resource specs in the default
+// registry.
+func InitializeRelationships(resourceSpecs map[string][]string, provider provider.Provider) {
+	defaultRegistry.InitializeRelationships(resourceSpecs, provider)
+}
+
+// FindPotentialKinds returns the kinds related to the given kind in the default registry.
+func FindPotentialKinds(sourceKind string, provider provider.Provider) ([]string, error) {
+	return defaultRegistry.FindPotentialKinds(sourceKind, provider)
+}
+
+// FindPotentialKindsIntersection returns the kinds related to every known kind of the given
+// relationships in the default registry.
+func FindPotentialKindsIntersection(relationships []*Relationship, provider provider.Provider) ([]string, error) {
+	return defaultRegistry.FindPotentialKindsIntersection(relationships, provider)
+}
+
+// copyRelationshipRules returns a deep copy of the given rules, so that match criteria appended
+// to the rules of one registry are not visible in another.
+func copyRelationshipRules(rules []RelationshipRule) []RelationshipRule {
+	out := make([]RelationshipRule, 0, len(rules))
+	for _, rule := range rules {
+		rule.MatchCriteria = append([]MatchCriterion(nil), rule.MatchCriteria...)
+		out = append(out, rule)
+	}
+	return out
+}
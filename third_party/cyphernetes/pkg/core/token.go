@@ -0,0 +1,64 @@
+package core
+
+const (
+	ILLEGAL TokenType = iota
+	EOF
+	IDENT
+	INT
+	STRING
+	NUMBER
+	BOOLEAN
+	NULL
+	JSONDATA
+
+	// Temporal functions and operators
+	DATETIME
+	DURATION
+	PLUS
+	MINUS
+
+	// Keywords
+	MATCH
+	CREATE
+	WHERE
+	SET
+	DELETE
+	RETURN
+	IN
+	AS
+	COUNT
+	SUM
+	AND
+	NOT
+
+	// Operators
+	EQUALS
+	NOT_EQUALS
+	GREATER_THAN
+	LESS_THAN
+	GREATER_THAN_EQUALS
+	LESS_THAN_EQUALS
+	REGEX_COMPARE
+	CONTAINS
+
+	// Delimiters
+	LPAREN
+	RPAREN
+	LBRACE
+	RBRACE
+	LBRACKET
+	RBRACKET
+	COLON
+	COMMA
+	DOT
+
+	// Relationship tokens
+	REL_NOPROPS_RIGHT
+	REL_NOPROPS_LEFT
+	REL_NOPROPS_BOTH
+	REL_NOPROPS_NONE
+	REL_BEGINPROPS_LEFT
+	REL_BEGINPROPS_NONE
+	REL_ENDPROPS_RIGHT
+	REL_ENDPROPS_NONE
+)
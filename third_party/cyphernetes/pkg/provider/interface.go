@@ -0,0 +1,31 @@
+package provider
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Provider defines the interface for different backend implementations
+type Provider interface {
+	// Resource Operations
+	// All operations support dry-run if the provider implementation supports it.
+	// Dry-run can be enabled through provider-specific configuration options.
+	GetK8sResources(kind, fieldSelector, labelSelector, namespace string) (interface{}, error)
+	DeleteK8sResources(kind, name, namespace string) error
+	CreateK8sResource(kind, name, namespace string, body interface{}) error
+	PatchK8sResource(kind, name, namespace string, patchJSON []byte) error
+
+	// Schema Operations
+	FindGVR(kind string) (schema.GroupVersionResource, error)
+	GetOpenAPIResourceSpecs() (map[string][]string, error)
+	CreateProviderForContext(context string) (Provider, error)
+
+	// Configuration
+	ToggleDryRun()
+}
+
+// ContextProvider is implemented by the providers whose resource listings can be cancelled.
+type ContextProvider interface {
+	GetK8sResourcesContext(ctx context.Context, kind, fieldSelector, labelSelector, namespace string) (interface{}, error)
+}